@@ -0,0 +1,350 @@
+package filebrowser
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	AuthorizationHeader = "Authorization"
+	BearerPrefix        = "Bearer "
+)
+
+type sessionContextKey struct{}
+
+// Session is the authenticated session of a user, as described by the token it has been issued.
+type Session struct {
+	id        string
+	uid       int32
+	issuedAt  time.Time
+	expiresAt time.Time
+}
+
+func (session *Session) Id() string {
+	return session.id
+}
+
+func (session *Session) Uid() int32 {
+	return session.uid
+}
+
+func (session *Session) IssuedAt() time.Time {
+	return session.issuedAt
+}
+
+func (session *Session) ExpiresAt() time.Time {
+	return session.expiresAt
+}
+
+// WithSession returns a copy of the given context carrying the given authenticated session.
+func WithSession(ctx context.Context, session *Session) context.Context {
+	return context.WithValue(ctx, sessionContextKey{}, session)
+}
+
+// SessionFromContext returns the authenticated session the given context carries, if any.
+func SessionFromContext(ctx context.Context) (session *Session, exists bool) {
+	session, exists = ctx.Value(sessionContextKey{}).(*Session)
+	return
+}
+
+// UidFromContext returns the id of the user the given context has been authenticated for, if any.
+func UidFromContext(ctx context.Context) (int32, bool) {
+	if session, exists := SessionFromContext(ctx); exists {
+		return session.uid, true
+	}
+
+	return 0, false
+}
+
+// TokenVerifier authenticates users by verifying the signed JWT they provide, whose subject is the user id.
+type TokenVerifier struct {
+	method   jwt.SigningMethod
+	key      interface{}
+	issuer   string
+	timeout  time.Duration
+	sessions SessionStore
+	logger   *zap.Logger
+}
+
+// NewHmacTokenVerifier returns a verifier of tokens signed with the given shared secret.
+func NewHmacTokenVerifier(secret []byte, issuer string, timeout time.Duration, logger *zap.Logger) *TokenVerifier {
+	return &TokenVerifier{
+		method:  jwt.SigningMethodHS256,
+		key:     secret,
+		issuer:  issuer,
+		timeout: timeout,
+		logger:  logger,
+	}
+}
+
+// NewEcdsaTokenVerifier returns a verifier of tokens signed with the private key of the given public one.
+func NewEcdsaTokenVerifier(key *ecdsa.PublicKey, issuer string, timeout time.Duration, logger *zap.Logger) *TokenVerifier {
+	return &TokenVerifier{
+		method:  jwt.SigningMethodES256,
+		key:     key,
+		issuer:  issuer,
+		timeout: timeout,
+		logger:  logger,
+	}
+}
+
+// NewTokenVerifier returns the verifier the given secret stands for. An EC key, either PEM or base64 encoded
+// as generated by `make secrets`, verifies ECDSA signed tokens; any other secret verifies HMAC signed ones.
+func NewTokenVerifier(secret []byte, issuer string, timeout time.Duration, logger *zap.Logger) *TokenVerifier {
+	if key := parseEcdsaPublicKey(secret); key != nil {
+		return NewEcdsaTokenVerifier(key, issuer, timeout, logger)
+	}
+
+	return NewHmacTokenVerifier(secret, issuer, timeout, logger)
+}
+
+func parseEcdsaPublicKey(secret []byte) *ecdsa.PublicKey {
+	der := secret
+	if block, _ := pem.Decode(secret); block != nil {
+		der = block.Bytes
+	} else if decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(secret))); err == nil {
+		if block, _ := pem.Decode(decoded); block != nil {
+			der = block.Bytes
+		} else {
+			der = decoded
+		}
+	}
+
+	if key, err := x509.ParsePKCS8PrivateKey(der); err == nil {
+		if key, ok := key.(*ecdsa.PrivateKey); ok {
+			return &key.PublicKey
+		}
+	}
+
+	if key, err := x509.ParsePKIXPublicKey(der); err == nil {
+		if key, ok := key.(*ecdsa.PublicKey); ok {
+			return key
+		}
+	}
+
+	return nil
+}
+
+// SetSessionStore makes the verifier reject any token whose session has been revoked in the given store.
+func (verifier *TokenVerifier) SetSessionStore(sessions SessionStore) {
+	verifier.sessions = sessions
+}
+
+// Verify returns the session the given token stands for, if, and only if, it is properly signed, has been
+// issued by the expected issuer, has not expired yet and has not been revoked.
+func (verifier *TokenVerifier) Verify(ctx context.Context, token string) (*Session, error) {
+	var claims jwt.RegisteredClaims
+	_, err := jwt.ParseWithClaims(token, &claims, func(token *jwt.Token) (interface{}, error) {
+		if token.Method.Alg() != verifier.method.Alg() {
+			return nil, jwt.ErrTokenSignatureInvalid
+		}
+
+		return verifier.key, nil
+	})
+
+	if err != nil {
+		verifier.logger.Warn("parsing jwt",
+			zap.Error(err))
+
+		return nil, ErrInvalidToken
+	}
+
+	if len(verifier.issuer) > 0 && !claims.VerifyIssuer(verifier.issuer, true) {
+		verifier.logger.Warn("verifying jwt issuer",
+			zap.String("issuer", claims.Issuer),
+			zap.String("want", verifier.issuer))
+
+		return nil, ErrInvalidToken
+	}
+
+	if claims.ExpiresAt == nil && verifier.timeout > 0 &&
+		(claims.IssuedAt == nil || claims.IssuedAt.Add(verifier.timeout).Before(time.Now())) {
+		// a token with no expiration lasts as long as the timeout since issued
+		verifier.logger.Warn("verifying jwt expiration",
+			zap.String("subject", claims.Subject))
+
+		return nil, ErrInvalidToken
+	}
+
+	uid, err := strconv.ParseInt(claims.Subject, 10, 32)
+	if err != nil {
+		verifier.logger.Warn("parsing jwt subject into int32",
+			zap.String("subject", claims.Subject),
+			zap.Error(err))
+
+		return nil, ErrInvalidToken
+	}
+
+	session := &Session{
+		id:  claims.ID,
+		uid: int32(uid),
+	}
+
+	if claims.IssuedAt != nil {
+		session.issuedAt = claims.IssuedAt.Time
+	}
+
+	if claims.ExpiresAt != nil {
+		session.expiresAt = claims.ExpiresAt.Time
+	} else if verifier.timeout > 0 {
+		session.expiresAt = session.issuedAt.Add(verifier.timeout)
+	}
+
+	if verifier.sessions == nil {
+		return session, nil
+	}
+
+	if revoked, err := verifier.sessions.IsRevoked(ctx, session); err != nil {
+		return nil, err
+	} else if revoked {
+		verifier.logger.Warn("verifying jwt session",
+			zap.String("session_id", session.id),
+			zap.Int32("user_id", session.uid),
+			zap.Error(ErrInvalidToken))
+
+		return nil, ErrInvalidToken
+	}
+
+	return session, nil
+}
+
+func bearerToken(value string) (string, error) {
+	if len(value) <= len(BearerPrefix) || !strings.EqualFold(value[:len(BearerPrefix)], BearerPrefix) {
+		return "", ErrInvalidHeader
+	}
+
+	return value[len(BearerPrefix):], nil
+}
+
+// requestToken returns the token the given request carries. Clients not supporting bearer tokens, such as
+// most WebDAV ones, may provide it instead as the password of their basic credentials.
+func requestToken(r *http.Request) (string, error) {
+	if _, password, exists := r.BasicAuth(); exists {
+		if len(password) == 0 {
+			return "", ErrInvalidHeader
+		}
+
+		return password, nil
+	}
+
+	return bearerToken(r.Header.Get(AuthorizationHeader))
+}
+
+// authenticate returns a copy of the given context carrying the session the bearer token in its metadata
+// stands for.
+func (verifier *TokenVerifier) authenticate(ctx context.Context) (context.Context, error) {
+	meta, exists := metadata.FromIncomingContext(ctx)
+	if !exists {
+		return nil, ErrUnauthorized
+	}
+
+	values := meta.Get(AuthorizationHeader)
+	if len(values) == 0 {
+		return nil, ErrUnauthorized
+	}
+
+	token, err := bearerToken(values[0])
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := verifier.Verify(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	return WithSession(ctx, session), nil
+}
+
+// UnaryServerInterceptor rejects any unary call not carrying a valid token, otherwise the id of the user
+// it has been issued for is put into the call's context.
+func (verifier *TokenVerifier) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := verifier.authenticate(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (stream *authenticatedStream) Context() context.Context {
+	return stream.ctx
+}
+
+// StreamServerInterceptor rejects any stream not carrying a valid token, otherwise the id of the user it
+// has been issued for is put into the stream's context.
+func (verifier *TokenVerifier) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := verifier.authenticate(stream.Context())
+		if err != nil {
+			return err
+		}
+
+		return handler(srv, &authenticatedStream{ServerStream: stream, ctx: ctx})
+	}
+}
+
+// HttpMiddleware rejects any request not carrying a valid token, otherwise the id of the user it has been
+// issued for is put into the request's context.
+func (verifier *TokenVerifier) HttpMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, err := requestToken(r)
+		if err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		session, err := verifier.Verify(r.Context(), token)
+		if errors.Is(err, ErrInvalidToken) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		} else if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(WithSession(r.Context(), session)))
+	})
+}
+
+type challengeWriter struct {
+	http.ResponseWriter
+	challenge string
+}
+
+func (w *challengeWriter) WriteHeader(code int) {
+	if code == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", w.challenge)
+	}
+
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// BasicChallenge asks for basic credentials any client whose request gets rejected as unauthorized, so it
+// can prompt the user for them.
+func BasicChallenge(realm string, next http.Handler) http.Handler {
+	challenge := `Basic realm="` + realm + `", charset="UTF-8"`
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(&challengeWriter{ResponseWriter: w, challenge: challenge}, r)
+	})
+}
@@ -0,0 +1,279 @@
+package filebrowser
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func newTestToken(t *testing.T, method jwt.SigningMethod, key interface{}, claims jwt.RegisteredClaims) string {
+	token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+	if err != nil {
+		t.Fatalf("got error = %v, want = %v", err, nil)
+	}
+
+	return token
+}
+
+func TestTokenVerifierVerify(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	secret := []byte("secret")
+	verifier := NewTokenVerifier(secret, "issuer", 0, logger)
+
+	now := time.Now()
+	tests := []struct {
+		name   string
+		claims jwt.RegisteredClaims
+		key    []byte
+		uid    int32
+		err    error
+	}{
+		{
+			name: "valid token",
+			claims: jwt.RegisteredClaims{
+				Subject:   "999",
+				Issuer:    "issuer",
+				ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+			},
+			key: secret,
+			uid: 999,
+		},
+		{
+			name: "expired token",
+			claims: jwt.RegisteredClaims{
+				Subject:   "999",
+				Issuer:    "issuer",
+				ExpiresAt: jwt.NewNumericDate(now.Add(-time.Hour)),
+			},
+			key: secret,
+			err: ErrInvalidToken,
+		},
+		{
+			name: "wrong issuer",
+			claims: jwt.RegisteredClaims{
+				Subject: "999",
+				Issuer:  "another",
+			},
+			key: secret,
+			err: ErrInvalidToken,
+		},
+		{
+			name: "wrong signature",
+			claims: jwt.RegisteredClaims{
+				Subject: "999",
+				Issuer:  "issuer",
+			},
+			key: []byte("another"),
+			err: ErrInvalidToken,
+		},
+		{
+			name: "non numeric subject",
+			claims: jwt.RegisteredClaims{
+				Subject: "alice",
+				Issuer:  "issuer",
+			},
+			key: secret,
+			err: ErrInvalidToken,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			token := newTestToken(t, jwt.SigningMethodHS256, test.key, test.claims)
+			session, err := verifier.Verify(context.Background(), token)
+			if !errors.Is(err, test.err) {
+				t.Errorf("got error = %v, want = %v", err, test.err)
+			}
+
+			if err == nil && session.Uid() != test.uid {
+				t.Errorf("got uid = %v, want = %v", session.Uid(), test.uid)
+			}
+		})
+	}
+}
+
+func TestTokenVerifierTimeout(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	secret := []byte("secret")
+	verifier := NewTokenVerifier(secret, "", time.Hour, logger)
+
+	token := newTestToken(t, jwt.SigningMethodHS256, secret, jwt.RegisteredClaims{
+		Subject:  "999",
+		IssuedAt: jwt.NewNumericDate(time.Now().Add(-2 * time.Hour)),
+	})
+
+	if _, err := verifier.Verify(context.Background(), token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("got error = %v, want = %v", err, ErrInvalidToken)
+	}
+
+	token = newTestToken(t, jwt.SigningMethodHS256, secret, jwt.RegisteredClaims{
+		Subject:  "999",
+		IssuedAt: jwt.NewNumericDate(time.Now()),
+	})
+
+	if _, err := verifier.Verify(context.Background(), token); err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+	}
+}
+
+func TestTokenVerifierWithEcdsaKey(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	der, _ := x509.MarshalPKCS8PrivateKey(key)
+	secret := base64.StdEncoding.EncodeToString(der)
+
+	verifier := NewTokenVerifier([]byte(secret), "", 0, logger)
+	token := newTestToken(t, jwt.SigningMethodES256, key, jwt.RegisteredClaims{Subject: "999"})
+
+	if session, err := verifier.Verify(context.Background(), token); err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+	} else if session.Uid() != 999 {
+		t.Errorf("got uid = %v, want = %v", session.Uid(), 999)
+	}
+
+	// a token signed with the secret itself must not be taken as a hmac one
+	token = newTestToken(t, jwt.SigningMethodHS256, []byte(secret), jwt.RegisteredClaims{Subject: "999"})
+	if _, err := verifier.Verify(context.Background(), token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("got error = %v, want = %v", err, ErrInvalidToken)
+	}
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	secret := []byte("secret")
+	verifier := NewTokenVerifier(secret, "", 0, logger)
+	interceptor := verifier.UnaryServerInterceptor()
+
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return GetUidFromGrpcCtx(ctx, "X-Uid", logger)
+	}
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("X-Uid", "111"))
+	if _, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{}, handler); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("got error = %v, want = %v", err, ErrUnauthorized)
+	}
+
+	token := newTestToken(t, jwt.SigningMethodHS256, secret, jwt.RegisteredClaims{Subject: "999"})
+	ctx = metadata.NewIncomingContext(context.Background(), metadata.Pairs("X-Uid", "111", "authorization", BearerPrefix+token))
+
+	if uid, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{}, handler); err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+	} else if uid != int32(999) {
+		t.Errorf("got uid = %v, want = %v", uid, 999)
+	}
+}
+
+func TestHttpMiddleware(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	secret := []byte("secret")
+	verifier := NewTokenVerifier(secret, "", 0, logger)
+
+	var got int32
+	handler := verifier.HttpMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = GetUidFromHttpRequest(r, "X-Uid", logger)
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/profile", nil)
+	r.Header.Set("X-Uid", "111")
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("got status = %v, want = %v", w.Code, http.StatusUnauthorized)
+	}
+
+	token := newTestToken(t, jwt.SigningMethodHS256, secret, jwt.RegisteredClaims{Subject: "999"})
+	r.Header.Set(AuthorizationHeader, BearerPrefix+token)
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("got status = %v, want = %v", w.Code, http.StatusOK)
+	}
+
+	if got != 999 {
+		t.Errorf("got uid = %v, want = %v", got, 999)
+	}
+}
+
+func TestHttpMiddlewareWithBasicAuth(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	secret := []byte("secret")
+	verifier := NewTokenVerifier(secret, "", 0, logger)
+
+	var got int32
+	handler := BasicChallenge("filebrowser", verifier.HttpMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = GetUidFromHttpRequest(r, "X-Uid", logger)
+	})))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("got status = %v, want = %v", w.Code, http.StatusUnauthorized)
+	}
+
+	if challenge := w.Header().Get("WWW-Authenticate"); !strings.HasPrefix(challenge, "Basic ") {
+		t.Errorf("got challenge = %v, want = %v", challenge, "Basic")
+	}
+
+	token := newTestToken(t, jwt.SigningMethodHS256, secret, jwt.RegisteredClaims{Subject: "999"})
+	r.SetBasicAuth("anyone", token)
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("got status = %v, want = %v", w.Code, http.StatusOK)
+	}
+
+	if challenge := w.Header().Get("WWW-Authenticate"); len(challenge) > 0 {
+		t.Errorf("got challenge = %v, want = %v", challenge, "")
+	}
+
+	if got != 999 {
+		t.Errorf("got uid = %v, want = %v", got, 999)
+	}
+}
+
+func TestGetUidWhenNoHeaderIsTrusted(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("X-Uid", "111"))
+	if _, err := GetUidFromGrpcCtx(ctx, "", logger); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("got error = %v, want = %v", err, ErrUnauthorized)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/profile", nil)
+	r.Header.Set("X-Uid", "111")
+	if _, err := GetUidFromHttpRequest(r, "", logger); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("got error = %v, want = %v", err, ErrUnauthorized)
+	}
+}
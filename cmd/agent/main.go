@@ -4,11 +4,13 @@ import (
 	"context"
 	"os"
 	"sync"
+	"time"
 
 	fb "github.com/alvidir/filebrowser"
 	"github.com/alvidir/filebrowser/cmd"
 	dir "github.com/alvidir/filebrowser/directory"
 	"github.com/alvidir/filebrowser/file"
+	"github.com/alvidir/filebrowser/group"
 	"github.com/alvidir/filebrowser/user"
 	"github.com/joho/godotenv"
 	"go.uber.org/zap"
@@ -72,6 +74,25 @@ func handleRabbitMqFileEvents(ctx context.Context, bus *fb.RabbitMqEventBus, han
 	return bus.Consume(ctx, queue, handler.OnEvent)
 }
 
+func purgeTrashPeriodically(ctx context.Context, app *dir.DirectoryApplication, logger *zap.Logger) {
+	retention := cmd.GetTrashRetention(logger)
+	ticker := time.NewTicker(cmd.GetTrashPurgeInterval(logger))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-ticker.C:
+			if err := app.PurgeTrash(ctx, time.Now().Add(-retention)); err != nil {
+				logger.Error("purging trash",
+					zap.Error(err))
+			}
+		}
+	}
+}
+
 func main() {
 	logger, _ := zap.NewProduction()
 	defer logger.Sync()
@@ -83,8 +104,12 @@ func main() {
 
 	mongoConn := cmd.GetMongoConnection(logger)
 	fileRepo := file.NewMongoFileRepository(mongoConn, logger)
+	contentStore := cmd.GetContentStore(mongoConn, logger)
+	cmd.MigrateLegacyContent(fileRepo, contentStore, logger)
 	directoryRepo := dir.NewMongoDirectoryRepository(mongoConn, fileRepo, logger)
-	directoryApp := dir.NewDirectoryApplication(directoryRepo, fileRepo, logger)
+	directoryApp := dir.NewDirectoryApplication(directoryRepo, fileRepo, contentStore, logger)
+	groupRepo := group.NewMongoGroupRepository(mongoConn, logger)
+	groupApp := group.NewGroupApplication(groupRepo, logger)
 
 	conn := cmd.GetAmqpConnection(logger)
 	defer conn.Close()
@@ -98,15 +123,20 @@ func main() {
 	eventIssuer := cmd.GetEventIssuer(logger)
 	fileExchange := cmd.GetFileExchange(logger)
 	fileBus := file.NewFileEventBus(bus, fileExchange, eventIssuer)
+	directoryApp.SetEventBus(fileBus)
 
-	fileApp := file.NewFileApplication(fileRepo, directoryApp, fileBus, logger)
+	fileApp := file.NewFileApplication(fileRepo, contentStore, directoryApp, groupApp, fileBus, logger)
 	userEventHandler := user.NewUserEventHandler(directoryApp, fileApp, logger)
+	if sessions := cmd.GetSessionStore(logger); sessions != nil {
+		userEventHandler.SetSessionStore(sessions)
+	}
+
 	fileEventHandler := file.NewFileEventHandler(fileApp, logger)
 
 	fileEventHandler.DiscardIssuer(eventIssuer)
 
 	var wg sync.WaitGroup
-	wg.Add(2)
+	wg.Add(3)
 
 	defer wg.Wait()
 
@@ -127,4 +157,9 @@ func main() {
 			cancel()
 		}
 	}()
+
+	go func() {
+		defer wg.Done()
+		purgeTrashPeriodically(ctx, directoryApp, logger)
+	}()
 }
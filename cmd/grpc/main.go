@@ -1,12 +1,14 @@
 package main
 
 import (
-	"os"
+	"context"
 
 	fb "github.com/alvidir/filebrowser"
 	"github.com/alvidir/filebrowser/cmd"
 	dir "github.com/alvidir/filebrowser/directory"
 	"github.com/alvidir/filebrowser/file"
+	"github.com/alvidir/filebrowser/group"
+	"github.com/alvidir/filebrowser/link"
 	"github.com/alvidir/filebrowser/proto"
 	"github.com/joho/godotenv"
 	"go.uber.org/zap"
@@ -23,15 +25,20 @@ func main() {
 	}
 
 	mongoConn := cmd.GetMongoConnection(logger)
-	if header, exists := os.LookupEnv(cmd.ENV_UID_HEADER); exists {
-		cmd.UidHeader = header
-	}
+	verifier := cmd.GetTokenVerifier(logger)
+	uidHeader := cmd.GetUidHeader(verifier)
 
 	fileRepo := file.NewMongoFileRepository(mongoConn, logger)
+	contentStore := cmd.GetContentStore(mongoConn, logger)
+	cmd.MigrateLegacyContent(fileRepo, contentStore, logger)
 
 	directoryRepo := dir.NewMongoDirectoryRepository(mongoConn, fileRepo, logger)
-	directoryApp := dir.NewDirectoryApplication(directoryRepo, fileRepo, logger)
-	directoryGrpcService := dir.NewDirectoryGrpcServer(directoryApp, logger, cmd.UidHeader)
+	directoryApp := dir.NewDirectoryApplication(directoryRepo, fileRepo, contentStore, logger)
+	directoryGrpcService := dir.NewDirectoryGrpcServer(directoryApp, logger, uidHeader)
+
+	groupRepo := group.NewMongoGroupRepository(mongoConn, logger)
+	groupApp := group.NewGroupApplication(groupRepo, logger)
+	groupGrpcService := group.NewGroupGrpcServer(groupApp, uidHeader, logger)
 
 	conn := cmd.GetAmqpConnection(logger)
 	defer conn.Close()
@@ -44,13 +51,56 @@ func main() {
 	bus := fb.NewRabbitMqEventBus(ch, logger)
 
 	fileBus := file.NewFileEventBus(bus, fileExchange, eventIssuer)
+	directoryApp.SetEventBus(fileBus)
+
+	// directory changes, no matter the service making them, are delivered to the watchers through the bus
+	watchQueue := cmd.GetWatchQueue(logger)
+	if err := bus.QueueBind(fileExchange, watchQueue); err != nil {
+		logger.Fatal("binding queue",
+			zap.String("queue", watchQueue),
+			zap.String("exchange", fileExchange),
+			zap.Error(err))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		if err := bus.Consume(ctx, watchQueue, directoryApp.Watcher().OnEvent); err != nil {
+			logger.Error("consuming directory events",
+				zap.String("queue", watchQueue),
+				zap.Error(err))
+		}
+	}()
 
-	fileApp := file.NewFileApplication(fileRepo, directoryApp, fileBus, logger)
-	fileGrpcService := file.NewFileGrpcServer(fileApp, cmd.UidHeader, logger)
+	fileApp := file.NewFileApplication(fileRepo, contentStore, directoryApp, groupApp, fileBus, logger)
+	fileGrpcService := file.NewFileGrpcServer(fileApp, uidHeader, logger)
+
+	linkRepo := link.NewMongoLinkRepository(mongoConn, logger)
+	linkApp := link.NewLinkApplication(linkRepo, fileRepo, contentStore, logger)
+	linkGrpcService := link.NewLinkGrpcServer(linkApp, uidHeader, logger)
+
+	// errors must be converted once the rest of interceptors have run, so the error ones go first
+	opts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(fb.UnaryErrorInterceptor(logger)),
+		grpc.ChainStreamInterceptor(fb.StreamErrorInterceptor(logger)),
+	}
+
+	if verifier != nil {
+		if sessions := cmd.GetSessionStore(logger); sessions != nil {
+			verifier.SetSessionStore(sessions)
+		}
+
+		opts = append(opts,
+			grpc.ChainUnaryInterceptor(verifier.UnaryServerInterceptor()),
+			grpc.ChainStreamInterceptor(verifier.StreamServerInterceptor()))
+	}
 
-	grpcServer := grpc.NewServer()
+	grpcServer := grpc.NewServer(opts...)
 	proto.RegisterDirectoryServiceServer(grpcServer, directoryGrpcService)
 	proto.RegisterFileServiceServer(grpcServer, fileGrpcService)
+	proto.RegisterLinkServiceServer(grpcServer, linkGrpcService)
+	proto.RegisterGroupServiceServer(grpcServer, groupGrpcService)
 	lis := cmd.GetNetworkListener(logger)
 
 	logger.Info("server ready to accept connections",
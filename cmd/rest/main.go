@@ -2,11 +2,13 @@ package main
 
 import (
 	"net/http"
-	"os"
 
+	fb "github.com/alvidir/filebrowser"
 	"github.com/alvidir/filebrowser/cmd"
 	dir "github.com/alvidir/filebrowser/directory"
 	"github.com/alvidir/filebrowser/file"
+	"github.com/alvidir/filebrowser/group"
+	"github.com/alvidir/filebrowser/link"
 	"github.com/alvidir/filebrowser/user"
 	"github.com/joho/godotenv"
 	"go.uber.org/zap"
@@ -22,22 +24,84 @@ func main() {
 	}
 
 	mongoConn := cmd.GetMongoConnection(logger)
-	if header, exists := os.LookupEnv(cmd.ENV_UID_HEADER); exists {
-		cmd.UidHeader = header
-	}
+	verifier := cmd.GetTokenVerifier(logger)
+	uidHeader := cmd.GetUidHeader(verifier)
 
 	fileRepo := file.NewMongoFileRepository(mongoConn, logger)
+	contentStore := cmd.GetContentStore(mongoConn, logger)
+	cmd.MigrateLegacyContent(fileRepo, contentStore, logger)
 
 	directoryRepo := dir.NewMongoDirectoryRepository(mongoConn, fileRepo, logger)
-	userApp := user.NewUserApplication(directoryRepo, fileRepo, logger)
-	userService := user.NewUserRestServer(userApp, logger, cmd.UidHeader)
+	directoryApp := dir.NewDirectoryApplication(directoryRepo, fileRepo, contentStore, logger)
+	directoryService := dir.NewDirectoryRestServer(directoryApp, logger, uidHeader)
+
+	userApp := user.NewUserApplication(directoryRepo, fileRepo, contentStore, logger)
+	userService := user.NewUserRestServer(userApp, logger, uidHeader)
+
+	groupRepo := group.NewMongoGroupRepository(mongoConn, logger)
+	groupApp := group.NewGroupApplication(groupRepo, logger)
+
+	conn := cmd.GetAmqpConnection(logger)
+	defer conn.Close()
+
+	ch := cmd.GetAmqpChannel(conn, logger)
+	defer ch.Close()
+
+	eventIssuer := cmd.GetEventIssuer(logger)
+	fileExchange := cmd.GetFileExchange(logger)
+	bus := fb.NewRabbitMqEventBus(ch, logger)
+
+	fileBus := file.NewFileEventBus(bus, fileExchange, eventIssuer)
+	directoryApp.SetEventBus(fileBus)
+
+	fileApp := file.NewFileApplication(fileRepo, contentStore, directoryApp, groupApp, fileBus, logger)
+	fileService := file.NewFileRestServer(fileApp, logger, uidHeader)
+
+	linkRepo := link.NewMongoLinkRepository(mongoConn, logger)
+	linkApp := link.NewLinkApplication(linkRepo, fileRepo, contentStore, logger)
+	linkService := link.NewLinkRestServer(linkApp, logger)
+
+	registry := cmd.GetRestRegistry(logger)
+	userService.RegisterRoutes(registry)
+	fileService.RegisterRoutes(registry)
+	directoryService.RegisterRoutes(registry)
+	linkService.RegisterRoutes(registry)
+
+	// any endpoint but links and the api document requires the user to be authenticated
+	authenticated := http.NewServeMux()
+	authenticated.Handle("/profile", userService)
+	authenticated.Handle(file.FilePathPrefix, fileService)
+	authenticated.Handle(dir.DirectoryPathPrefix, directoryService)
+	authenticated.Handle(dir.MovePath, directoryService)
+	authenticated.Handle(dir.SearchPath, directoryService)
+
+	var handler http.Handler = authenticated
+	if verifier != nil {
+		handler = verifier.HttpMiddleware(authenticated)
+
+		if sessions := cmd.GetSessionStore(logger); sessions != nil {
+			verifier.SetSessionStore(sessions)
+			authenticated.Handle(fb.LogoutPath, fb.NewLogoutHandler(sessions, logger))
+			registry.Register(fb.Route{
+				Method:    http.MethodPost,
+				Path:      fb.LogoutPath,
+				Summary:   "Revokes the session the request has been authenticated with",
+				Responses: map[int]fb.Response{http.StatusNoContent: {Description: "The session has been revoked"}},
+			})
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(link.LinkPathPrefix, linkService)
+	mux.Handle(fb.OpenApiPath, registry)
+	mux.Handle("/", handler)
 
 	lis := cmd.GetNetworkListener(logger)
 
 	logger.Info("server ready to accept connections",
 		zap.String("address", cmd.ServiceAddr))
 
-	if err := http.Serve(lis, userService); err != nil {
+	if err := http.Serve(lis, registry.Middleware(mux)); err != nil {
 		logger.Fatal("server terminated with errors",
 			zap.Error(err))
 	}
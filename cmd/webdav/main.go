@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net/http"
+	"os"
+
+	fb "github.com/alvidir/filebrowser"
+	"github.com/alvidir/filebrowser/cmd"
+	dir "github.com/alvidir/filebrowser/directory"
+	"github.com/alvidir/filebrowser/file"
+	"github.com/alvidir/filebrowser/group"
+	"github.com/joho/godotenv"
+	"go.uber.org/zap"
+)
+
+func main() {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	if err := godotenv.Load(); err != nil {
+		logger.Warn("loading dotenv file",
+			zap.Error(err))
+	}
+
+	mongoConn := cmd.GetMongoConnection(logger)
+	verifier := cmd.GetTokenVerifier(logger)
+	uidHeader := cmd.GetUidHeader(verifier)
+
+	if prefix, exists := os.LookupEnv(cmd.ENV_WEBDAV_PREFIX); exists {
+		cmd.WebdavPrefix = prefix
+	}
+
+	fileRepo := file.NewMongoFileRepository(mongoConn, logger)
+	contentStore := cmd.GetContentStore(mongoConn, logger)
+	cmd.MigrateLegacyContent(fileRepo, contentStore, logger)
+
+	directoryRepo := dir.NewMongoDirectoryRepository(mongoConn, fileRepo, logger)
+	directoryApp := dir.NewDirectoryApplication(directoryRepo, fileRepo, contentStore, logger)
+
+	groupRepo := group.NewMongoGroupRepository(mongoConn, logger)
+	groupApp := group.NewGroupApplication(groupRepo, logger)
+
+	conn := cmd.GetAmqpConnection(logger)
+	defer conn.Close()
+
+	ch := cmd.GetAmqpChannel(conn, logger)
+	defer ch.Close()
+
+	eventIssuer := cmd.GetEventIssuer(logger)
+	fileExchange := cmd.GetFileExchange(logger)
+	bus := fb.NewRabbitMqEventBus(ch, logger)
+
+	fileBus := file.NewFileEventBus(bus, fileExchange, eventIssuer)
+	directoryApp.SetEventBus(fileBus)
+	fileApp := file.NewFileApplication(fileRepo, contentStore, directoryApp, groupApp, fileBus, logger)
+
+	var handler http.Handler = dir.NewDirectoryWebdavServer(directoryApp, fileApp, cmd.WebdavPrefix, logger, uidHeader)
+	if verifier != nil {
+		if sessions := cmd.GetSessionStore(logger); sessions != nil {
+			verifier.SetSessionStore(sessions)
+		}
+
+		// webdav clients authenticate through basic credentials, whose password is the token itself
+		handler = fb.BasicChallenge(cmd.ServiceName, verifier.HttpMiddleware(handler))
+	}
+
+	lis := cmd.GetNetworkListener(logger)
+
+	logger.Info("server ready to accept connections",
+		zap.String("address", cmd.ServiceAddr))
+
+	if err := http.Serve(lis, handler); err != nil {
+		logger.Fatal("server terminated with errors",
+			zap.Error(err))
+	}
+}
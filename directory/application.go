@@ -2,9 +2,11 @@ package directory
 
 import (
 	"context"
+	"errors"
 	"path"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	fb "github.com/alvidir/filebrowser"
@@ -12,6 +14,9 @@ import (
 	"go.uber.org/zap"
 )
 
+// MaxSaveAttempts is the amount of times a directory is saved, at most, when conflicting with concurrent updates.
+const MaxSaveAttempts = 3
+
 type RepoOptions struct {
 	LazyLoading bool
 }
@@ -21,22 +26,49 @@ type DirectoryRepository interface {
 	Create(ctx context.Context, directory *Directory) error
 	Save(ctx context.Context, directory *Directory) error
 	Delete(ctx context.Context, directory *Directory) error
+	FindAllByTrashedBefore(ctx context.Context, deadline time.Time, options *RepoOptions) ([]*Directory, error)
+	FindAllByUserIds(ctx context.Context, userIds []int32, options *RepoOptions) ([]*Directory, error)
+}
+
+// EventBus notifies about the changes made into the directory of a user, as well as about those files
+// permanently deleted from it.
+type EventBus interface {
+	EmitPathCreated(uid int32, f *file.File, p string) error
+	EmitPathDeleted(uid int32, f *file.File, p string) error
+	EmitPathMoved(uid int32, f *file.File, from, to string) error
+	EmitFileDeleted(uid int32, f *file.File) error
 }
 
 type DirectoryApplication struct {
 	dirRepo  DirectoryRepository
 	fileRepo file.FileRepository
+	content  *file.ContentStore
+	bus      EventBus
+	watcher  *DirectoryWatcher
 	logger   *zap.Logger
 }
 
-func NewDirectoryApplication(dirRepo DirectoryRepository, fileRepo file.FileRepository, logger *zap.Logger) *DirectoryApplication {
+func NewDirectoryApplication(dirRepo DirectoryRepository, fileRepo file.FileRepository, content *file.ContentStore, logger *zap.Logger) *DirectoryApplication {
 	return &DirectoryApplication{
 		dirRepo:  dirRepo,
 		fileRepo: fileRepo,
+		content:  content,
+		watcher:  NewDirectoryWatcher(dirRepo, logger),
 		logger:   logger,
 	}
 }
 
+// SetEventBus makes the application emit through the given bus any change made into a directory. Since the
+// changes are no longer delivered straight to the watcher, it is expected to be fed by the bus itself.
+func (app *DirectoryApplication) SetEventBus(bus EventBus) {
+	app.bus = bus
+}
+
+// Watcher returns the watcher through which all the changes made into any directory are delivered.
+func (app *DirectoryApplication) Watcher() *DirectoryWatcher {
+	return app.watcher
+}
+
 // Create creates a new directory if, and only if, there is no other for the given user uid. Otherwise returns an error.
 func (app *DirectoryApplication) Create(ctx context.Context, uid int32) (*Directory, error) {
 	app.logger.Info("processing a \"create\" directory request",
@@ -71,11 +103,84 @@ func (app *DirectoryApplication) Get(ctx context.Context, uid int32, p string) (
 	selected.files = dir.AggregateFiles(absP)
 	selected.path = absP
 	selected.id = dir.id
+	selected.revision = dir.revision
 
 	return selected, nil
 }
 
-// Delete removes from the directory all those files whose path matches the given one.
+// Lookup returns the file or folder located at the given path of the user's directory. Unlike Get, only the file
+// at the given path, if any, is loaded from the repository.
+func (app *DirectoryApplication) Lookup(ctx context.Context, uid int32, p string) (*file.File, error) {
+	app.logger.Info("processing a \"lookup\" directory request",
+		zap.Int32("user_id", uid),
+		zap.String("path", p))
+
+	dir, err := app.dirRepo.FindByUserId(ctx, uid, &RepoOptions{LazyLoading: true})
+	if err != nil {
+		return nil, err
+	}
+
+	absP := filepath.Join(PathSeparator, p)
+	if stub := dir.FileByPath(absP); stub != nil {
+		f, err := app.fileRepo.Find(ctx, stub.Id(), &file.RepoOptions{View: file.BasicView})
+		if err != nil {
+			return nil, err
+		}
+
+		f.MarkAsProtected() // avoid saving changes
+		f.SetDirectory(path.Dir(absP))
+		f.SetName(path.Base(absP))
+		f.ProtectFields(uid)
+
+		if _, exists := f.Value(file.MetadataSizeKey); !exists && !f.IsFolder() {
+			// files written before sizes were tracked have no size metadata
+			size, err := app.content.Size(ctx, f)
+			if err != nil {
+				return nil, err
+			}
+
+			f.AddMetadata(file.MetadataSizeKey, strconv.FormatInt(size, 10))
+		}
+
+		return f, nil
+	}
+
+	if len(dir.FilesByPath(absP)) == 0 {
+		return nil, fb.ErrNotFound
+	}
+
+	// the folder is not in the directory by itself, but synthesised from the files under it
+	folder, err := file.NewFile("", path.Base(absP))
+	if err != nil {
+		return nil, err
+	}
+
+	folder.SetFlag(file.Directory)
+	folder.SetDirectory(path.Dir(absP))
+	return folder, nil
+}
+
+// Watch delivers through the returned channel all the changes made at, or under, the given path of the user's
+// directory until the given context is done, when the channel gets closed.
+func (app *DirectoryApplication) Watch(ctx context.Context, uid int32, p string) (<-chan *WatchEvent, error) {
+	app.logger.Info("processing a \"watch\" directory request",
+		zap.Int32("user_id", uid),
+		zap.String("path", p))
+
+	if _, err := app.dirRepo.FindByUserId(ctx, uid, &RepoOptions{LazyLoading: true}); err != nil {
+		return nil, err
+	}
+
+	sub := app.watcher.subscribe(uid, filepath.Join(PathSeparator, p))
+	go func() {
+		<-ctx.Done()
+		app.watcher.unsubscribe(sub)
+	}()
+
+	return sub.events, nil
+}
+
+// Delete moves into the trash all those files whose path matches the given one.
 func (app *DirectoryApplication) Delete(ctx context.Context, uid int32, p string) (*Directory, error) {
 	app.logger.Info("processing a \"delete\" directory request",
 		zap.Int32("user_id", uid),
@@ -88,39 +193,252 @@ func (app *DirectoryApplication) Delete(ctx context.Context, uid int32, p string
 
 	absP := filepath.Join(PathSeparator, p)
 	affected := NewDirectory(uid)
-	affected.files = dir.FilesByPath(absP)
 	affected.path = absP
 
-	for _, f := range affected.files {
-		dir.RemoveFile(f)
-		if f.Permission(uid)&file.Owner == 0 {
+	err = app.save(ctx, dir, func(dir *Directory) error {
+		affected.files = make(map[string]*file.File)
+		for _, f := range dir.FilesByPath(absP) {
+			if trashed := dir.TrashFile(f); trashed != nil {
+				affected.files[trashed.path] = f
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	affected.revision = dir.revision
+	for fp, f := range affected.files {
+		app.notify(ctx, fb.EventKindDeleted, uid, f, fp, "")
+		f.ProtectFields(uid)
+	}
+
+	return affected, nil
+}
+
+// ListTrash returns all those trashed files that were located at, or under, the given path.
+func (app *DirectoryApplication) ListTrash(ctx context.Context, uid int32, p string) ([]*TrashedFile, error) {
+	app.logger.Info("processing a directory's \"list trash\" request",
+		zap.Int32("user_id", uid),
+		zap.String("path", p))
+
+	dir, err := app.dirRepo.FindByUserId(ctx, uid, &RepoOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	trash := dir.TrashByPath(p)
+	for _, trashed := range trash {
+		trashed.file.ProtectFields(uid)
+		trashed.file.MarkAsProtected() // avoid saving changes
+		trashed.file.AddMetadata(file.MetadataDeletedAtKey, strconv.FormatInt(trashed.deletedAt.Unix(), file.TimestampBase))
+	}
+
+	return trash, nil
+}
+
+// Restore moves back from the trash all those files that were located at, or under, the given path. The path
+// of a restored file may change if, and only if, another file with the same name exists in the same path.
+func (app *DirectoryApplication) Restore(ctx context.Context, uid int32, p string) (*Directory, error) {
+	app.logger.Info("processing a directory's \"restore\" request",
+		zap.Int32("user_id", uid),
+		zap.String("path", p))
+
+	dir, err := app.dirRepo.FindByUserId(ctx, uid, &RepoOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	absP := filepath.Join(PathSeparator, p)
+	affected := NewDirectory(uid)
+	affected.path = absP
+
+	err = app.save(ctx, dir, func(dir *Directory) error {
+		affected.files = make(map[string]*file.File)
+		for _, trashed := range dir.TrashByPath(absP) {
+			fp := dir.RestoreFile(trashed)
+			affected.files[fp] = trashed.file
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := app.inherit(ctx, dir, affected.files); err != nil {
+		return nil, err
+	}
+
+	affected.revision = dir.revision
+	for fp, f := range affected.files {
+		app.notify(ctx, fb.EventKindCreated, uid, f, filepath.Join(PathSeparator, fp), "")
+		f.ProtectFields(uid)
+	}
+
+	return affected, nil
+}
+
+// EmptyTrash permanently removes from the trash all those files that were located at, or under, the given path.
+func (app *DirectoryApplication) EmptyTrash(ctx context.Context, uid int32, p string) ([]*TrashedFile, error) {
+	app.logger.Info("processing a directory's \"empty trash\" request",
+		zap.Int32("user_id", uid),
+		zap.String("path", p))
+
+	dir, err := app.dirRepo.FindByUserId(ctx, uid, &RepoOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	trash := dir.TrashByPath(p)
+	if err := app.purge(ctx, dir, trash); err != nil {
+		return nil, err
+	}
+
+	for _, trashed := range trash {
+		trashed.file.ProtectFields(uid)
+	}
+
+	return trash, nil
+}
+
+// PurgeTrash permanently removes from all directories those trashed files deleted before the given deadline.
+func (app *DirectoryApplication) PurgeTrash(ctx context.Context, deadline time.Time) error {
+	app.logger.Info("processing a \"purge trash\" request",
+		zap.Time("deadline", deadline))
+
+	dirs, err := app.dirRepo.FindAllByTrashedBefore(ctx, deadline, &RepoOptions{})
+	if err != nil {
+		return err
+	}
+
+	for _, dir := range dirs {
+		if err := app.purge(ctx, dir, dir.TrashedBefore(deadline)); err != nil {
+			app.logger.Error("purging directory's trash",
+				zap.Int32("user_id", dir.userId),
+				zap.Error(err))
+		}
+	}
+
+	return nil
+}
+
+// purge removes the given trashed files from the directory. Those files the directory's user is the single
+// owner of are deleted as well, while from any other the user loses its access.
+func (app *DirectoryApplication) purge(ctx context.Context, dir *Directory, trash []*TrashedFile) error {
+	for _, trashed := range trash {
+		f := trashed.file
+		dir.RemoveTrashed(f)
+
+		if dir.hasFile(f) {
+			// the file has been registered again since trashed
 			continue
 		}
 
-		if len(f.Owners()) > 1 {
+		if f.Permission(dir.userId)&file.Owner == 0 || len(f.Owners()) > 1 {
+			wasOwner := f.Permission(dir.userId)&file.Owner != 0
+			if f.RevokeAccess(dir.userId) {
+				if err := app.fileRepo.Save(ctx, f); err != nil {
+					return err
+				}
+			}
+
+			if wasOwner && !f.IsFolder() {
+				app.release(ctx, f, []int32{dir.userId})
+			}
+
 			continue
 		}
 
 		f.AddMetadata(file.MetadataDeletedAtKey, strconv.FormatInt(time.Now().Unix(), file.TimestampBase))
 		if err := app.fileRepo.Delete(ctx, f); err != nil {
-			return nil, err
+			return err
 		}
 
-		f.ProtectFields(uid)
+		if err := app.content.Delete(ctx, f); err != nil {
+			app.logger.Error("deleting file content",
+				zap.String("file_id", f.Id()),
+				zap.Error(err))
+		}
+
+		if !f.IsFolder() {
+			app.content.Quotas().Release(ctx, f.Owners(), 0, 1)
+		}
+
+		app.emitFileDeleted(dir.userId, f)
 	}
 
-	if err := app.dirRepo.Save(ctx, dir); err != nil {
-		return nil, err
+	return app.save(ctx, dir, func(dir *Directory) error {
+		for _, trashed := range trash {
+			dir.RemoveTrashed(trashed.file)
+		}
+
+		return nil
+	})
+}
+
+// save applies the given change into the directory and saves it. Since no client tells the revision of the
+// directory it expects, a conflicting save makes the directory be read again and the change be applied once more,
+// up to MaxSaveAttempts times.
+func (app *DirectoryApplication) save(ctx context.Context, dir *Directory, change func(dir *Directory) error) error {
+	for attempt := 1; ; attempt++ {
+		if err := change(dir); err != nil {
+			return err
+		}
+
+		err := app.dirRepo.Save(ctx, dir)
+		if !errors.Is(err, fb.ErrConflict) || attempt >= MaxSaveAttempts {
+			return err
+		}
+
+		app.logger.Warn("retrying directory save",
+			zap.Int32("user_id", dir.userId),
+			zap.Int("attempt", attempt),
+			zap.Error(err))
+
+		reloaded, err := app.dirRepo.FindByUserId(ctx, dir.userId, &RepoOptions{})
+		if err != nil {
+			return err
+		}
+
+		*dir = *reloaded
 	}
+}
 
-	for _, f := range affected.files {
-		f.ProtectFields(uid)
+// emitFileDeleted notifies through the bus, if any, that the given file has been permanently deleted.
+func (app *DirectoryApplication) emitFileDeleted(uid int32, f *file.File) {
+	if app.bus == nil {
+		return
 	}
 
-	return affected, nil
+	if err := app.bus.EmitFileDeleted(uid, f); err != nil {
+		app.logger.Error("emiting file deleted event",
+			zap.String("file_id", f.Id()),
+			zap.Int32("user_id", uid),
+			zap.Error(err))
+	}
+}
+
+// release makes the given owners no longer consume the given file, which they do not own anymore.
+func (app *DirectoryApplication) release(ctx context.Context, f *file.File, owners []int32) {
+	size, err := app.content.Size(ctx, f)
+	if err != nil {
+		app.logger.Warn("getting file size",
+			zap.String("file_id", f.Id()),
+			zap.Error(err))
+
+		return
+	}
+
+	app.content.Quotas().Release(ctx, owners, size, 1)
 }
 
 // Move replaces the destination path to all these file paths in the directory matching any of the given paths.
+// Those permissions the moved files inherit from the folders containing them are recomputed accordingly.
 func (app *DirectoryApplication) Move(ctx context.Context, uid int32, paths []string, dest string) (*Directory, error) {
 	app.logger.Info("processing a directory's \"move\" request",
 		zap.Int32("user_id", uid),
@@ -135,41 +453,461 @@ func (app *DirectoryApplication) Move(ctx context.Context, uid int32, paths []st
 	destDir := path.Dir(dest)
 	absDest := filepath.Join(PathSeparator, dest)
 
+	var affected *Directory
+	var moved map[string]string
+	err = app.save(ctx, dir, func(dir *Directory) error {
+		sources := make(map[string]*file.File)
+		prefixes := make(map[string]string)
+		for _, p := range paths {
+			absP := filepath.Join(PathSeparator, p)
+			for fp, f := range dir.FilesByPath(absP) {
+				absFp := filepath.Join(PathSeparator, fp)
+				sources[absFp] = f
+				prefixes[absFp] = absP
+			}
+		}
+
+		// a path ending with a separator stands for the folder a file is moved into, while any other path is the
+		// new one of the given path, and so, of the files and folders under it
+		roots := make(map[string]string)
+		for _, prefix := range prefixes {
+			if _, exists := roots[prefix]; exists {
+				continue
+			}
+
+			root := absDest
+			if absDest == PathSeparator {
+				// nothing can be renamed as root, so anything is moved into it
+				root = path.Join(absDest, path.Base(prefix))
+			}
+
+			// a folder cannot be merged into another one, since it would be split from its content
+			if folder := dir.FileByPath(prefix); folder != nil && folder.IsFolder() {
+				if other := dir.FileByPath(root); other != nil && other.IsFolder() && other.Id() != folder.Id() {
+					root = dir.getAvailableRoot(root)
+				}
+			}
+
+			roots[prefix] = root
+		}
+
+		affected = NewDirectory(uid)
+		moved = make(map[string]string)
+		for absFp, f := range sources {
+			prefix := prefixes[absFp]
+			finalPath := path.Join(roots[prefix], absFp[len(prefix):])
+			if destDir == absDest && absFp == prefix && !f.IsFolder() {
+				finalPath = path.Join(absDest, path.Base(absFp))
+			}
+
+			dir.RemoveFile(f)
+			finalPath = dir.AddFile(f, finalPath)
+			affected.files[finalPath] = f
+			moved[finalPath] = absFp
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := app.inherit(ctx, dir, affected.files); err != nil {
+		return nil, err
+	}
+
+	affected.revision = dir.revision
+	for fp, f := range affected.files {
+		app.notify(ctx, fb.EventKindMoved, uid, f, moved[fp], fp)
+		f.ProtectFields(uid)
+	}
+
+	return affected, nil
+}
+
+// Copy duplicates all the files located at, or under, any of the given paths into the destination one, which
+// gets renamed if already taken. Each copy is a brand new file owned by the user, having the same content and
+// metadata as the original one.
+func (app *DirectoryApplication) Copy(ctx context.Context, uid int32, paths []string, dest string) (*Directory, error) {
+	app.logger.Info("processing a directory's \"copy\" request",
+		zap.Int32("user_id", uid),
+		zap.Strings("paths", paths),
+		zap.String("destination", dest))
+
+	dir, err := app.dirRepo.FindByUserId(ctx, uid, &RepoOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	absDest := filepath.Join(PathSeparator, dest)
 	affected := NewDirectory(uid)
-	prefixes := make(map[string]string)
+	affected.path = absDest
+
 	for _, p := range paths {
 		absP := filepath.Join(PathSeparator, p)
-		for absFp, f := range dir.FilesByPath(absP) {
-			affected.files[absFp] = f
-			prefixes[absFp] = absP
+
+		// the directory gets modified while copying, so the originals must be selected beforehand
+		originals := make(map[string]*file.File)
+		for fp, f := range dir.FilesByPath(absP) {
+			originals[filepath.Join(PathSeparator, fp)] = f
+		}
+
+		if len(originals) == 0 {
+			continue
+		}
+
+		root := absDest
+		if absDest == PathSeparator {
+			root = path.Join(absDest, path.Base(absP))
+		}
+
+		root = dir.getAvailableRoot(root)
+		for absFp, f := range originals {
+			replica, err := app.copyFile(ctx, uid, f)
+			if err != nil {
+				app.discard(ctx, affected.files)
+				return nil, err
+			}
+
+			fp := dir.AddFile(replica, path.Join(root, strings.TrimPrefix(absFp, absP)))
+			affected.files[fp] = replica
 		}
 	}
 
-	for absFp, f := range affected.files {
-		sufix := absFp[len(prefixes[absFp]):]
-		finalPath := path.Join(absDest, sufix)
-		if destDir == absDest {
-			finalPath = path.Join(absDest, path.Base(absFp))
+	replicas := affected.files
+	err = app.save(ctx, dir, func(dir *Directory) error {
+		// a directory read again lacks the replicas, which get added back where intended
+		affected.files = make(map[string]*file.File, len(replicas))
+		for fp, replica := range replicas {
+			if !dir.hasFile(replica) {
+				fp = dir.AddFile(replica, fp)
+			}
+
+			affected.files[fp] = replica
 		}
 
-		dir.RemoveFile(f)
-		dir.AddFile(f, finalPath)
+		return nil
+	})
 
-		delete(affected.files, absFp)
-		affected.files[finalPath] = f
+	if err != nil {
+		app.discard(ctx, replicas)
+		return nil, err
 	}
 
-	if err := app.dirRepo.Save(ctx, dir); err != nil {
+	if err := app.inherit(ctx, dir, affected.files); err != nil {
 		return nil, err
 	}
 
-	for _, f := range affected.files {
+	affected.revision = dir.revision
+	for fp, f := range affected.files {
+		app.notify(ctx, fb.EventKindCreated, uid, f, fp, "")
 		f.ProtectFields(uid)
 	}
 
 	return affected, nil
 }
 
+// copyFile creates a brand new file owned by the user uid, having the same name, flags, metadata and content as
+// the given one.
+func (app *DirectoryApplication) copyFile(ctx context.Context, uid int32, f *file.File) (*file.File, error) {
+	replica, err := file.NewFile("", f.Name())
+	if err != nil {
+		return nil, err
+	}
+
+	replica.SetFlag(f.Flags())
+	replica.AddPermission(uid, file.Owner)
+	for key, value := range f.Metadata() {
+		if !file.IsReservedMetadata(key) {
+			replica.AddMetadata(key, value)
+		}
+	}
+
+	if f.IsFolder() {
+		// folders consume no storage at all
+		return replica, app.fileRepo.Create(ctx, replica)
+	}
+
+	if err := app.content.Quotas().Reserve(ctx, replica.Owners(), 0, 1); err != nil {
+		return nil, err
+	}
+
+	if err := app.fileRepo.Create(ctx, replica); err != nil {
+		app.content.Quotas().Release(ctx, replica.Owners(), 0, 1)
+		return nil, err
+	}
+
+	if _, err := app.content.Copy(ctx, uid, f, replica); err != nil {
+		app.discard(ctx, map[string]*file.File{f.Name(): replica})
+		return nil, err
+	}
+
+	if err := app.fileRepo.Save(ctx, replica); err != nil {
+		app.discard(ctx, map[string]*file.File{f.Name(): replica})
+		return nil, err
+	}
+
+	return replica, nil
+}
+
+// discard removes the given replicas, together with their content, once copying them has failed. Since the
+// failure is already being reported, any error here is logged but never returned.
+func (app *DirectoryApplication) discard(ctx context.Context, replicas map[string]*file.File) {
+	for _, replica := range replicas {
+		if !replica.IsFolder() {
+			if err := app.content.Delete(ctx, replica); err != nil {
+				app.logger.Warn("deleting content of discarded file",
+					zap.String("file_id", replica.Id()),
+					zap.Error(err))
+			}
+		}
+
+		if err := app.fileRepo.Delete(ctx, replica); err != nil {
+			app.logger.Warn("deleting discarded file",
+				zap.String("file_id", replica.Id()),
+				zap.Error(err))
+		}
+
+		if !replica.IsFolder() {
+			app.content.Quotas().Release(ctx, replica.Owners(), 0, 1)
+		}
+	}
+}
+
+// CreateFolder creates an empty folder at the given path, which keeps existing no matter the files under it.
+func (app *DirectoryApplication) CreateFolder(ctx context.Context, uid int32, p string) (*file.File, error) {
+	app.logger.Info("processing a directory's \"create folder\" request",
+		zap.Int32("user_id", uid),
+		zap.String("path", p))
+
+	absP := filepath.Join(PathSeparator, p)
+	if absP == PathSeparator {
+		return nil, fb.ErrInvalidFormat
+	}
+
+	dir, err := app.dirRepo.FindByUserId(ctx, uid, &RepoOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(dir.FilesByPath(absP)) > 0 {
+		return nil, fb.ErrAlreadyExists
+	}
+
+	folder, err := app.newFolder(ctx, dir, absP)
+	if err != nil {
+		return nil, err
+	}
+
+	app.notify(ctx, fb.EventKindCreated, uid, folder, absP, "")
+	folder.ProtectFields(uid)
+	return folder, nil
+}
+
+// ShareFolder grants the given permission over the folder at the given path to the user grantee, which gets
+// inherited by all those files the user uid owns under it. Folders can only be granted to read and write the
+// files under them, never to own them. Only owners can share a folder.
+func (app *DirectoryApplication) ShareFolder(ctx context.Context, uid int32, p string, grantee int32, perm file.Permission) (*file.File, error) {
+	app.logger.Info("processing a directory's \"share folder\" request",
+		zap.Int32("user_id", uid),
+		zap.String("path", p),
+		zap.Int32("grantee", grantee))
+
+	absP := filepath.Join(PathSeparator, p)
+	if perm&(file.Read|file.Write) == 0 || perm&file.Owner != 0 || absP == PathSeparator {
+		return nil, fb.ErrInvalidFormat
+	}
+
+	dir, err := app.dirRepo.FindByUserId(ctx, uid, &RepoOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	folder, err := app.folder(ctx, dir, absP)
+	if err != nil {
+		return nil, err
+	}
+
+	folder.AddPermission(grantee, perm)
+	if err := app.fileRepo.Save(ctx, folder); err != nil {
+		return nil, err
+	}
+
+	if err := app.inherit(ctx, dir, dir.FilesByPath(absP)); err != nil {
+		return nil, err
+	}
+
+	folder.ProtectFields(uid)
+	return folder, nil
+}
+
+// UnshareFolder revokes the given permission over the folder at the given path from the user grantee, or all of
+// them if none is given, as well as from all those files inheriting it. Only owners can unshare a folder.
+func (app *DirectoryApplication) UnshareFolder(ctx context.Context, uid int32, p string, grantee int32, perm file.Permission) (*file.File, error) {
+	app.logger.Info("processing a directory's \"unshare folder\" request",
+		zap.Int32("user_id", uid),
+		zap.String("path", p),
+		zap.Int32("grantee", grantee))
+
+	absP := filepath.Join(PathSeparator, p)
+	dir, err := app.dirRepo.FindByUserId(ctx, uid, &RepoOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	folder := dir.FileByPath(absP)
+	if folder == nil || !folder.IsFolder() {
+		return nil, fb.ErrNotFound
+	}
+
+	if folder.Permission(uid)&file.Owner == 0 {
+		return nil, fb.ErrNotAvailable
+	}
+
+	if folder.Permission(grantee)&(file.Read|file.Write) == 0 || folder.Permission(grantee)&file.Owner != 0 {
+		return nil, fb.ErrNotFound
+	}
+
+	if perm == 0 {
+		perm = file.Read | file.Write
+	}
+
+	folder.RevokePermission(grantee, perm&(file.Read|file.Write))
+	if err := app.fileRepo.Save(ctx, folder); err != nil {
+		return nil, err
+	}
+
+	if err := app.inherit(ctx, dir, dir.FilesByPath(absP)); err != nil {
+		return nil, err
+	}
+
+	folder.ProtectFields(uid)
+	return folder, nil
+}
+
+// folder returns the folder at the given absolute path of the directory, which is created if there is none yet
+// but any file under it. Only owners can get a folder.
+func (app *DirectoryApplication) folder(ctx context.Context, dir *Directory, absP string) (*file.File, error) {
+	if folder := dir.FileByPath(absP); folder != nil {
+		if !folder.IsFolder() {
+			return nil, fb.ErrInvalidFormat
+		}
+
+		if folder.Permission(dir.userId)&file.Owner == 0 {
+			return nil, fb.ErrNotAvailable
+		}
+
+		return folder, nil
+	}
+
+	if len(dir.FilesByPath(absP)) == 0 {
+		return nil, fb.ErrNotFound
+	}
+
+	return app.newFolder(ctx, dir, absP)
+}
+
+// newFolder creates a folder at the given absolute path of the directory, owned by the directory's user.
+func (app *DirectoryApplication) newFolder(ctx context.Context, dir *Directory, absP string) (*file.File, error) {
+	folder, err := file.NewFile("", path.Base(absP))
+	if err != nil {
+		return nil, err
+	}
+
+	folder.SetFlag(file.Directory)
+	folder.AddPermission(dir.userId, file.Owner)
+	if err := app.fileRepo.Create(ctx, folder); err != nil {
+		return nil, err
+	}
+
+	err = app.save(ctx, dir, func(dir *Directory) error {
+		dir.AddFile(folder, absP)
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return folder, nil
+}
+
+// inherit recomputes the permissions the given files, located at the given paths of the directory, inherit from
+// the folders containing them. Only those files the directory's user owns inherit any permission. Users gaining
+// access to a file get it registered into their own directory, under the shared folder granting it, while those
+// losing it get it unregistered.
+func (app *DirectoryApplication) inherit(ctx context.Context, dir *Directory, files map[string]*file.File) error {
+	folders := dir.Folders()
+	for fp, f := range files {
+		absFp := filepath.Join(PathSeparator, fp)
+		if f.IsFolder() || f.Permission(dir.userId)&file.Owner == 0 {
+			continue
+		}
+
+		before := make(map[int32]bool)
+		for _, grantee := range f.SharedWith() {
+			before[grantee] = true
+		}
+
+		changed := false
+		for folderPath, folder := range folders {
+			if absFp != folderPath && isSubpath(absFp, folderPath) {
+				changed = f.Inherit(folder) || changed
+			} else {
+				changed = f.Disinherit(folder.Id()) || changed
+			}
+		}
+
+		if !changed {
+			continue
+		}
+
+		if err := app.fileRepo.Save(ctx, f); err != nil {
+			return err
+		}
+
+		for _, grantee := range f.SharedWith() {
+			if before[grantee] {
+				delete(before, grantee)
+				continue
+			}
+
+			shared := *f
+			shared.SetDirectory(path.Dir(sharedPath(folders, absFp, grantee)))
+			if _, err := app.RegisterFile(ctx, grantee, &shared); err != nil {
+				return err
+			}
+		}
+
+		for grantee := range before {
+			if f.Permission(grantee) != 0 {
+				continue
+			}
+
+			if err := app.UnregisterFile(ctx, grantee, f); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// sharedPath returns the path where the file at the given absolute path gets registered into the directory of the
+// user grantee, that is under the outermost of the folders containing it granting any permission to the user.
+func sharedPath(folders map[string]*file.File, absFp string, grantee int32) string {
+	outermost := path.Dir(absFp)
+	for folderPath, folder := range folders {
+		if folderPath != absFp && isSubpath(absFp, folderPath) &&
+			folder.Permission(grantee) != 0 && len(folderPath) < len(outermost) {
+			outermost = folderPath
+		}
+	}
+
+	return path.Join(file.SharedDirectory, path.Base(outermost), absFp[len(outermost):])
+}
+
 // Search returns alls these files whose path matches with the given regex
 func (app *DirectoryApplication) Search(ctx context.Context, uid int32, regex string) ([]SearchMatch, error) {
 	app.logger.Info("processing a \"search\" in directory request",
@@ -186,21 +924,62 @@ func (app *DirectoryApplication) Search(ctx context.Context, uid int32, regex st
 
 // RegisterFile registers the given file into the user uid directory. The given path may change if,
 // and only if, another file with the same name exists in the same path.
-func (app *DirectoryApplication) RegisterFile(ctx context.Context, uid int32, file *file.File) (string, error) {
+func (app *DirectoryApplication) RegisterFile(ctx context.Context, uid int32, f *file.File) (string, error) {
 	app.logger.Info("processing a directory's \"register file\" request",
 		zap.Int32("user_id", uid),
-		zap.String("file_id", file.Id()),
-		zap.String("file_name", file.Name()),
-		zap.String("directory", file.Directory()))
+		zap.String("file_id", f.Id()),
+		zap.String("file_name", f.Name()),
+		zap.String("directory", f.Directory()))
 
-	absFp := filepath.Join(PathSeparator, file.Directory(), file.Name())
+	absFp := filepath.Join(PathSeparator, f.Directory(), f.Name())
 	dir, err := app.dirRepo.FindByUserId(ctx, uid, &RepoOptions{})
 	if err != nil {
 		return "", err
 	}
 
-	name := dir.AddFile(file, absFp)
-	return path.Base(name), app.dirRepo.Save(ctx, dir)
+	var fp string
+	err = app.save(ctx, dir, func(dir *Directory) error {
+		fp = dir.AddFile(f, absFp)
+		return nil
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	if err := app.inherit(ctx, dir, map[string]*file.File{fp: f}); err != nil {
+		return "", err
+	}
+
+	return path.Base(fp), nil
+}
+
+// TrashFile moves the given file from the user uid directory into its trash.
+func (app *DirectoryApplication) TrashFile(ctx context.Context, uid int32, f *file.File) error {
+	app.logger.Info("processing a directory's \"trash file\" request",
+		zap.Int32("user_id", uid),
+		zap.String("file_id", f.Id()))
+
+	dir, err := app.dirRepo.FindByUserId(ctx, uid, &RepoOptions{})
+	if err != nil {
+		return err
+	}
+
+	var trashed *TrashedFile
+	err = app.save(ctx, dir, func(dir *Directory) error {
+		if trashed = dir.TrashFile(f); trashed == nil {
+			return fb.ErrNotFound
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	app.notify(ctx, fb.EventKindDeleted, uid, f, trashed.path, "")
+	return nil
 }
 
 // UnregisterFile unregisters the given file from the directory. This action may trigger the file's
@@ -214,6 +993,44 @@ func (app *DirectoryApplication) UnregisterFile(ctx context.Context, uid int32,
 		return err
 	}
 
-	dir.RemoveFile(f)
-	return app.dirRepo.Save(ctx, dir)
+	return app.save(ctx, dir, func(dir *Directory) error {
+		dir.RemoveFile(f)
+		return nil
+	})
+}
+
+// notify publishes the given change made at the path p of the user uid directory, either through the event
+// bus, if any, or straight to the watcher.
+func (app *DirectoryApplication) notify(ctx context.Context, kind string, uid int32, f *file.File, p string, dest string) {
+	if app.bus == nil {
+		app.watcher.Dispatch(ctx, &WatchEvent{
+			kind:        kind,
+			uid:         uid,
+			fileId:      f.Id(),
+			fileName:    f.Name(),
+			path:        p,
+			destination: dest,
+		})
+
+		return
+	}
+
+	var err error
+	switch kind {
+	case fb.EventKindCreated:
+		err = app.bus.EmitPathCreated(uid, f, p)
+	case fb.EventKindDeleted:
+		err = app.bus.EmitPathDeleted(uid, f, p)
+	case fb.EventKindMoved:
+		err = app.bus.EmitPathMoved(uid, f, p, dest)
+	}
+
+	if err != nil {
+		app.logger.Error("emiting directory event",
+			zap.String("kind", kind),
+			zap.String("file_id", f.Id()),
+			zap.Int32("user_id", uid),
+			zap.String("path", p),
+			zap.Error(err))
+	}
 }
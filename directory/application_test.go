@@ -3,6 +3,7 @@ package directory
 import (
 	"context"
 	"errors"
+	"io"
 	"path"
 	"path/filepath"
 	"strconv"
@@ -19,10 +20,12 @@ const (
 )
 
 type directoryRepositoryMock struct {
-	findByUserId func(ctx context.Context, userId int32, opttions *RepoOptions) (*Directory, error)
-	create       func(ctx context.Context, dir *Directory) error
-	save         func(ctx context.Context, dir *Directory) error
-	delete       func(ctx context.Context, dir *Directory) error
+	findByUserId           func(ctx context.Context, userId int32, opttions *RepoOptions) (*Directory, error)
+	findAllByTrashedBefore func(ctx context.Context, deadline time.Time, options *RepoOptions) ([]*Directory, error)
+	findAllByUserIds       func(ctx context.Context, userIds []int32, options *RepoOptions) ([]*Directory, error)
+	create                 func(ctx context.Context, dir *Directory) error
+	save                   func(ctx context.Context, dir *Directory) error
+	delete                 func(ctx context.Context, dir *Directory) error
 }
 
 func (mock *directoryRepositoryMock) FindByUserId(ctx context.Context, userId int32, options *RepoOptions) (*Directory, error) {
@@ -35,6 +38,32 @@ func (mock *directoryRepositoryMock) FindByUserId(ctx context.Context, userId in
 	return dir, nil
 }
 
+func (mock *directoryRepositoryMock) FindAllByTrashedBefore(ctx context.Context, deadline time.Time, options *RepoOptions) ([]*Directory, error) {
+	if mock.findAllByTrashedBefore != nil {
+		return mock.findAllByTrashedBefore(ctx, deadline, options)
+	}
+
+	return []*Directory{}, nil
+}
+
+func (mock *directoryRepositoryMock) FindAllByUserIds(ctx context.Context, userIds []int32, options *RepoOptions) ([]*Directory, error) {
+	if mock.findAllByUserIds != nil {
+		return mock.findAllByUserIds(ctx, userIds, options)
+	}
+
+	dirs := make([]*Directory, 0, len(userIds))
+	for _, uid := range userIds {
+		dir, err := mock.FindByUserId(ctx, uid, options)
+		if err != nil {
+			return nil, err
+		}
+
+		dirs = append(dirs, dir)
+	}
+
+	return dirs, nil
+}
+
 func (mock *directoryRepositoryMock) Create(ctx context.Context, dir *Directory) error {
 	if mock.create != nil {
 		return mock.create(ctx, dir)
@@ -78,7 +107,7 @@ func (mock *fileRepositoryMock) Create(ctx context.Context, file *file.File) err
 	return fb.ErrNotFound
 }
 
-func (mock *fileRepositoryMock) Find(ctx context.Context, id string) (*file.File, error) {
+func (mock *fileRepositoryMock) Find(ctx context.Context, id string, options *file.RepoOptions) (*file.File, error) {
 	if mock.find != nil {
 		return mock.find(mock, ctx, id)
 	}
@@ -110,6 +139,56 @@ func (mock *fileRepositoryMock) Delete(ctx context.Context, file *file.File) err
 	return fb.ErrUnknown
 }
 
+type blobStoreMock struct {
+	delete func(ctx context.Context, key string) error
+}
+
+func (mock *blobStoreMock) Put(ctx context.Context, key string, r io.Reader) (int64, error) {
+	return io.Copy(io.Discard, r)
+}
+
+func (mock *blobStoreMock) Get(ctx context.Context, key string, w io.Writer) error {
+	return fb.ErrNotFound
+}
+
+func (mock *blobStoreMock) Delete(ctx context.Context, key string) error {
+	if mock.delete != nil {
+		return mock.delete(ctx, key)
+	}
+
+	return nil
+}
+
+func (mock *blobStoreMock) Stat(ctx context.Context, key string) (*file.BlobInfo, error) {
+	return nil, fb.ErrNotFound
+}
+
+type versionRepositoryMock struct{}
+
+func (mock *versionRepositoryMock) Create(ctx context.Context, version *file.Version) error {
+	return nil
+}
+
+func (mock *versionRepositoryMock) Find(ctx context.Context, id string) (*file.Version, error) {
+	return nil, fb.ErrNotFound
+}
+
+func (mock *versionRepositoryMock) FindByFileId(ctx context.Context, fid string) ([]*file.Version, error) {
+	return []*file.Version{}, nil
+}
+
+func (mock *versionRepositoryMock) Delete(ctx context.Context, version *file.Version) error {
+	return nil
+}
+
+func (mock *versionRepositoryMock) DeleteByFileId(ctx context.Context, fid string) error {
+	return nil
+}
+
+func newContentStoreMock(blobs file.BlobStore, logger *zap.Logger) *file.ContentStore {
+	return file.NewContentStore(blobs, &versionRepositoryMock{}, 0, logger)
+}
+
 func TestCreateWhenAlreadyExists(t *testing.T) {
 	logger, _ := zap.NewProduction()
 	defer logger.Sync()
@@ -120,7 +199,7 @@ func TestCreateWhenAlreadyExists(t *testing.T) {
 	}
 
 	fileRepo := &fileRepositoryMock{}
-	app := NewDirectoryApplication(dirRepo, fileRepo, logger)
+	app := NewDirectoryApplication(dirRepo, fileRepo, newContentStoreMock(&blobStoreMock{}, logger), logger)
 
 	var want int32 = 999
 	if _, err := app.Create(context.TODO(), want); !errors.Is(err, fb.ErrAlreadyExists) {
@@ -138,7 +217,7 @@ func TestCreate(t *testing.T) {
 	}
 
 	fileRepo := &fileRepositoryMock{}
-	app := NewDirectoryApplication(dirRepo, fileRepo, logger)
+	app := NewDirectoryApplication(dirRepo, fileRepo, newContentStoreMock(&blobStoreMock{}, logger), logger)
 
 	var want int32 = 999
 	dir, err := app.Create(context.TODO(), want)
@@ -165,7 +244,7 @@ func TestGetWhenDirectoryDoesNotExists(t *testing.T) {
 	}
 
 	fileRepo := &fileRepositoryMock{}
-	app := NewDirectoryApplication(dirRepo, fileRepo, logger)
+	app := NewDirectoryApplication(dirRepo, fileRepo, newContentStoreMock(&blobStoreMock{}, logger), logger)
 
 	if _, err := app.Get(context.TODO(), 999, ""); !errors.Is(err, fb.ErrNotFound) {
 		t.Errorf("got error = %v, want = %v", err, fb.ErrNotFound)
@@ -228,7 +307,7 @@ func TestGet(t *testing.T) {
 				},
 			}
 
-			app := NewDirectoryApplication(dirRepo, fileRepo, logger)
+			app := NewDirectoryApplication(dirRepo, fileRepo, newContentStoreMock(&blobStoreMock{}, logger), logger)
 			dir, err := app.Get(context.TODO(), 999, test.path)
 
 			if err != nil {
@@ -262,7 +341,7 @@ func TestDeleteWhenDirectoryDoesNotExists(t *testing.T) {
 	}
 
 	fileRepo := &fileRepositoryMock{}
-	app := NewDirectoryApplication(dirRepo, fileRepo, logger)
+	app := NewDirectoryApplication(dirRepo, fileRepo, newContentStoreMock(&blobStoreMock{}, logger), logger)
 
 	if _, err := app.Delete(context.TODO(), 999, ""); !errors.Is(err, fb.ErrNotFound) {
 		t.Errorf("got error = %v, want = %v", err, fb.ErrNotFound)
@@ -276,46 +355,54 @@ func TestDeleteWhenUserIsSingleOwner(t *testing.T) {
 	f, _ := file.NewFile("test", "filename")
 	f.AddPermission(999, file.Owner)
 
-	dirRepo := &directoryRepositoryMock{
-		delete: func(ctx context.Context, dir *Directory) error {
-			return nil
+	d := &Directory{
+		id:     "test",
+		userId: 999,
+		files: map[string]*file.File{
+			"/path/to/file": f,
 		},
 	}
 
-	dirRepo.findByUserId = func(ctx context.Context, userId int32, options *RepoOptions) (*Directory, error) {
-		return &Directory{
-			id:     "test",
-			userId: 999,
-			files: map[string]*file.File{
-				"/path/to/file": f,
-			},
-		}, nil
+	dirRepo := &directoryRepositoryMock{
+		findByUserId: func(ctx context.Context, userId int32, options *RepoOptions) (*Directory, error) {
+			return d, nil
+		},
 	}
 
 	fileRepo := &fileRepositoryMock{
 		find: func(repo *fileRepositoryMock, ctx context.Context, id string) (*file.File, error) {
 			return f, nil
 		},
+	}
 
-		delete: func(repo *fileRepositoryMock, ctx context.Context, file *file.File) error {
+	blobDeleted := false
+	blobs := &blobStoreMock{
+		delete: func(ctx context.Context, key string) error {
+			blobDeleted = true
 			return nil
 		},
 	}
-	app := NewDirectoryApplication(dirRepo, fileRepo, logger)
 
-	before := time.Now().Unix()
+	app := NewDirectoryApplication(dirRepo, fileRepo, newContentStoreMock(blobs, logger), logger)
+
 	if _, err := app.Delete(context.TODO(), 999, "/path/to/file"); err != nil {
 		t.Errorf("got error = %v, want = %v", err, nil)
 	}
 
-	after := time.Now().Unix()
+	if blobDeleted {
+		t.Errorf("blob store's Delete method did execute")
+	}
+
+	if got, exists := d.files["/path/to/file"]; exists {
+		t.Errorf("got file = %v, want = %v", got, nil)
+	}
 
-	if deletedAt, exists := f.Value(file.MetadataDeletedAtKey); !exists {
-		t.Errorf("got deleted_at = %v, want > %v && < %v", deletedAt, before, after)
-	} else if unixDeletedAt, err := strconv.ParseInt(deletedAt, file.TimestampBase, 64); err != nil {
-		t.Errorf("got error = %v, want = %v", err, nil)
-	} else if unixDeletedAt > before || unixDeletedAt < after {
-		t.Errorf("got deleted_at = %v, want > %v && < %v", unixDeletedAt, before, after)
+	if got := d.TrashByPath("/path/to/file"); len(got) != 1 || got[0].File().Id() != f.Id() {
+		t.Errorf("got trash = %v, want = %v", got, f.Id())
+	}
+
+	if deletedAt, exists := f.Value(file.MetadataDeletedAtKey); exists {
+		t.Errorf("got deleted_at = %v, want = %v", deletedAt, nil)
 	}
 }
 
@@ -348,7 +435,7 @@ func TestDeleteWhenUserIsNotSingleOwner(t *testing.T) {
 			return f, nil
 		},
 	}
-	app := NewDirectoryApplication(dirRepo, fileRepo, logger)
+	app := NewDirectoryApplication(dirRepo, fileRepo, newContentStoreMock(&blobStoreMock{}, logger), logger)
 
 	if _, err := app.Delete(context.TODO(), 999, ""); err != nil {
 		t.Errorf("got error = %v, want = %v", err, nil)
@@ -385,7 +472,7 @@ func TestDeleteWhenUserIsNotOwner(t *testing.T) {
 			return f, nil
 		},
 	}
-	app := NewDirectoryApplication(dirRepo, fileRepo, logger)
+	app := NewDirectoryApplication(dirRepo, fileRepo, newContentStoreMock(&blobStoreMock{}, logger), logger)
 
 	if _, err := app.Delete(context.TODO(), 999, ""); err != nil {
 		t.Errorf("got error = %v, want = %v", err, nil)
@@ -406,7 +493,7 @@ func TestRegisterFileWhenDirectoryDoesNotExists(t *testing.T) {
 	}
 
 	fileRepo := &fileRepositoryMock{}
-	app := NewDirectoryApplication(dirRepo, fileRepo, logger)
+	app := NewDirectoryApplication(dirRepo, fileRepo, newContentStoreMock(&blobStoreMock{}, logger), logger)
 
 	f, _ := file.NewFile("test", "filename")
 	f.SetDirectory("path/to/file")
@@ -428,7 +515,7 @@ func TestRegisterFile(t *testing.T) {
 	}
 
 	fileRepo := &fileRepositoryMock{}
-	app := NewDirectoryApplication(dirRepo, fileRepo, logger)
+	app := NewDirectoryApplication(dirRepo, fileRepo, newContentStoreMock(&blobStoreMock{}, logger), logger)
 
 	f, _ := file.NewFile("test", "filename")
 	f.SetDirectory("path/to")
@@ -444,6 +531,68 @@ func TestRegisterFile(t *testing.T) {
 	}
 }
 
+func TestRegisterFileWhenSaveConflicts(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	concurrent, _ := file.NewFile("concurrent", "other")
+	stored := make(map[string]*file.File)
+	saves := 0
+
+	dirRepo := &directoryRepositoryMock{}
+	dirRepo.findByUserId = func(ctx context.Context, userId int32, options *RepoOptions) (*Directory, error) {
+		d := NewDirectory(userId)
+		for fp, f := range stored {
+			d.files[fp] = f
+		}
+
+		return d, nil
+	}
+
+	dirRepo.save = func(ctx context.Context, dir *Directory) error {
+		if saves++; saves == 1 {
+			// another file gets registered since the directory was read
+			stored["/other"] = concurrent
+			return fb.ErrConflict
+		}
+
+		stored = dir.files
+		return nil
+	}
+
+	app := NewDirectoryApplication(dirRepo, &fileRepositoryMock{}, newContentStoreMock(&blobStoreMock{}, logger), logger)
+
+	f, _ := file.NewFile("test", "filename")
+	if _, err := app.RegisterFile(context.TODO(), 999, f); err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+	}
+
+	if saves != 2 {
+		t.Errorf("got saves = %v, want = %v", saves, 2)
+	}
+
+	for _, fp := range []string{"/other", "/filename"} {
+		if _, exists := stored[fp]; !exists {
+			t.Errorf("got file %v = %v, want = %v", fp, nil, "registered")
+		}
+	}
+
+	// a directory that keeps conflicting is given up on eventually
+	saves = 0
+	dirRepo.save = func(ctx context.Context, dir *Directory) error {
+		saves++
+		return fb.ErrConflict
+	}
+
+	if _, err := app.RegisterFile(context.TODO(), 999, f); !errors.Is(err, fb.ErrConflict) {
+		t.Errorf("got error = %v, want = %v", err, fb.ErrConflict)
+	}
+
+	if saves != MaxSaveAttempts {
+		t.Errorf("got saves = %v, want = %v", saves, MaxSaveAttempts)
+	}
+}
+
 func TestUnregisterFileWhenDirectoryDoesNotExists(t *testing.T) {
 	logger, _ := zap.NewProduction()
 	defer logger.Sync()
@@ -454,7 +603,7 @@ func TestUnregisterFileWhenDirectoryDoesNotExists(t *testing.T) {
 	}
 
 	fileRepo := &fileRepositoryMock{}
-	app := NewDirectoryApplication(dirRepo, fileRepo, logger)
+	app := NewDirectoryApplication(dirRepo, fileRepo, newContentStoreMock(&blobStoreMock{}, logger), logger)
 
 	f, _ := file.NewFile("test", "filename")
 	if err := app.UnregisterFile(context.TODO(), 999, f); !errors.Is(err, fb.ErrNotFound) {
@@ -474,7 +623,7 @@ func TestUnregisterFileWhenUserIsNoOwner(t *testing.T) {
 	}
 
 	fileRepo := &fileRepositoryMock{}
-	app := NewDirectoryApplication(dirRepo, fileRepo, logger)
+	app := NewDirectoryApplication(dirRepo, fileRepo, newContentStoreMock(&blobStoreMock{}, logger), logger)
 
 	f, _ := file.NewFile("test", "filename")
 	f.AddPermission(999, file.Read)
@@ -501,7 +650,7 @@ func TestUnregisterFileWhenFileIsDeleted(t *testing.T) {
 	}
 
 	fileRepo := &fileRepositoryMock{}
-	app := NewDirectoryApplication(dirRepo, fileRepo, logger)
+	app := NewDirectoryApplication(dirRepo, fileRepo, newContentStoreMock(&blobStoreMock{}, logger), logger)
 
 	f, _ := file.NewFile("test", "filename")
 	f.AddMetadata(file.MetadataDeletedAtKey, strconv.FormatInt(time.Now().Unix(), file.TimestampBase))
@@ -538,7 +687,7 @@ func TestUnregisterFileWhenFileIsShared(t *testing.T) {
 	}
 
 	fileRepo := &fileRepositoryMock{}
-	app := NewDirectoryApplication(dirRepo, fileRepo, logger)
+	app := NewDirectoryApplication(dirRepo, fileRepo, newContentStoreMock(&blobStoreMock{}, logger), logger)
 
 	f, _ := file.NewFile("test", "filename")
 	f.AddMetadata(file.MetadataDeletedAtKey, strconv.FormatInt(time.Now().Unix(), file.TimestampBase))
@@ -809,7 +958,7 @@ func TestMove(t *testing.T) {
 				},
 			}
 
-			app := NewDirectoryApplication(dirRepo, fileRepo, logger)
+			app := NewDirectoryApplication(dirRepo, fileRepo, newContentStoreMock(&blobStoreMock{}, logger), logger)
 
 			_, err := app.Move(context.TODO(), 999, test.paths, test.dest)
 			if err != nil {
@@ -828,3 +977,594 @@ func TestMove(t *testing.T) {
 		})
 	}
 }
+
+func TestRestore(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	f, _ := file.NewFile("test", "filename")
+	f.AddPermission(999, file.Owner)
+
+	another, _ := file.NewFile("another", "filename")
+	another.AddPermission(999, file.Owner)
+
+	d := NewDirectory(999)
+	d.AddFile(f, "/path/to/file")
+	d.TrashFile(f)
+	d.AddFile(another, "/path/to/file")
+
+	dirRepo := &directoryRepositoryMock{
+		findByUserId: func(ctx context.Context, userId int32, options *RepoOptions) (*Directory, error) {
+			return d, nil
+		},
+	}
+
+	app := NewDirectoryApplication(dirRepo, &fileRepositoryMock{}, newContentStoreMock(&blobStoreMock{}, logger), logger)
+
+	restored, err := app.Restore(context.TODO(), 999, "/path")
+	if err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+		return
+	}
+
+	want := "/path/to/file_1"
+	if got, exists := restored.files[want]; !exists || got.Id() != f.Id() {
+		t.Errorf("got restored = %v, want = %v", restored.files, want)
+	}
+
+	if got, exists := d.files["/path/to/file"]; !exists || got.Id() != another.Id() {
+		t.Errorf("got file = %v, want = %v", got, another.Id())
+	}
+
+	if got := d.TrashByPath("/"); len(got) != 0 {
+		t.Errorf("got trash len = %v, want = %v", len(got), 0)
+	}
+}
+
+// eventBusMock records the files the application has notified as permanently deleted.
+type eventBusMock struct {
+	deleted []string
+}
+
+func (mock *eventBusMock) EmitPathCreated(uid int32, f *file.File, p string) error {
+	return nil
+}
+
+func (mock *eventBusMock) EmitPathDeleted(uid int32, f *file.File, p string) error {
+	return nil
+}
+
+func (mock *eventBusMock) EmitPathMoved(uid int32, f *file.File, from, to string) error {
+	return nil
+}
+
+func (mock *eventBusMock) EmitFileDeleted(uid int32, f *file.File) error {
+	mock.deleted = append(mock.deleted, f.Id())
+	return nil
+}
+
+func TestEmptyTrashWhenUserIsSingleOwner(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	f, _ := file.NewFile("test", "filename")
+	f.AddPermission(999, file.Owner)
+
+	d := NewDirectory(999)
+	d.AddFile(f, "/path/to/file")
+	d.TrashFile(f)
+
+	dirRepo := &directoryRepositoryMock{
+		findByUserId: func(ctx context.Context, userId int32, options *RepoOptions) (*Directory, error) {
+			return d, nil
+		},
+	}
+
+	fileDeleted := false
+	fileRepo := &fileRepositoryMock{
+		delete: func(repo *fileRepositoryMock, ctx context.Context, file *file.File) error {
+			fileDeleted = true
+			return nil
+		},
+	}
+
+	blobDeleted := false
+	blobs := &blobStoreMock{
+		delete: func(ctx context.Context, key string) error {
+			blobDeleted = key == f.Id()
+			return nil
+		},
+	}
+
+	bus := &eventBusMock{}
+	app := NewDirectoryApplication(dirRepo, fileRepo, newContentStoreMock(blobs, logger), logger)
+	app.SetEventBus(bus)
+
+	if _, err := app.EmptyTrash(context.TODO(), 999, "/"); err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+	}
+
+	if !fileDeleted {
+		t.Errorf("file repository's Delete method did not execute")
+	}
+
+	if len(bus.deleted) != 1 || bus.deleted[0] != f.Id() {
+		t.Errorf("got deleted events = %v, want = %v", bus.deleted, []string{f.Id()})
+	}
+
+	if !blobDeleted {
+		t.Errorf("blob store's Delete method did not execute")
+	}
+
+	if _, exists := f.Value(file.MetadataDeletedAtKey); !exists {
+		t.Errorf("got deleted_at = %v, want = %v", nil, "any")
+	}
+
+	if got := d.TrashByPath("/"); len(got) != 0 {
+		t.Errorf("got trash len = %v, want = %v", len(got), 0)
+	}
+}
+
+func TestEmptyTrashWhenUserIsNotSingleOwner(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	f, _ := file.NewFile("test", "filename")
+	f.AddPermission(999, file.Owner)
+	f.AddPermission(888, file.Owner)
+
+	d := NewDirectory(999)
+	d.AddFile(f, "/path/to/file")
+	d.TrashFile(f)
+
+	dirRepo := &directoryRepositoryMock{
+		findByUserId: func(ctx context.Context, userId int32, options *RepoOptions) (*Directory, error) {
+			return d, nil
+		},
+	}
+
+	fileSaved := false
+	fileRepo := &fileRepositoryMock{
+		save: func(repo *fileRepositoryMock, ctx context.Context, file *file.File) error {
+			fileSaved = true
+			return nil
+		},
+	}
+
+	bus := &eventBusMock{}
+	app := NewDirectoryApplication(dirRepo, fileRepo, newContentStoreMock(&blobStoreMock{}, logger), logger)
+	app.SetEventBus(bus)
+
+	if _, err := app.EmptyTrash(context.TODO(), 999, "/"); err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+	}
+
+	if !fileSaved {
+		t.Errorf("file repository's Save method did not execute")
+	}
+
+	// the file is still alive for its other owner
+	if len(bus.deleted) != 0 {
+		t.Errorf("got deleted events = %v, want = %v", bus.deleted, []string{})
+	}
+
+	if perm := f.Permission(999); perm != 0 {
+		t.Errorf("got permission = %v, want = %v", perm, 0)
+	}
+
+	if perm := f.Permission(888); perm != file.Owner {
+		t.Errorf("got permission = %v, want = %v", perm, file.Owner)
+	}
+}
+
+func TestPurgeTrash(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	old, _ := file.NewFile("old", "filename")
+	old.AddPermission(999, file.Owner)
+
+	recent, _ := file.NewFile("recent", "filename")
+	recent.AddPermission(999, file.Owner)
+
+	d := NewDirectory(999)
+	d.AddFile(old, "/old")
+	d.AddFile(recent, "/recent")
+	d.TrashFile(old).deletedAt = time.Now().Add(-time.Hour)
+	d.TrashFile(recent)
+
+	dirRepo := &directoryRepositoryMock{
+		findAllByTrashedBefore: func(ctx context.Context, deadline time.Time, options *RepoOptions) ([]*Directory, error) {
+			return []*Directory{d}, nil
+		},
+	}
+
+	deleted := make([]string, 0)
+	fileRepo := &fileRepositoryMock{
+		delete: func(repo *fileRepositoryMock, ctx context.Context, file *file.File) error {
+			deleted = append(deleted, file.Id())
+			return nil
+		},
+	}
+
+	bus := &eventBusMock{}
+	app := NewDirectoryApplication(dirRepo, fileRepo, newContentStoreMock(&blobStoreMock{}, logger), logger)
+	app.SetEventBus(bus)
+
+	if err := app.PurgeTrash(context.TODO(), time.Now().Add(-time.Minute)); err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+	}
+
+	if len(deleted) != 1 || deleted[0] != old.Id() {
+		t.Errorf("got deleted = %v, want = %v", deleted, []string{old.Id()})
+	}
+
+	if len(bus.deleted) != 1 || bus.deleted[0] != old.Id() {
+		t.Errorf("got deleted events = %v, want = %v", bus.deleted, []string{old.Id()})
+	}
+
+	if got := d.TrashByPath("/"); len(got) != 1 || got[0].File().Id() != recent.Id() {
+		t.Errorf("got trash = %v, want = %v", got, recent.Id())
+	}
+}
+
+func TestShareFolder(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	owned := make(map[string]*file.File)
+	ownerDir := NewDirectory(111)
+	for index, fp := range []string{"/shared/a_file", "/shared/sub/another_file", "/other/a_file"} {
+		f, _ := file.NewFile(strconv.Itoa(index), path.Base(fp))
+		f.AddPermission(111, file.Owner)
+		ownerDir.AddFile(f, fp)
+		owned[fp] = f
+	}
+
+	granteeDir := NewDirectory(222)
+	dirRepo := &directoryRepositoryMock{
+		findByUserId: func(ctx context.Context, userId int32, options *RepoOptions) (*Directory, error) {
+			if userId == 111 {
+				return ownerDir, nil
+			}
+
+			return granteeDir, nil
+		},
+	}
+
+	fileRepo := &fileRepositoryMock{
+		create: func(repo *fileRepositoryMock, ctx context.Context, f *file.File) error {
+			f.SetID("folder")
+			return nil
+		},
+		save: func(repo *fileRepositoryMock, ctx context.Context, f *file.File) error {
+			return nil
+		},
+	}
+
+	app := NewDirectoryApplication(dirRepo, fileRepo, newContentStoreMock(&blobStoreMock{}, logger), logger)
+
+	if _, err := app.ShareFolder(context.TODO(), 111, "/shared", 222, file.Read|file.Owner); !errors.Is(err, fb.ErrInvalidFormat) {
+		t.Errorf("got error = %v, want = %v", err, fb.ErrInvalidFormat)
+	}
+
+	if _, err := app.ShareFolder(context.TODO(), 111, "/unknown", 222, file.Read); !errors.Is(err, fb.ErrNotFound) {
+		t.Errorf("got error = %v, want = %v", err, fb.ErrNotFound)
+	}
+
+	folder, err := app.ShareFolder(context.TODO(), 111, "/shared", 222, file.Read)
+	if err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+		return
+	}
+
+	if !folder.IsFolder() {
+		t.Errorf("got is folder = %v, want = %v", false, true)
+	}
+
+	if got := ownerDir.FileByPath("/shared"); got == nil || got.Id() != "folder" {
+		t.Errorf("got folder = %v, want = %v", got, "folder")
+	}
+
+	want := map[string]file.Permission{
+		"/shared/a_file":           file.Read,
+		"/shared/sub/another_file": file.Read,
+		"/other/a_file":            0,
+	}
+
+	for fp, perm := range want {
+		if got := owned[fp].Permission(222); got != perm {
+			t.Errorf("%s: got permission = %v, want = %v", fp, got, perm)
+		}
+	}
+
+	sharedPaths := []string{
+		path.Join(file.SharedDirectory, "shared/a_file"),
+		path.Join(file.SharedDirectory, "shared/sub/another_file"),
+	}
+
+	if got := len(granteeDir.files); got != len(sharedPaths) {
+		t.Errorf("got registered files = %v, want = %v", got, len(sharedPaths))
+	}
+
+	for _, fp := range sharedPaths {
+		if _, exists := granteeDir.files[fp]; !exists {
+			t.Errorf("got registered %s = %v, want = %v", fp, false, true)
+		}
+	}
+
+	if _, err := app.Move(context.TODO(), 111, []string{"/shared/a_file"}, "/other/"); err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+		return
+	}
+
+	if got := owned["/shared/a_file"].Permission(222); got != 0 {
+		t.Errorf("got permission = %v, want = %v", got, 0)
+	}
+
+	if got := len(granteeDir.files); got != 1 {
+		t.Errorf("got registered files = %v, want = %v", got, 1)
+	}
+
+	if _, err := app.UnshareFolder(context.TODO(), 111, "/shared", 222, 0); err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+		return
+	}
+
+	if got := owned["/shared/sub/another_file"].Permission(222); got != 0 {
+		t.Errorf("got permission = %v, want = %v", got, 0)
+	}
+
+	if got := len(granteeDir.files); got != 0 {
+		t.Errorf("got registered files = %v, want = %v", got, 0)
+	}
+}
+
+func TestCopy(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	dir := NewDirectory(111)
+	originals := make(map[string]*file.File)
+	for index, fp := range []string{"/docs/a_file", "/docs/sub/b_file", "/other"} {
+		f, _ := file.NewFile(strconv.Itoa(index), path.Base(fp))
+		f.AddPermission(222, file.Owner)
+		f.AddPermission(111, file.Read)
+		f.AddMetadata("key", fp)
+		dir.AddFile(f, fp)
+		originals[fp] = f
+	}
+
+	dirRepo := &directoryRepositoryMock{
+		findByUserId: func(ctx context.Context, userId int32, options *RepoOptions) (*Directory, error) {
+			return dir, nil
+		},
+	}
+
+	created := 0
+	fileRepo := &fileRepositoryMock{
+		create: func(repo *fileRepositoryMock, ctx context.Context, f *file.File) error {
+			created++
+			f.SetID("copy_" + strconv.Itoa(created))
+			return nil
+		},
+		save: func(repo *fileRepositoryMock, ctx context.Context, f *file.File) error {
+			return nil
+		},
+	}
+
+	app := NewDirectoryApplication(dirRepo, fileRepo, newContentStoreMock(&blobStoreMock{}, logger), logger)
+
+	copied, err := app.Copy(context.TODO(), 111, []string{"/docs", "/other"}, "/")
+	if err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+		return
+	}
+
+	want := map[string]string{
+		"/docs_1/a_file":     "/docs/a_file",
+		"/docs_1/sub/b_file": "/docs/sub/b_file",
+		"/other_1":           "/other",
+	}
+
+	if got := len(copied.files); got != len(want) {
+		t.Errorf("got copied files = %v, want = %v", got, len(want))
+	}
+
+	for fp, original := range want {
+		replica := dir.FileByPath(fp)
+		if replica == nil {
+			t.Errorf("%s: got file = %v, want = %v", fp, nil, original)
+			continue
+		}
+
+		if replica.Id() == originals[original].Id() {
+			t.Errorf("%s: got id = %v, want != %v", fp, replica.Id(), originals[original].Id())
+		}
+
+		if got := replica.Permission(111); got != file.Owner {
+			t.Errorf("%s: got permission = %v, want = %v", fp, got, file.Owner)
+		}
+
+		if got := replica.Permission(222); got != 0 {
+			t.Errorf("%s: got permission = %v, want = %v", fp, got, 0)
+		}
+
+		if got, _ := replica.Value("key"); got != original {
+			t.Errorf("%s: got metadata = %v, want = %v", fp, got, original)
+		}
+
+		if dir.FileByPath(original) != originals[original] {
+			t.Errorf("%s: got original moved, want kept", original)
+		}
+	}
+}
+
+func TestCopyWhenAnyFileFails(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	dir := NewDirectory(111)
+	for index, fp := range []string{"/docs/a_file", "/docs/b_file"} {
+		f, _ := file.NewFile(strconv.Itoa(index), path.Base(fp))
+		f.AddPermission(111, file.Owner)
+		dir.AddFile(f, fp)
+	}
+
+	saved := false
+	dirRepo := &directoryRepositoryMock{
+		findByUserId: func(ctx context.Context, userId int32, options *RepoOptions) (*Directory, error) {
+			return dir, nil
+		},
+		save: func(ctx context.Context, dir *Directory) error {
+			saved = true
+			return nil
+		},
+	}
+
+	created := 0
+	deleted := make(map[string]bool)
+	fileRepo := &fileRepositoryMock{
+		create: func(repo *fileRepositoryMock, ctx context.Context, f *file.File) error {
+			created++
+			f.SetID("copy_" + strconv.Itoa(created))
+			return nil
+		},
+		save: func(repo *fileRepositoryMock, ctx context.Context, f *file.File) error {
+			if f.Id() == "copy_2" {
+				return fb.ErrUnknown
+			}
+
+			return nil
+		},
+		delete: func(repo *fileRepositoryMock, ctx context.Context, f *file.File) error {
+			deleted[f.Id()] = true
+			return nil
+		},
+	}
+
+	app := NewDirectoryApplication(dirRepo, fileRepo, newContentStoreMock(&blobStoreMock{}, logger), logger)
+	if _, err := app.Copy(context.TODO(), 111, []string{"/docs"}, "/"); !errors.Is(err, fb.ErrUnknown) {
+		t.Errorf("got error = %v, want = %v", err, fb.ErrUnknown)
+	}
+
+	// the replica that failed, as well as those already created, must not be left behind
+	for _, id := range []string{"copy_1", "copy_2"} {
+		if !deleted[id] {
+			t.Errorf("%s: got deleted = %v, want = %v", id, false, true)
+		}
+	}
+
+	if saved {
+		t.Errorf("directory repository's Save method did execute")
+	}
+}
+
+func TestCreateFolder(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	dir := NewDirectory(111)
+	dirRepo := &directoryRepositoryMock{
+		findByUserId: func(ctx context.Context, userId int32, options *RepoOptions) (*Directory, error) {
+			return dir, nil
+		},
+	}
+
+	fileRepo := &fileRepositoryMock{
+		create: func(repo *fileRepositoryMock, ctx context.Context, f *file.File) error {
+			f.SetID("folder")
+			return nil
+		},
+	}
+
+	app := NewDirectoryApplication(dirRepo, fileRepo, newContentStoreMock(&blobStoreMock{}, logger), logger)
+
+	folder, err := app.CreateFolder(context.TODO(), 111, "/a_folder")
+	if err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+		return
+	}
+
+	if !folder.IsFolder() {
+		t.Errorf("got folder = %v, want = %v", folder.IsFolder(), true)
+	}
+
+	if got := dir.FileByPath("/a_folder"); got != folder {
+		t.Errorf("got file = %v, want = %v", got, folder)
+	}
+
+	if _, err := app.CreateFolder(context.TODO(), 111, "/a_folder"); !errors.Is(err, fb.ErrAlreadyExists) {
+		t.Errorf("got error = %v, want = %v", err, fb.ErrAlreadyExists)
+	}
+
+	if _, err := app.CreateFolder(context.TODO(), 111, "/"); !errors.Is(err, fb.ErrInvalidFormat) {
+		t.Errorf("got error = %v, want = %v", err, fb.ErrInvalidFormat)
+	}
+}
+
+func TestMoveFolder(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	dir := NewDirectory(111)
+	for _, fp := range []string{"/a_folder", "/other/a_folder"} {
+		folder, _ := file.NewFile(fp, path.Base(fp))
+		folder.SetFlag(file.Directory)
+		folder.AddPermission(111, file.Owner)
+		dir.AddFile(folder, fp)
+	}
+
+	f, _ := file.NewFile("file", "a_file")
+	f.AddPermission(111, file.Owner)
+	dir.AddFile(f, "/a_folder/a_file")
+
+	dirRepo := &directoryRepositoryMock{
+		findByUserId: func(ctx context.Context, userId int32, options *RepoOptions) (*Directory, error) {
+			return dir, nil
+		},
+	}
+
+	fileRepo := &fileRepositoryMock{
+		save: func(repo *fileRepositoryMock, ctx context.Context, f *file.File) error {
+			return nil
+		},
+	}
+
+	app := NewDirectoryApplication(dirRepo, fileRepo, newContentStoreMock(&blobStoreMock{}, logger), logger)
+
+	// a folder cannot be merged into another one, but renamed
+	if _, err := app.Move(context.TODO(), 111, []string{"/a_folder"}, "/other/a_folder"); err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+		return
+	}
+
+	want := map[string]string{
+		"/other/a_folder":          "/other/a_folder",
+		"/other/a_folder_1":        "/a_folder",
+		"/other/a_folder_1/a_file": "file",
+	}
+
+	for fp, id := range want {
+		if got := dir.FileByPath(fp); got == nil || got.Id() != id {
+			t.Errorf("%s: got file = %v, want = %v", fp, got, id)
+		}
+	}
+
+	// nothing can be renamed as root, so the folder is moved into it with all its content
+	if _, err := app.Move(context.TODO(), 111, []string{"/other/a_folder_1"}, "/"); err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+		return
+	}
+
+	want = map[string]string{
+		"/a_folder_1":        "/a_folder",
+		"/a_folder_1/a_file": "file",
+	}
+
+	for fp, id := range want {
+		if got := dir.FileByPath(fp); got == nil || got.Id() != id {
+			t.Errorf("%s: got file = %v, want = %v", fp, got, id)
+		}
+	}
+}
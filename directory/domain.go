@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/alvidir/filebrowser/file"
 )
@@ -20,11 +21,32 @@ type SearchMatch struct {
 	end   int
 }
 
+// TrashedFile is a file removed from a directory, which remembers the path it was located at.
+type TrashedFile struct {
+	file      *file.File
+	path      string
+	deletedAt time.Time
+}
+
+func (trashed *TrashedFile) File() *file.File {
+	return trashed.file
+}
+
+func (trashed *TrashedFile) Path() string {
+	return trashed.path
+}
+
+func (trashed *TrashedFile) DeletedAt() time.Time {
+	return trashed.deletedAt
+}
+
 type Directory struct {
-	id     string
-	userId int32
-	files  map[string]*file.File
-	path   string
+	id       string
+	userId   int32
+	files    map[string]*file.File
+	trash    []*TrashedFile
+	path     string
+	revision int64 // amount of times the directory has been saved, for optimistic concurrency control
 }
 
 func NewDirectory(userId int32) *Directory {
@@ -35,6 +57,10 @@ func NewDirectory(userId int32) *Directory {
 	}
 }
 
+func (dir *Directory) Revision() int64 {
+	return dir.revision
+}
+
 func pathComponents(p string) []string {
 	paths := strings.Split(p, PathSeparator)
 
@@ -58,7 +84,8 @@ func (dir *Directory) getAvailablePath(dest string) string {
 
 		for {
 			subject := filepath.Join(components[0 : index+1]...)
-			if _, exists := dir.files[subject]; !exists {
+			if f, exists := dir.files[subject]; !exists || f.IsFolder() && index < len(components)-1 {
+				// a folder does not collide with those files located under it
 				break
 			}
 
@@ -70,6 +97,18 @@ func (dir *Directory) getAvailablePath(dest string) string {
 	return filepath.Join(components...)
 }
 
+// getAvailableRoot returns the given path if there is no file at, or under, it. Otherwise returns the first
+// variant of it, named as getAvailablePath does, having none.
+func (dir *Directory) getAvailableRoot(dest string) string {
+	absDest := filepath.Join(PathSeparator, dest)
+	candidate := absDest
+	for counter := 1; len(dir.FilesByPath(candidate)) > 0; counter++ {
+		candidate = fmt.Sprintf("%s_%d", absDest, counter)
+	}
+
+	return candidate
+}
+
 func (dir *Directory) AddFile(file *file.File, fp string) string {
 	fp = dir.getAvailablePath(fp)
 	file.SetDirectory(path.Dir(fp))
@@ -86,6 +125,13 @@ func (dir *Directory) RemoveFile(file *file.File) {
 	}
 }
 
+// isSubpath returns true if, and only if, the absolute path absFp is, or is located under, the absolute path absP.
+func isSubpath(absFp string, absP string) bool {
+	return absP == PathSeparator ||
+		strings.HasPrefix(absFp, absP) &&
+			(len(absP) == len(absFp) || path.IsAbs(absFp[len(absP):]))
+}
+
 func (dir *Directory) FilesByPath(p string) map[string]*file.File {
 	absP := filepath.Join(PathSeparator, p)
 	if absP == PathSeparator {
@@ -96,8 +142,7 @@ func (dir *Directory) FilesByPath(p string) map[string]*file.File {
 	for fp, f := range dir.files {
 		absFp := filepath.Join(PathSeparator, fp)
 
-		if strings.HasPrefix(absFp, absP) &&
-			(len(absP) == len(absFp) || path.IsAbs(absFp[len(absP):])) {
+		if isSubpath(absFp, absP) {
 			files[absFp] = f
 		}
 
@@ -106,6 +151,96 @@ func (dir *Directory) FilesByPath(p string) map[string]*file.File {
 	return files
 }
 
+// TrashFile moves the given file from the directory into its trash, remembering the path it was located at.
+// Returns nil if the file does not belong to the directory.
+func (dir *Directory) TrashFile(f *file.File) *TrashedFile {
+	for fp, candidate := range dir.files {
+		if candidate.Id() != f.Id() {
+			continue
+		}
+
+		// a file can only be once in the trash, being the last deletion the one that prevails
+		dir.RemoveTrashed(f)
+		delete(dir.files, fp)
+
+		trashed := &TrashedFile{
+			file:      f,
+			path:      filepath.Join(PathSeparator, fp),
+			deletedAt: time.Now(),
+		}
+
+		dir.trash = append(dir.trash, trashed)
+		return trashed
+	}
+
+	return nil
+}
+
+// TrashByPath returns all those trashed files that were located at, or under, the given path.
+func (dir *Directory) TrashByPath(p string) []*TrashedFile {
+	absP := filepath.Join(PathSeparator, p)
+
+	trash := make([]*TrashedFile, 0)
+	for _, trashed := range dir.trash {
+		if isSubpath(trashed.path, absP) {
+			trash = append(trash, trashed)
+		}
+	}
+
+	return trash
+}
+
+// TrashedBefore returns all those trashed files that were deleted before the given deadline.
+func (dir *Directory) TrashedBefore(deadline time.Time) []*TrashedFile {
+	trash := make([]*TrashedFile, 0)
+	for _, trashed := range dir.trash {
+		if trashed.deletedAt.Before(deadline) {
+			trash = append(trash, trashed)
+		}
+	}
+
+	return trash
+}
+
+// RestoreFile moves the given trashed file back to the path it was located at, returning the final one.
+func (dir *Directory) RestoreFile(trashed *TrashedFile) string {
+	dir.RemoveTrashed(trashed.file)
+	return dir.AddFile(trashed.file, trashed.path)
+}
+
+// RemoveTrashed removes the given file from the trash, if there.
+func (dir *Directory) RemoveTrashed(f *file.File) {
+	for index, trashed := range dir.trash {
+		if trashed.file.Id() == f.Id() {
+			dir.trash = append(dir.trash[:index], dir.trash[index+1:]...)
+			return
+		}
+	}
+}
+
+// hasFile returns true if, and only if, the given file belongs to the directory.
+func (dir *Directory) hasFile(f *file.File) bool {
+	for _, candidate := range dir.files {
+		if candidate.Id() == f.Id() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Folders returns all those folders in the directory, by path.
+func (dir *Directory) Folders() map[string]*file.File {
+	folders := make(map[string]*file.File)
+	for fp, f := range dir.files {
+		if f.IsFolder() {
+			folders[fp] = f
+		}
+	}
+
+	return folders
+}
+
 func (dir *Directory) FileByPath(p string) *file.File {
 	absP := filepath.Join(PathSeparator, p)
 	return dir.files[absP]
@@ -115,11 +250,28 @@ func (dir *Directory) AggregateFiles(p string) map[string]*file.File {
 	files := make(map[string]*file.File)
 
 	type FolderAggregate struct {
-		size      int
+		files     int
+		size      int64
 		updatedAt int
 	}
 
 	folders := make(map[string]*FolderAggregate)
+	aggregate := func(folderPath string, files int, size int64, updatedAt int) {
+		if folder, exists := folders[folderPath]; exists {
+			folder.files += files
+			folder.size += size
+
+			if updatedAt > folder.updatedAt {
+				folder.updatedAt = updatedAt
+			}
+		} else {
+			folders[folderPath] = &FolderAggregate{
+				files:     files,
+				size:      size,
+				updatedAt: updatedAt,
+			}
+		}
+	}
 
 	absP := filepath.Join(PathSeparator, p)
 	pCount := strings.Count(p, PathSeparator)
@@ -129,32 +281,38 @@ func (dir *Directory) AggregateFiles(p string) map[string]*file.File {
 	}
 
 	for absFp, f := range dir.FilesByPath(p) {
-		if pCount < strings.Count(absFp, PathSeparator) {
-			updatedAt := 0
-			if sizeStr, exists := f.Metadata()[file.MetadataUpdatedAtKey]; exists {
-				if unix, err := strconv.ParseInt(sizeStr, file.TimestampBase, 64); err == nil {
-					updatedAt = int(unix)
-				}
-			}
+		absFp = filepath.Join(PathSeparator, absFp)
+		if absFp == absP && f.IsFolder() {
+			// a folder is not part of its own content
+			continue
+		}
 
-			// f is located deeper in the directory tree, and so, there is a folder at absP containing it
-			folderPath := filepath.Join(pathComponents(absFp)[0 : pCount+1]...)
-			if folder, exists := folders[folderPath]; exists {
-				folder.size += 1
+		updatedAt := 0
+		if sizeStr, exists := f.Metadata()[file.MetadataUpdatedAtKey]; exists {
+			if unix, err := strconv.ParseInt(sizeStr, file.TimestampBase, 64); err == nil {
+				updatedAt = int(unix)
+			}
+		}
 
-				if updatedAt > folder.updatedAt {
-					folder.updatedAt = updatedAt
-				}
-			} else {
-				folders[folderPath] = &FolderAggregate{
-					size:      1,
-					updatedAt: updatedAt,
-				}
+		if pCount < strings.Count(absFp, PathSeparator) {
+			files, size := 1, int64(0)
+			if f.IsFolder() {
+				// a folder is not a file by itself, but it makes sure its parent exists
+				files = 0
+			} else if sizeStr, exists := f.Metadata()[file.MetadataSizeKey]; exists {
+				size, _ = strconv.ParseInt(sizeStr, 10, 64)
 			}
 
+			// f is located deeper in the directory tree, and so, there is a folder at absP containing it
+			aggregate(filepath.Join(pathComponents(absFp)[0:pCount+1]...), files, size, updatedAt)
 			continue
 		}
 
+		if f.IsFolder() {
+			// an empty folder has no content to aggregate but itself
+			aggregate(absFp, 0, 0, updatedAt)
+		}
+
 		f.MarkAsProtected() // avoid saving changes
 		f.SetDirectory(absP)
 		f.SetName(path.Base(absFp))
@@ -162,14 +320,20 @@ func (dir *Directory) AggregateFiles(p string) map[string]*file.File {
 	}
 
 	for folderPath, aggregate := range folders {
-		folder, err := file.NewFile("", path.Base(folderPath))
-		if err != nil {
-			continue
+		folder, exists := files[folderPath]
+		if !exists || !folder.IsFolder() {
+			// the folder is not in the directory by itself, but synthesised from the files under it
+			var err error
+			if folder, err = file.NewFile("", path.Base(folderPath)); err != nil {
+				continue
+			}
+
+			folder.SetFlag(file.Directory)
+			folder.SetDirectory(path.Dir(folderPath))
 		}
 
-		folder.SetFlag(file.Directory)
-		folder.SetDirectory(path.Dir(folderPath))
-		folder.AddMetadata(file.MetadataSizeKey, strconv.Itoa(aggregate.size))
+		folder.AddMetadata(file.MetadataSizeKey, strconv.FormatInt(aggregate.size, 10))
+		folder.AddMetadata(file.MetadataFilesKey, strconv.Itoa(aggregate.files))
 		folder.AddMetadata(file.MetadataUpdatedAtKey, strconv.FormatInt(int64(aggregate.updatedAt), file.TimestampBase))
 		files[folderPath] = folder
 	}
@@ -222,7 +386,15 @@ func (dir *Directory) Search(search string) []SearchMatch {
 				paths := pathComponents(absFp[len(directory):])
 				name := paths[1] // 1 since pathComponents includes "/" at the beginning
 
-				matchingFile, _ = file.NewFile("", name)
+				if folder := dir.FileByPath(path.Join(directory, name)); folder != nil && folder.IsFolder() {
+					// the folder is in the directory by itself
+					matchingFile = folder
+					matchingFile.MarkAsProtected()
+					matchingFile.SetName(name)
+				} else {
+					matchingFile, _ = file.NewFile("", name)
+				}
+
 				matchingFile.SetDirectory(directory)
 				matchingFile.SetFlag(file.Directory)
 			} else {
@@ -341,3 +341,124 @@ func TestSearchRootpath(t *testing.T) {
 		t.Errorf("got directory, want file")
 	}
 }
+
+func TestTrashFile(t *testing.T) {
+	dir := NewDirectory(999)
+
+	f, _ := file.NewFile("111", "test")
+	dir.AddFile(f, "/path/to/filename")
+
+	other, _ := file.NewFile("222", "test")
+	if got := dir.TrashFile(other); got != nil {
+		t.Errorf("got trashed = %v, want = %v", got, nil)
+	}
+
+	trashed := dir.TrashFile(f)
+	if trashed == nil {
+		t.Errorf("got trashed = %v, want = %v", trashed, f.Id())
+		return
+	}
+
+	if got := trashed.Path(); got != "/path/to/filename" {
+		t.Errorf("got path = %v, want = %v", got, "/path/to/filename")
+	}
+
+	if got, exists := dir.files["/path/to/filename"]; exists {
+		t.Errorf("got file = %v, want = %v", got, nil)
+	}
+
+	if got := dir.TrashByPath("/path"); len(got) != 1 {
+		t.Errorf("got trash len = %v, want = %v", len(got), 1)
+	}
+
+	if got := dir.TrashByPath("/path/to/file"); len(got) != 0 {
+		t.Errorf("got trash len = %v, want = %v", len(got), 0)
+	}
+
+	dir.AddFile(f, "/another/path")
+	dir.TrashFile(f)
+
+	if got := dir.TrashByPath("/"); len(got) != 1 || got[0].Path() != "/another/path" {
+		t.Errorf("got trash = %v, want = %v", got, "/another/path")
+	}
+}
+
+func TestRestoreFile(t *testing.T) {
+	dir := NewDirectory(999)
+
+	f, _ := file.NewFile("111", "test")
+	dir.AddFile(f, "/path/to/filename")
+	trashed := dir.TrashFile(f)
+
+	if got := dir.RestoreFile(trashed); got != "/path/to/filename" {
+		t.Errorf("got final path = %v, want = %v", got, "/path/to/filename")
+	}
+
+	if got := dir.TrashByPath("/"); len(got) != 0 {
+		t.Errorf("got trash len = %v, want = %v", len(got), 0)
+	}
+
+	if got := dir.FileByPath("/path/to/filename"); got == nil || got.Id() != f.Id() {
+		t.Errorf("got file = %v, want = %v", got, f.Id())
+	}
+}
+
+func TestAddFileUnderFolder(t *testing.T) {
+	dir := NewDirectory(999)
+
+	folder, _ := file.NewFile("folder", "a_folder")
+	folder.SetFlag(file.Directory)
+	dir.AddFile(folder, "/a_folder")
+
+	f, _ := file.NewFile("file", "a_file")
+	f.AddMetadata(file.MetadataSizeKey, "5")
+	if got := dir.AddFile(f, "/a_folder/a_file"); got != "/a_folder/a_file" {
+		t.Errorf("got path = %v, want = %v", got, "/a_folder/a_file")
+	}
+
+	another, _ := file.NewFile("another", "a_folder")
+	if got := dir.AddFile(another, "/a_folder"); got != "/a_folder_1" {
+		t.Errorf("got path = %v, want = %v", got, "/a_folder_1")
+	}
+
+	files := dir.AggregateFiles("/")
+	if got, exists := files["/a_folder"]; !exists || got.Id() != "folder" {
+		t.Errorf("got folder = %v, want = %v", got, "folder")
+	} else if size, _ := got.Value(file.MetadataSizeKey); size != "5" {
+		t.Errorf("got size = %v, want = %v", size, "5")
+	} else if files, _ := got.Value(file.MetadataFilesKey); files != "1" {
+		t.Errorf("got files = %v, want = %v", files, "1")
+	}
+}
+
+func TestAggregateEmptyFolder(t *testing.T) {
+	dir := NewDirectory(999)
+
+	folder, _ := file.NewFile("folder", "a_folder")
+	folder.SetFlag(file.Directory)
+	folder.AddMetadata(file.MetadataUpdatedAtKey, "1")
+	dir.AddFile(folder, "/a_folder")
+
+	files := dir.AggregateFiles("/")
+	if got, exists := files["/a_folder"]; !exists || got.Id() != "folder" {
+		t.Errorf("got folder = %v, want = %v", got, "folder")
+	} else if size, _ := got.Value(file.MetadataSizeKey); size != "0" {
+		t.Errorf("got size = %v, want = %v", size, "0")
+	} else if files, _ := got.Value(file.MetadataFilesKey); files != "0" {
+		t.Errorf("got files = %v, want = %v", files, "0")
+	} else if updatedAt, _ := got.Value(file.MetadataUpdatedAtKey); updatedAt != "1" {
+		t.Errorf("got updatedAt = %v, want = %v", updatedAt, "1")
+	}
+
+	// a folder is not part of its own content
+	if got := len(dir.AggregateFiles("/a_folder")); got != 0 {
+		t.Errorf("got %v items, want = %v", got, 0)
+	}
+
+	results := dir.Search("a_folder")
+	if got := len(results); got != 1 {
+		t.Errorf("got %v results, want = %v", got, 1)
+	} else if results[0].file.Id() != "folder" {
+		t.Errorf("got result = %v, want = %v", results[0].file.Id(), "folder")
+	}
+}
@@ -57,9 +57,10 @@ func NewProtoSearchResponse(matches []SearchMatch) *proto.SearchResponse {
 
 func NewProtoDirectory(dir *Directory) *proto.Directory {
 	protoDir := &proto.Directory{
-		Id:    dir.id,
-		Files: make([]*proto.File, 0, len(dir.files)),
-		Path:  NewProtoPath(dir.path),
+		Id:       dir.id,
+		Files:    make([]*proto.File, 0, len(dir.files)),
+		Path:     NewProtoPath(dir.path),
+		Revision: dir.revision,
 	}
 
 	for _, fs := range dir.files {
@@ -69,6 +70,41 @@ func NewProtoDirectory(dir *Directory) *proto.Directory {
 	return protoDir
 }
 
+func NewProtoTrash(trash []*TrashedFile) *proto.Trash {
+	protoTrash := &proto.Trash{
+		Files: make([]*proto.TrashedFile, 0, len(trash)),
+	}
+
+	for _, trashed := range trash {
+		protoTrash.Files = append(protoTrash.Files, &proto.TrashedFile{
+			File:      file.NewProtoFile(trashed.file),
+			Path:      NewProtoPath(trashed.path),
+			DeletedAt: trashed.deletedAt.Unix(),
+		})
+	}
+
+	return protoTrash
+}
+
+var protoWatchEventKinds = map[string]proto.WatchEvent_Kind{
+	fb.EventKindCreated: proto.WatchEvent_CREATED,
+	fb.EventKindUpdated: proto.WatchEvent_UPDATED,
+	fb.EventKindMoved:   proto.WatchEvent_MOVED,
+	fb.EventKindDeleted: proto.WatchEvent_DELETED,
+}
+
+func NewProtoWatchEvent(event *WatchEvent) *proto.WatchEvent {
+	return &proto.WatchEvent{
+		Kind: protoWatchEventKinds[event.kind],
+		File: &proto.File{
+			Id:   event.fileId,
+			Name: event.fileName,
+		},
+		Path:        NewProtoPath(event.path),
+		Destination: NewProtoPath(event.destination),
+	}
+}
+
 func (server *DirectoryGrpcService) Get(ctx context.Context, path *proto.Path) (*proto.Directory, error) {
 	uid, err := fb.GetUidFromGrpcCtx(ctx, server.uidHeader, server.logger)
 	if err != nil {
@@ -117,6 +153,26 @@ func (server *DirectoryGrpcService) Move(ctx context.Context, req *proto.MoveReq
 	return NewProtoDirectory(dir), nil
 }
 
+func (server *DirectoryGrpcService) Copy(ctx context.Context, req *proto.CopyRequest) (*proto.Directory, error) {
+	uid, err := fb.GetUidFromGrpcCtx(ctx, server.uidHeader, server.logger)
+	if err != nil {
+		return nil, err
+	}
+
+	protoPaths := req.GetPaths()
+	paths := make([]string, 0, len(protoPaths))
+	for _, pp := range protoPaths {
+		paths = append(paths, pp.GetAbsolute())
+	}
+
+	dir, err := server.app.Copy(ctx, uid, paths, req.GetDestination().GetAbsolute())
+	if err != nil {
+		return nil, err
+	}
+
+	return NewProtoDirectory(dir), nil
+}
+
 func (server *DirectoryGrpcService) Search(ctx context.Context, req *proto.SearchRequest) (*proto.SearchResponse, error) {
 	uid, err := fb.GetUidFromGrpcCtx(ctx, server.uidHeader, server.logger)
 	if err != nil {
@@ -130,3 +186,110 @@ func (server *DirectoryGrpcService) Search(ctx context.Context, req *proto.Searc
 
 	return NewProtoSearchResponse(search), nil
 }
+
+func (server *DirectoryGrpcService) ListTrash(ctx context.Context, path *proto.Path) (*proto.Trash, error) {
+	uid, err := fb.GetUidFromGrpcCtx(ctx, server.uidHeader, server.logger)
+	if err != nil {
+		return nil, err
+	}
+
+	trash, err := server.app.ListTrash(ctx, uid, path.GetAbsolute())
+	if err != nil {
+		return nil, err
+	}
+
+	return NewProtoTrash(trash), nil
+}
+
+func (server *DirectoryGrpcService) Restore(ctx context.Context, path *proto.Path) (*proto.Directory, error) {
+	uid, err := fb.GetUidFromGrpcCtx(ctx, server.uidHeader, server.logger)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := server.app.Restore(ctx, uid, path.GetAbsolute())
+	if err != nil {
+		return nil, err
+	}
+
+	return NewProtoDirectory(dir), nil
+}
+
+func (server *DirectoryGrpcService) EmptyTrash(ctx context.Context, path *proto.Path) (*proto.Trash, error) {
+	uid, err := fb.GetUidFromGrpcCtx(ctx, server.uidHeader, server.logger)
+	if err != nil {
+		return nil, err
+	}
+
+	trash, err := server.app.EmptyTrash(ctx, uid, path.GetAbsolute())
+	if err != nil {
+		return nil, err
+	}
+
+	return NewProtoTrash(trash), nil
+}
+
+func (server *DirectoryGrpcService) CreateFolder(ctx context.Context, path *proto.Path) (*proto.File, error) {
+	uid, err := fb.GetUidFromGrpcCtx(ctx, server.uidHeader, server.logger)
+	if err != nil {
+		return nil, err
+	}
+
+	folder, err := server.app.CreateFolder(ctx, uid, path.GetAbsolute())
+	if err != nil {
+		return nil, err
+	}
+
+	return file.NewProtoFile(folder), nil
+}
+
+func (server *DirectoryGrpcService) ShareFolder(ctx context.Context, req *proto.FolderShareRequest) (*proto.File, error) {
+	uid, err := fb.GetUidFromGrpcCtx(ctx, server.uidHeader, server.logger)
+	if err != nil {
+		return nil, err
+	}
+
+	perms := req.GetPermissions()
+	folder, err := server.app.ShareFolder(ctx, uid, req.GetPath().GetAbsolute(), perms.GetUserId(), file.NewPermission(perms))
+	if err != nil {
+		return nil, err
+	}
+
+	return file.NewProtoFile(folder), nil
+}
+
+func (server *DirectoryGrpcService) UnshareFolder(ctx context.Context, req *proto.FolderShareRequest) (*proto.File, error) {
+	uid, err := fb.GetUidFromGrpcCtx(ctx, server.uidHeader, server.logger)
+	if err != nil {
+		return nil, err
+	}
+
+	perms := req.GetPermissions()
+	folder, err := server.app.UnshareFolder(ctx, uid, req.GetPath().GetAbsolute(), perms.GetUserId(), file.NewPermission(perms))
+	if err != nil {
+		return nil, err
+	}
+
+	return file.NewProtoFile(folder), nil
+}
+
+func (server *DirectoryGrpcService) Watch(path *proto.Path, stream proto.DirectoryService_WatchServer) error {
+	ctx := stream.Context()
+	uid, err := fb.GetUidFromGrpcCtx(ctx, server.uidHeader, server.logger)
+	if err != nil {
+		return err
+	}
+
+	events, err := server.app.Watch(ctx, uid, path.GetAbsolute())
+	if err != nil {
+		return err
+	}
+
+	for event := range events {
+		if err := stream.Send(NewProtoWatchEvent(event)); err != nil {
+			return err
+		}
+	}
+
+	return ctx.Err()
+}
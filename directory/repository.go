@@ -2,7 +2,9 @@ package directory
 
 import (
 	"context"
+	"errors"
 	"path"
+	"time"
 
 	fb "github.com/alvidir/filebrowser"
 	"github.com/alvidir/filebrowser/file"
@@ -16,10 +18,19 @@ const (
 	mongoDirectoryCollectionName = "directories"
 )
 
+type mongoTrashedFile struct {
+	Path      string             `bson:"path"`
+	FileID    primitive.ObjectID `bson:"file_id"`
+	DeletedAt time.Time          `bson:"deleted_at"`
+}
+
 type mongoDirectory struct {
-	ID     primitive.ObjectID            `bson:"_id,omitempty"`
-	UserID int32                         `bson:"user_id"`
-	Files  map[string]primitive.ObjectID `bson:"files"`
+	ID       primitive.ObjectID            `bson:"_id,omitempty"`
+	UserID   int32                         `bson:"user_id"`
+	Files    map[string]primitive.ObjectID `bson:"files"`
+	Folders  []string                      `bson:"folders,omitempty"`
+	Trash    []mongoTrashedFile            `bson:"trash,omitempty"`
+	Revision int64                         `bson:"revision"`
 }
 
 func newMongoDirectory(dir *Directory) (*mongoDirectory, error) {
@@ -33,9 +44,10 @@ func newMongoDirectory(dir *Directory) (*mongoDirectory, error) {
 	}
 
 	mongoDir := &mongoDirectory{
-		ID:     oid,
-		UserID: dir.userId,
-		Files:  make(map[string]primitive.ObjectID),
+		ID:       oid,
+		UserID:   dir.userId,
+		Files:    make(map[string]primitive.ObjectID),
+		Revision: dir.revision,
 	}
 
 	for fpath, f := range dir.files {
@@ -45,6 +57,23 @@ func newMongoDirectory(dir *Directory) (*mongoDirectory, error) {
 		}
 
 		mongoDir.Files[fpath] = oid
+		if f.IsFolder() {
+			// folders are told apart even when lazy loaded, since they have no content to be looked up for
+			mongoDir.Folders = append(mongoDir.Folders, fpath)
+		}
+	}
+
+	for _, trashed := range dir.trash {
+		oid, err := primitive.ObjectIDFromHex(trashed.file.Id())
+		if err != nil {
+			return nil, err
+		}
+
+		mongoDir.Trash = append(mongoDir.Trash, mongoTrashedFile{
+			Path:      trashed.path,
+			FileID:    oid,
+			DeletedAt: trashed.deletedAt,
+		})
 	}
 
 	return mongoDir, nil
@@ -67,7 +96,9 @@ func NewMongoDirectoryRepository(db *mongo.Database, fileRepo file.FileRepositor
 func (repo *MongoDirectoryRepository) FindByUserId(ctx context.Context, userId int32, options *RepoOptions) (*Directory, error) {
 	var mdir mongoDirectory
 	err := repo.conn.FindOne(ctx, bson.M{"user_id": userId}).Decode(&mdir)
-	if err != nil {
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, fb.ErrNotFound
+	} else if err != nil {
 		repo.logger.Error("performing find by user id on mongo",
 			zap.Int32("user_id", userId),
 			zap.Error(err))
@@ -78,6 +109,57 @@ func (repo *MongoDirectoryRepository) FindByUserId(ctx context.Context, userId i
 	return repo.build(ctx, &mdir, options)
 }
 
+// FindAllByTrashedBefore returns all those directories having any file in their trash deleted before the given deadline.
+func (repo *MongoDirectoryRepository) FindAllByTrashedBefore(ctx context.Context, deadline time.Time, options *RepoOptions) ([]*Directory, error) {
+	cursor, err := repo.conn.Find(ctx, bson.M{"trash.deleted_at": bson.M{"$lt": deadline}})
+	if err != nil {
+		repo.logger.Error("performing find by trashed before on mongo",
+			zap.Time("deadline", deadline),
+			zap.Error(err))
+
+		return nil, fb.ErrUnknown
+	}
+
+	return repo.buildAll(ctx, cursor, options)
+}
+
+// FindAllByUserIds returns the directories of all the given users, skipping those having none.
+func (repo *MongoDirectoryRepository) FindAllByUserIds(ctx context.Context, userIds []int32, options *RepoOptions) ([]*Directory, error) {
+	cursor, err := repo.conn.Find(ctx, bson.M{"user_id": bson.M{"$in": userIds}})
+	if err != nil {
+		repo.logger.Error("performing find by user ids on mongo",
+			zap.Int32s("user_ids", userIds),
+			zap.Error(err))
+
+		return nil, fb.ErrUnknown
+	}
+
+	return repo.buildAll(ctx, cursor, options)
+}
+
+// buildAll returns the directories of all the documents the given cursor iterates over.
+func (repo *MongoDirectoryRepository) buildAll(ctx context.Context, cursor *mongo.Cursor, options *RepoOptions) ([]*Directory, error) {
+	var mdirs []mongoDirectory
+	if err := cursor.All(ctx, &mdirs); err != nil {
+		repo.logger.Error("decoding directories from mongo",
+			zap.Error(err))
+
+		return nil, fb.ErrUnknown
+	}
+
+	dirs := make([]*Directory, 0, len(mdirs))
+	for index := range mdirs {
+		dir, err := repo.build(ctx, &mdirs[index], options)
+		if err != nil {
+			return nil, err
+		}
+
+		dirs = append(dirs, dir)
+	}
+
+	return dirs, nil
+}
+
 func (repo *MongoDirectoryRepository) Create(ctx context.Context, dir *Directory) error {
 	mdir, err := newMongoDirectory(dir)
 	if err != nil {
@@ -120,7 +202,9 @@ func (repo *MongoDirectoryRepository) Save(ctx context.Context, dir *Directory)
 		return fb.ErrUnknown
 	}
 
-	if _, err = repo.conn.ReplaceOne(ctx, bson.M{"_id": mdir.ID}, mdir); err != nil {
+	mdir.Revision = dir.revision + 1
+	result, err := repo.conn.ReplaceOne(ctx, fb.NewMongoRevisionFilter(mdir.ID, dir.revision), mdir)
+	if err != nil {
 		repo.logger.Error("performing replace one on mongo",
 			zap.Int32("user_id", dir.userId),
 			zap.Error(err))
@@ -128,6 +212,17 @@ func (repo *MongoDirectoryRepository) Save(ctx context.Context, dir *Directory)
 		return fb.ErrUnknown
 	}
 
+	if result.MatchedCount == 0 {
+		// the directory has been either modified or deleted since read
+		repo.logger.Warn("performing replace one on mongo",
+			zap.String("directory_id", dir.id),
+			zap.Int64("revision", dir.revision),
+			zap.Error(fb.ErrConflict))
+
+		return fb.ErrConflict
+	}
+
+	dir.revision = mdir.Revision
 	return nil
 }
 
@@ -164,23 +259,44 @@ func (repo *MongoDirectoryRepository) Delete(ctx context.Context, dir *Directory
 
 func (repo *MongoDirectoryRepository) build(ctx context.Context, mdir *mongoDirectory, options *RepoOptions) (*Directory, error) {
 	dir := &Directory{
-		id:     mdir.ID.Hex(),
-		userId: mdir.UserID,
-		files:  make(map[string]*file.File),
+		id:       mdir.ID.Hex(),
+		userId:   mdir.UserID,
+		files:    make(map[string]*file.File),
+		revision: mdir.Revision,
 	}
 
 	if options == nil || options.LazyLoading {
+		folders := make(map[string]struct{}, len(mdir.Folders))
+		for _, fpath := range mdir.Folders {
+			folders[fpath] = struct{}{}
+		}
+
 		for fpath, oid := range mdir.Files {
 			f, _ := file.NewFile(oid.Hex(), path.Base(fpath))
+			if _, exists := folders[fpath]; exists {
+				f.SetFlag(file.Directory)
+			}
+
 			dir.files[fpath] = f
 		}
+
+		for _, trashed := range mdir.Trash {
+			f, _ := file.NewFile(trashed.FileID.Hex(), path.Base(trashed.Path))
+			dir.trash = append(dir.trash, &TrashedFile{
+				file:      f,
+				path:      trashed.Path,
+				deletedAt: trashed.DeletedAt,
+			})
+		}
 	} else {
 
-		filesIds := make([]string, 0, len(mdir.Files))
-		pathByFileId := make(map[string]string)
-		for fpath, oid := range mdir.Files {
+		filesIds := make([]string, 0, len(mdir.Files)+len(mdir.Trash))
+		for _, oid := range mdir.Files {
 			filesIds = append(filesIds, oid.Hex())
-			pathByFileId[oid.Hex()] = fpath
+		}
+
+		for _, trashed := range mdir.Trash {
+			filesIds = append(filesIds, trashed.FileID.Hex())
 		}
 
 		files, err := repo.fileRepo.FindAll(ctx, filesIds)
@@ -188,12 +304,30 @@ func (repo *MongoDirectoryRepository) build(ctx context.Context, mdir *mongoDire
 			return nil, err
 		}
 
+		fileById := make(map[string]*file.File)
 		for _, f := range files {
 			if f == nil {
 				continue
 			}
 
-			dir.files[pathByFileId[f.Id()]] = f
+			fileById[f.Id()] = f
+		}
+
+		for fpath, oid := range mdir.Files {
+			if f, exists := fileById[oid.Hex()]; exists {
+				dir.files[fpath] = f
+			}
+		}
+
+		for _, trashed := range mdir.Trash {
+			// a file may be no longer available since trashed, and so it is just dropped
+			if f, exists := fileById[trashed.FileID.Hex()]; exists {
+				dir.trash = append(dir.trash, &TrashedFile{
+					file:      f,
+					path:      trashed.Path,
+					deletedAt: trashed.DeletedAt,
+				})
+			}
 		}
 	}
 
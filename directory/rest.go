@@ -0,0 +1,227 @@
+package directory
+
+import (
+	"net/http"
+	"strings"
+
+	fb "github.com/alvidir/filebrowser"
+	"github.com/alvidir/filebrowser/proto"
+	"go.uber.org/zap"
+)
+
+const (
+	DirectoryPathPrefix = "/directory/"
+	MovePath            = "/move"
+	SearchPath          = "/search"
+	SearchQueryParam    = "q"
+)
+
+// DirectoryRestService exposes the directory application through plain HTTP, where:
+//
+//	GET    /directory/:path  lists the files located at the given path
+//	DELETE /directory/:path  moves all the files at, or under, the given path into the trash
+//	POST   /move             moves the given paths into the destination one
+//	GET    /search?q=:regex  returns all the files whose path matches the given regex
+type DirectoryRestService struct {
+	app       *DirectoryApplication
+	handler   *http.ServeMux
+	logger    *zap.Logger
+	uidHeader string
+}
+
+func NewDirectoryRestServer(app *DirectoryApplication, logger *zap.Logger, authHeader string) *DirectoryRestService {
+	server := &DirectoryRestService{
+		app:       app,
+		handler:   http.NewServeMux(),
+		logger:    logger,
+		uidHeader: authHeader,
+	}
+
+	server.handler.HandleFunc(DirectoryPathPrefix, server.directoryHandler)
+	server.handler.HandleFunc(MovePath, server.moveHandler)
+	server.handler.HandleFunc(SearchPath, server.searchHandler)
+	return server
+}
+
+func (server *DirectoryRestService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	server.handler.ServeHTTP(w, r)
+}
+
+func (server *DirectoryRestService) directoryHandler(w http.ResponseWriter, r *http.Request) {
+	uid, err := fb.GetUidFromHttpRequest(r, server.uidHeader, server.logger)
+	if err != nil {
+		fb.HttpError(w, err)
+		return
+	}
+
+	// the prefix keeps its trailing separator, so the path is absolute
+	p := strings.TrimPrefix(r.URL.Path, DirectoryPathPrefix[:len(DirectoryPathPrefix)-1])
+
+	var dir *Directory
+	switch r.Method {
+	case http.MethodGet:
+		dir, err = server.app.Get(r.Context(), uid, p)
+	case http.MethodDelete:
+		dir, err = server.app.Delete(r.Context(), uid, p)
+	default:
+		w.Header().Set("Allow", strings.Join([]string{http.MethodGet, http.MethodDelete}, ", "))
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err != nil {
+		fb.HttpError(w, err)
+		return
+	}
+
+	fb.WriteJson(w, http.StatusOK, NewProtoDirectory(dir), server.logger)
+}
+
+func (server *DirectoryRestService) moveHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	uid, err := fb.GetUidFromHttpRequest(r, server.uidHeader, server.logger)
+	if err != nil {
+		fb.HttpError(w, err)
+		return
+	}
+
+	var req proto.MoveRequest
+	if err := fb.ReadJson(r, &req); err != nil {
+		fb.HttpError(w, err)
+		return
+	}
+
+	paths := make([]string, 0, len(req.GetPaths()))
+	for _, pp := range req.GetPaths() {
+		paths = append(paths, pp.GetAbsolute())
+	}
+
+	dir, err := server.app.Move(r.Context(), uid, paths, req.GetDestination().GetAbsolute())
+	if err != nil {
+		fb.HttpError(w, err)
+		return
+	}
+
+	fb.WriteJson(w, http.StatusOK, NewProtoDirectory(dir), server.logger)
+}
+
+func (server *DirectoryRestService) searchHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	uid, err := fb.GetUidFromHttpRequest(r, server.uidHeader, server.logger)
+	if err != nil {
+		fb.HttpError(w, err)
+		return
+	}
+
+	search := r.URL.Query().Get(SearchQueryParam)
+	if len(search) == 0 {
+		fb.HttpError(w, fb.ErrInvalidFormat)
+		return
+	}
+
+	matches, err := server.app.Search(r.Context(), uid, search)
+	if err != nil {
+		fb.HttpError(w, err)
+		return
+	}
+
+	fb.WriteJson(w, http.StatusOK, NewProtoSearchResponse(matches), server.logger)
+}
+
+// RegisterRoutes describes into the given registry all the routes served by the service.
+func (server *DirectoryRestService) RegisterRoutes(registry *fb.RestRegistry) {
+	registry.AddSchema("Path", &fb.Schema{
+		Type: "object",
+		Properties: map[string]*fb.Schema{
+			"absolute": {Type: "string"},
+		},
+	})
+
+	registry.AddSchema("Directory", &fb.Schema{
+		Type: "object",
+		Properties: map[string]*fb.Schema{
+			"id":       {Type: "string"},
+			"path":     fb.SchemaRef("Path"),
+			"files":    {Type: "array", Items: fb.SchemaRef("File")},
+			"revision": {Type: "string", Format: "int64"},
+		},
+	})
+
+	registry.AddSchema("MoveRequest", &fb.Schema{
+		Type:     "object",
+		Required: []string{"paths", "destination"},
+		Properties: map[string]*fb.Schema{
+			"paths":       {Type: "array", Items: fb.SchemaRef("Path")},
+			"destination": fb.SchemaRef("Path"),
+		},
+	})
+
+	registry.AddSchema("SearchResponse", &fb.Schema{
+		Type: "object",
+		Properties: map[string]*fb.Schema{
+			"matches": {
+				Type: "array",
+				Items: &fb.Schema{
+					Type: "object",
+					Properties: map[string]*fb.Schema{
+						"file":       fb.SchemaRef("File"),
+						"matchStart": {Type: "integer", Format: "int32"},
+						"matchEnd":   {Type: "integer", Format: "int32"},
+					},
+				},
+			},
+		},
+	})
+
+	path := fb.Parameter{Name: "path", In: "path", Required: true, Description: "The path in the directory, may contain slashes", Schema: &fb.Schema{Type: "string"}}
+	directory := fb.Response{Description: "The directory", ContentType: fb.JsonContentType, Schema: fb.SchemaRef("Directory")}
+
+	registry.Register(
+		fb.Route{
+			Method:     http.MethodGet,
+			Path:       DirectoryPathPrefix + "{path...}",
+			Summary:    "Lists the files located at the given path",
+			Parameters: []fb.Parameter{path},
+			Responses:  map[int]fb.Response{http.StatusOK: directory},
+			Errors:     []error{fb.ErrNotFound},
+		},
+		fb.Route{
+			Method:     http.MethodDelete,
+			Path:       DirectoryPathPrefix + "{path...}",
+			Summary:    "Moves all the files at, or under, the given path into the trash",
+			Parameters: []fb.Parameter{path},
+			Responses:  map[int]fb.Response{http.StatusOK: directory},
+			Errors:     []error{fb.ErrNotFound},
+		},
+		fb.Route{
+			Method:    http.MethodPost,
+			Path:      MovePath,
+			Summary:   "Moves the given paths into the destination one",
+			Request:   fb.SchemaRef("MoveRequest"),
+			Responses: map[int]fb.Response{http.StatusOK: directory},
+			Errors:    []error{fb.ErrNotFound, fb.ErrInvalidFormat},
+		},
+		fb.Route{
+			Method:  http.MethodGet,
+			Path:    SearchPath,
+			Summary: "Returns all the files whose path matches the given regex",
+			Parameters: []fb.Parameter{
+				{Name: SearchQueryParam, In: "query", Required: true, Schema: &fb.Schema{Type: "string"}},
+			},
+			Responses: map[int]fb.Response{
+				http.StatusOK: {Description: "The matching files", ContentType: fb.JsonContentType, Schema: fb.SchemaRef("SearchResponse")},
+			},
+			Errors: []error{fb.ErrNotFound, fb.ErrInvalidFormat},
+		},
+	)
+}
@@ -0,0 +1,237 @@
+package directory
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"sync"
+
+	fb "github.com/alvidir/filebrowser"
+	"github.com/alvidir/filebrowser/file"
+	"go.uber.org/zap"
+)
+
+const (
+	// WatchBufferSize is the amount of events a watcher may have pending before any further one gets dropped.
+	WatchBufferSize = 64
+)
+
+// watchEventKinds relates the kind of each file event concerning a directory with the one of its watch event.
+var watchEventKinds = map[string]string{
+	fb.EventKindPathCreated: fb.EventKindCreated,
+	fb.EventKindPathMoved:   fb.EventKindMoved,
+	fb.EventKindPathDeleted: fb.EventKindDeleted,
+	fb.EventKindUpdated:     fb.EventKindUpdated,
+}
+
+// WatchEvent is a change made at some path of the directory of a user.
+type WatchEvent struct {
+	kind        string
+	uid         int32
+	fileId      string
+	fileName    string
+	path        string
+	destination string
+}
+
+func (event *WatchEvent) Kind() string {
+	return event.kind
+}
+
+func (event *WatchEvent) FileId() string {
+	return event.fileId
+}
+
+func (event *WatchEvent) FileName() string {
+	return event.fileName
+}
+
+func (event *WatchEvent) Path() string {
+	return event.path
+}
+
+func (event *WatchEvent) Destination() string {
+	return event.destination
+}
+
+// concerns returns true if, and only if, the event has changed anything at, or under, the given absolute path.
+func (event *WatchEvent) concerns(absP string) bool {
+	return isSubpath(event.path, absP) ||
+		len(event.destination) > 0 && isSubpath(event.destination, absP)
+}
+
+type watchSubscription struct {
+	uid    int32
+	path   string
+	events chan *WatchEvent
+}
+
+// DirectoryWatcher dispatches the changes made into any directory to all those subscribed to it.
+type DirectoryWatcher struct {
+	dirRepo       DirectoryRepository
+	mu            sync.RWMutex
+	subscriptions map[*watchSubscription]struct{}
+	logger        *zap.Logger
+}
+
+func NewDirectoryWatcher(dirRepo DirectoryRepository, logger *zap.Logger) *DirectoryWatcher {
+	return &DirectoryWatcher{
+		dirRepo:       dirRepo,
+		subscriptions: make(map[*watchSubscription]struct{}),
+		logger:        logger,
+	}
+}
+
+// subscribe registers a new subscription to all the changes made at, or under, the given absolute path of the
+// directory of the user uid.
+func (watcher *DirectoryWatcher) subscribe(uid int32, absP string) *watchSubscription {
+	sub := &watchSubscription{
+		uid:    uid,
+		path:   absP,
+		events: make(chan *WatchEvent, WatchBufferSize),
+	}
+
+	watcher.mu.Lock()
+	defer watcher.mu.Unlock()
+
+	watcher.subscriptions[sub] = struct{}{}
+	return sub
+}
+
+// unsubscribe removes the given subscription, whose channel gets closed.
+func (watcher *DirectoryWatcher) unsubscribe(sub *watchSubscription) {
+	watcher.mu.Lock()
+	defer watcher.mu.Unlock()
+
+	if _, exists := watcher.subscriptions[sub]; exists {
+		delete(watcher.subscriptions, sub)
+		close(sub.events)
+	}
+}
+
+// Dispatch delivers the given event to all those subscriptions it concerns. An event with no path, as the
+// update of a file is, concerns all those users having the file in their directory, at the path they have it.
+func (watcher *DirectoryWatcher) Dispatch(ctx context.Context, event *WatchEvent) {
+	watcher.mu.RLock()
+	subs := make([]*watchSubscription, 0, len(watcher.subscriptions))
+	for sub := range watcher.subscriptions {
+		subs = append(subs, sub)
+	}
+
+	watcher.mu.RUnlock()
+
+	// the paths the file has in each directory, in case the event has none by itself
+	var paths map[int32][]string
+	if len(event.path) == 0 {
+		uids := make([]int32, 0, len(subs))
+		seen := make(map[int32]bool)
+		for _, sub := range subs {
+			if !seen[sub.uid] {
+				seen[sub.uid] = true
+				uids = append(uids, sub.uid)
+			}
+		}
+
+		paths = watcher.lookup(ctx, uids, event.fileId)
+	}
+
+	deliveries := make(map[*watchSubscription][]*WatchEvent)
+	for _, sub := range subs {
+		if len(event.path) > 0 {
+			if event.uid == sub.uid && event.concerns(sub.path) {
+				deliveries[sub] = append(deliveries[sub], event)
+			}
+
+			continue
+		}
+
+		for _, fp := range paths[sub.uid] {
+			located := *event
+			located.uid = sub.uid
+			located.path = fp
+			if located.concerns(sub.path) {
+				deliveries[sub] = append(deliveries[sub], &located)
+			}
+		}
+	}
+
+	watcher.mu.RLock()
+	defer watcher.mu.RUnlock()
+
+	for sub, events := range deliveries {
+		if _, exists := watcher.subscriptions[sub]; !exists {
+			// the subscription has been closed in the meanwhile
+			continue
+		}
+
+		for _, event := range events {
+			watcher.send(sub, event)
+		}
+	}
+}
+
+// lookup returns, for each of the given users, all the paths the file with the given id is located at in their
+// directory. All the directories are retrieved at once, no matter how many users are there.
+func (watcher *DirectoryWatcher) lookup(ctx context.Context, uids []int32, fid string) map[int32][]string {
+	if len(uids) == 0 {
+		return nil
+	}
+
+	dirs, err := watcher.dirRepo.FindAllByUserIds(ctx, uids, &RepoOptions{LazyLoading: true})
+	if err != nil {
+		watcher.logger.Error("finding directories by user ids",
+			zap.Int32s("user_ids", uids),
+			zap.Error(err))
+
+		return nil
+	}
+
+	paths := make(map[int32][]string)
+	for _, dir := range dirs {
+		for fp, f := range dir.files {
+			if f.Id() == fid {
+				paths[dir.userId] = append(paths[dir.userId], filepath.Join(PathSeparator, fp))
+			}
+		}
+	}
+
+	return paths
+}
+
+func (watcher *DirectoryWatcher) send(sub *watchSubscription, event *WatchEvent) {
+	select {
+	case sub.events <- event:
+	default:
+		watcher.logger.Warn("dropping watch event",
+			zap.Int32("user_id", sub.uid),
+			zap.String("path", event.path),
+			zap.String("kind", event.kind))
+	}
+}
+
+// OnEvent dispatches the directory changes carried by the given file event, as emitted through the FileEventBus.
+func (watcher *DirectoryWatcher) OnEvent(ctx context.Context, body []byte) {
+	payload := new(file.FileEventPayload)
+	if err := json.Unmarshal(body, payload); err != nil {
+		watcher.logger.Error("unmarshaling file event body",
+			zap.ByteString("event_body", body),
+			zap.Error(err))
+
+		return
+	}
+
+	kind, exists := watchEventKinds[payload.Kind]
+	if !exists {
+		// any other event, as those from external applications, does not concern any path of a directory
+		return
+	}
+
+	watcher.Dispatch(ctx, &WatchEvent{
+		kind:        kind,
+		uid:         payload.UserID,
+		fileId:      payload.FileID,
+		fileName:    payload.FileName,
+		path:        payload.Path,
+		destination: payload.Destination,
+	})
+}
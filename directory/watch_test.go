@@ -0,0 +1,198 @@
+package directory
+
+import (
+	"context"
+	"encoding/json"
+	"path"
+	"strconv"
+	"testing"
+	"time"
+
+	fb "github.com/alvidir/filebrowser"
+	"github.com/alvidir/filebrowser/file"
+	"go.uber.org/zap"
+)
+
+func receiveWatchEvent(t *testing.T, events <-chan *WatchEvent) *WatchEvent {
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(time.Second):
+		t.Errorf("got event = %v, want = %v", nil, "any")
+		return nil
+	}
+}
+
+func TestWatch(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	dir := NewDirectory(111)
+	for index, fp := range []string{"/a_file", "/docs/b_file"} {
+		f, _ := file.NewFile(strconv.Itoa(index), path.Base(fp))
+		f.AddPermission(111, file.Owner)
+		dir.AddFile(f, fp)
+	}
+
+	dirRepo := &directoryRepositoryMock{
+		findByUserId: func(ctx context.Context, userId int32, options *RepoOptions) (*Directory, error) {
+			return dir, nil
+		},
+	}
+
+	fileRepo := &fileRepositoryMock{
+		save: func(repo *fileRepositoryMock, ctx context.Context, f *file.File) error {
+			return nil
+		},
+	}
+
+	app := NewDirectoryApplication(dirRepo, fileRepo, newContentStoreMock(&blobStoreMock{}, logger), logger)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := app.Watch(ctx, 111, "/docs")
+	if err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+		return
+	}
+
+	if _, err := app.Move(context.TODO(), 111, []string{"/a_file"}, "/docs/a_file"); err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+		return
+	}
+
+	if event := receiveWatchEvent(t, events); event != nil {
+		if event.Kind() != fb.EventKindMoved {
+			t.Errorf("got kind = %v, want = %v", event.Kind(), fb.EventKindMoved)
+		}
+
+		if event.Path() != "/a_file" {
+			t.Errorf("got path = %v, want = %v", event.Path(), "/a_file")
+		}
+
+		if event.Destination() != "/docs/a_file" {
+			t.Errorf("got destination = %v, want = %v", event.Destination(), "/docs/a_file")
+		}
+	}
+
+	// changes out of the watched path, or made by any other user, are not delivered
+	app.Watcher().Dispatch(context.TODO(), &WatchEvent{kind: fb.EventKindCreated, uid: 111, path: "/other"})
+	app.Watcher().Dispatch(context.TODO(), &WatchEvent{kind: fb.EventKindCreated, uid: 222, path: "/docs/other"})
+
+	body, _ := json.Marshal(file.FileEventPayload{
+		UserID: 222,
+		FileID: "1",
+		Kind:   fb.EventKindUpdated,
+	})
+
+	// an update has no path by itself, but the one the file has in the watched directory
+	app.Watcher().OnEvent(context.TODO(), body)
+	if event := receiveWatchEvent(t, events); event != nil {
+		if event.Kind() != fb.EventKindUpdated {
+			t.Errorf("got kind = %v, want = %v", event.Kind(), fb.EventKindUpdated)
+		}
+
+		if event.Path() != "/docs/b_file" {
+			t.Errorf("got path = %v, want = %v", event.Path(), "/docs/b_file")
+		}
+	}
+
+	if _, err := app.Delete(context.TODO(), 111, "/docs/b_file"); err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+		return
+	}
+
+	if event := receiveWatchEvent(t, events); event != nil && event.Kind() != fb.EventKindDeleted {
+		t.Errorf("got kind = %v, want = %v", event.Kind(), fb.EventKindDeleted)
+	}
+
+	cancel()
+	select {
+	case event, open := <-events:
+		if open {
+			t.Errorf("got event = %v, want = %v", event, nil)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("got closed = %v, want = %v", false, true)
+	}
+}
+
+func TestWatchOnPathEvent(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	watcher := NewDirectoryWatcher(&directoryRepositoryMock{}, logger)
+	sub := watcher.subscribe(111, "/docs")
+	defer watcher.unsubscribe(sub)
+
+	// a file created by any other application concerns no path, even if carrying one
+	body, _ := json.Marshal(file.FileEventPayload{
+		UserID: 111,
+		FileID: "1",
+		Path:   "/docs/a_file",
+		Kind:   fb.EventKindCreated,
+	})
+
+	watcher.OnEvent(context.TODO(), body)
+
+	body, _ = json.Marshal(file.FileEventPayload{
+		UserID: 111,
+		FileID: "2",
+		Path:   "/docs/b_file",
+		Kind:   fb.EventKindPathCreated,
+	})
+
+	watcher.OnEvent(context.TODO(), body)
+	if event := receiveWatchEvent(t, sub.events); event != nil {
+		if event.Kind() != fb.EventKindCreated {
+			t.Errorf("got kind = %v, want = %v", event.Kind(), fb.EventKindCreated)
+		}
+
+		if event.FileId() != "2" {
+			t.Errorf("got file id = %v, want = %v", event.FileId(), "2")
+		}
+	}
+}
+
+func TestWatchLooksUpPathsOncePerEvent(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	f, _ := file.NewFile("1", "a_file")
+	lookups := 0
+	dirRepo := &directoryRepositoryMock{
+		findAllByUserIds: func(ctx context.Context, userIds []int32, options *RepoOptions) ([]*Directory, error) {
+			lookups++
+
+			dirs := make([]*Directory, 0, len(userIds))
+			for _, uid := range userIds {
+				dir := NewDirectory(uid)
+				dir.AddFile(f, "/docs/a_file")
+				dirs = append(dirs, dir)
+			}
+
+			return dirs, nil
+		},
+	}
+
+	watcher := NewDirectoryWatcher(dirRepo, logger)
+	subs := []*watchSubscription{
+		watcher.subscribe(111, "/docs"),
+		watcher.subscribe(111, "/"),
+		watcher.subscribe(222, "/docs"),
+	}
+
+	watcher.Dispatch(context.TODO(), &WatchEvent{kind: fb.EventKindUpdated, uid: 111, fileId: f.Id()})
+	if lookups != 1 {
+		t.Errorf("got lookups = %v, want = %v", lookups, 1)
+	}
+
+	for _, sub := range subs {
+		if event := receiveWatchEvent(t, sub.events); event != nil && event.Path() != "/docs/a_file" {
+			t.Errorf("got path = %v, want = %v", event.Path(), "/docs/a_file")
+		}
+
+		watcher.unsubscribe(sub)
+	}
+}
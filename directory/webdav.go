@@ -0,0 +1,525 @@
+package directory
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	fb "github.com/alvidir/filebrowser"
+	"github.com/alvidir/filebrowser/file"
+	"go.uber.org/zap"
+	"golang.org/x/net/webdav"
+)
+
+type webdavUidContextKey struct{}
+
+// DirectoryWebdavService serves the directory of each user through WebDAV, so it can be mounted by any file
+// manager. Every request is served on behalf of the user it has been authenticated for.
+type DirectoryWebdavService struct {
+	handler   *webdav.Handler
+	logger    *zap.Logger
+	uidHeader string
+}
+
+func NewDirectoryWebdavServer(dirApp *DirectoryApplication, fileApp *file.FileApplication, prefix string, logger *zap.Logger, authHeader string) *DirectoryWebdavService {
+	server := &DirectoryWebdavService{
+		logger:    logger,
+		uidHeader: authHeader,
+	}
+
+	server.handler = &webdav.Handler{
+		Prefix: prefix,
+		FileSystem: &webdavFileSystem{
+			dirApp:  dirApp,
+			fileApp: fileApp,
+			logger:  logger,
+		},
+		LockSystem: webdav.NewMemLS(),
+		Logger: func(r *http.Request, err error) {
+			if err != nil {
+				logger.Warn("serving webdav request",
+					zap.String("method", r.Method),
+					zap.String("path", r.URL.Path),
+					zap.Error(err))
+			}
+		},
+	}
+
+	return server
+}
+
+func (server *DirectoryWebdavService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	uid, err := fb.GetUidFromHttpRequest(r, server.uidHeader, server.logger)
+	if err != nil {
+		fb.HttpError(w, err)
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), webdavUidContextKey{}, uid)
+	server.handler.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// webdavError returns the os error the file system is expected to return for the given one.
+func webdavError(err error) error {
+	switch {
+	case errors.Is(err, fb.ErrNotFound):
+		return os.ErrNotExist
+	case errors.Is(err, fb.ErrNotAvailable):
+		return os.ErrPermission
+	case errors.Is(err, fb.ErrAlreadyExists):
+		return os.ErrExist
+	}
+
+	return err
+}
+
+// webdavFileInfo describes a file or folder of a directory as an os.FileInfo.
+type webdavFileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	dir     bool
+}
+
+func newWebdavFileInfo(f *file.File) *webdavFileInfo {
+	info := &webdavFileInfo{
+		name: f.Name(),
+		dir:  f.IsFolder(),
+	}
+
+	if value, exists := f.Value(file.MetadataUpdatedAtKey); exists {
+		if unix, err := strconv.ParseInt(value, file.TimestampBase, 64); err == nil {
+			info.modTime = time.Unix(unix, 0)
+		}
+	}
+
+	if value, exists := f.Value(file.MetadataSizeKey); exists && !info.dir {
+		info.size, _ = strconv.ParseInt(value, 10, 64)
+	}
+
+	return info
+}
+
+func (info *webdavFileInfo) Name() string {
+	return info.name
+}
+
+func (info *webdavFileInfo) Size() int64 {
+	return info.size
+}
+
+func (info *webdavFileInfo) Mode() fs.FileMode {
+	if info.dir {
+		return fs.ModeDir | 0755
+	}
+
+	return 0644
+}
+
+func (info *webdavFileInfo) ModTime() time.Time {
+	return info.modTime
+}
+
+func (info *webdavFileInfo) IsDir() bool {
+	return info.dir
+}
+
+func (info *webdavFileInfo) Sys() interface{} {
+	return nil
+}
+
+// webdavFileSystem maps the operations of WebDAV onto the directory and file applications.
+type webdavFileSystem struct {
+	dirApp  *DirectoryApplication
+	fileApp *file.FileApplication
+	logger  *zap.Logger
+}
+
+func (wfs *webdavFileSystem) uid(ctx context.Context) (int32, error) {
+	uid, exists := ctx.Value(webdavUidContextKey{}).(int32)
+	if !exists {
+		return 0, os.ErrPermission
+	}
+
+	return uid, nil
+}
+
+// list returns all the files and folders located right at the given absolute path, by absolute path.
+func (wfs *webdavFileSystem) list(ctx context.Context, uid int32, absP string) (map[string]*file.File, error) {
+	dir, err := wfs.dirApp.Get(ctx, uid, absP)
+	if err != nil {
+		return nil, webdavError(err)
+	}
+
+	// the folder at the given path, if any, is not part of its own content
+	delete(dir.files, absP)
+	return dir.files, nil
+}
+
+// lookup returns the file or folder located at the given absolute path, if any. Root has no file at all.
+func (wfs *webdavFileSystem) lookup(ctx context.Context, uid int32, absP string) (*file.File, error) {
+	f, err := wfs.dirApp.Lookup(ctx, uid, absP)
+	if err != nil {
+		return nil, webdavError(err)
+	}
+
+	return f, nil
+}
+
+func (wfs *webdavFileSystem) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	uid, err := wfs.uid(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	absP := filepath.Join(PathSeparator, name)
+	if absP == PathSeparator {
+		return &webdavFileInfo{name: PathSeparator, dir: true}, nil
+	}
+
+	f, err := wfs.lookup(ctx, uid, absP)
+	if err != nil {
+		return nil, err
+	}
+
+	return newWebdavFileInfo(f), nil
+}
+
+func (wfs *webdavFileSystem) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	uid, err := wfs.uid(ctx)
+	if err != nil {
+		return err
+	}
+
+	absP := filepath.Join(PathSeparator, name)
+	if parent := path.Dir(absP); parent != PathSeparator {
+		if info, err := wfs.Stat(ctx, parent); err != nil {
+			return err
+		} else if !info.IsDir() {
+			return os.ErrNotExist
+		}
+	}
+
+	_, err = wfs.dirApp.CreateFolder(ctx, uid, absP)
+	return webdavError(err)
+}
+
+func (wfs *webdavFileSystem) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	uid, err := wfs.uid(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	absP := filepath.Join(PathSeparator, name)
+	handle := &webdavFile{
+		ctx:      ctx,
+		uid:      uid,
+		path:     absP,
+		fs:       wfs,
+		writable: flag&(os.O_WRONLY|os.O_RDWR) != 0,
+	}
+
+	if absP == PathSeparator {
+		handle.info = &webdavFileInfo{name: PathSeparator, dir: true}
+		return handle, nil
+	}
+
+	f, err := wfs.lookup(ctx, uid, absP)
+	if errors.Is(err, os.ErrNotExist) && flag&os.O_CREATE != 0 {
+		// the file is created once closed, with all the data written into it
+		handle.info = &webdavFileInfo{name: path.Base(absP), modTime: time.Now()}
+		handle.dirty = true
+		return handle, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if flag&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL {
+		return nil, os.ErrExist
+	}
+
+	handle.file = f
+	handle.info = newWebdavFileInfo(f)
+	if f.IsFolder() {
+		if handle.writable {
+			return nil, os.ErrPermission
+		}
+
+		return handle, nil
+	}
+
+	// the content is truncated once closed, even if nothing gets written into it
+	handle.dirty = flag&os.O_TRUNC != 0
+	return handle, nil
+}
+
+func (wfs *webdavFileSystem) RemoveAll(ctx context.Context, name string) error {
+	uid, err := wfs.uid(ctx)
+	if err != nil {
+		return err
+	}
+
+	absP := filepath.Join(PathSeparator, name)
+	if absP == PathSeparator {
+		return os.ErrPermission
+	}
+
+	dir, err := wfs.dirApp.Delete(ctx, uid, absP)
+	if err != nil {
+		return webdavError(err)
+	}
+
+	if len(dir.files) == 0 {
+		return os.ErrNotExist
+	}
+
+	return nil
+}
+
+func (wfs *webdavFileSystem) Rename(ctx context.Context, oldName, newName string) error {
+	uid, err := wfs.uid(ctx)
+	if err != nil {
+		return err
+	}
+
+	oldAbsP := filepath.Join(PathSeparator, oldName)
+	newAbsP := filepath.Join(PathSeparator, newName)
+	if oldAbsP == PathSeparator || newAbsP == PathSeparator || isSubpath(newAbsP, oldAbsP) {
+		return os.ErrPermission
+	}
+
+	dir, err := wfs.dirApp.Move(ctx, uid, []string{oldAbsP}, newAbsP)
+	if err != nil {
+		return webdavError(err)
+	}
+
+	if len(dir.files) == 0 {
+		return os.ErrNotExist
+	}
+
+	return nil
+}
+
+// webdavFile is an open file or folder of a directory. The content of a file is never kept in memory: it is
+// streamed from the file application as read, and into it as written, replacing the former one once closed.
+type webdavFile struct {
+	ctx      context.Context
+	uid      int32
+	path     string
+	fs       *webdavFileSystem
+	file     *file.File
+	info     *webdavFileInfo
+	offset   int64
+	writable bool
+	dirty    bool
+	children []fs.FileInfo
+
+	// reader streams the content of the file, already read up to readOffset
+	reader     *io.PipeReader
+	readOffset int64
+
+	// writer streams all the data written into the file, whose upload result is sent through uploaded
+	writer   *io.PipeWriter
+	uploaded chan error
+}
+
+func (handle *webdavFile) Read(p []byte) (int, error) {
+	if handle.info.dir {
+		return 0, os.ErrInvalid
+	}
+
+	if handle.file == nil || handle.dirty {
+		// the content being written, or about to be truncated, cannot be read back
+		return 0, io.EOF
+	}
+
+	if err := handle.download(); err != nil {
+		return 0, err
+	}
+
+	n, err := handle.reader.Read(p)
+	handle.offset += int64(n)
+	handle.readOffset = handle.offset
+	return n, err
+}
+
+// download makes sure the content of the file is being streamed from the current offset, restarting the stream
+// if the offset is behind it.
+func (handle *webdavFile) download() error {
+	if handle.reader != nil && handle.readOffset > handle.offset {
+		handle.reader.Close()
+		handle.reader = nil
+	}
+
+	if handle.reader == nil {
+		r, w := io.Pipe()
+		go func(fid string) {
+			_, err := handle.fs.fileApp.Download(handle.ctx, handle.uid, fid, w)
+			w.CloseWithError(webdavError(err))
+		}(handle.file.Id())
+
+		handle.reader = r
+		handle.readOffset = 0
+	}
+
+	if skip := handle.offset - handle.readOffset; skip > 0 {
+		n, err := io.CopyN(io.Discard, handle.reader, skip)
+		handle.readOffset += n
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (handle *webdavFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += handle.offset
+	case io.SeekEnd:
+		offset += handle.info.size
+	default:
+		return 0, os.ErrInvalid
+	}
+
+	if offset < 0 {
+		return 0, os.ErrInvalid
+	}
+
+	handle.offset = offset
+	return offset, nil
+}
+
+func (handle *webdavFile) Write(p []byte) (int, error) {
+	if handle.info.dir || !handle.writable {
+		return 0, os.ErrPermission
+	}
+
+	if handle.writer == nil {
+		if handle.offset != 0 {
+			// the content is replaced as a whole, from its very beginning
+			return 0, os.ErrInvalid
+		}
+
+		handle.upload()
+	} else if handle.offset != handle.info.size {
+		// the content is streamed as written, so it can only be appended
+		return 0, os.ErrInvalid
+	}
+
+	n, err := handle.writer.Write(p)
+	handle.offset += int64(n)
+	handle.info.size = handle.offset
+	handle.dirty = true
+	if err != nil {
+		return n, webdavError(err)
+	}
+
+	return n, nil
+}
+
+// upload starts streaming into the file all the data written from now on, which becomes its content once closed.
+func (handle *webdavFile) upload() {
+	options := &file.UploadOptions{
+		Name:      path.Base(handle.path),
+		Directory: path.Dir(handle.path),
+	}
+
+	if handle.file != nil {
+		options = &file.UploadOptions{
+			Id: handle.file.Id(),
+		}
+	}
+
+	if handle.reader != nil {
+		handle.reader.Close()
+		handle.reader = nil
+	}
+
+	r, w := io.Pipe()
+	handle.writer = w
+	handle.uploaded = make(chan error, 1)
+	handle.info.size = 0
+
+	go func() {
+		_, err := handle.fs.fileApp.Upload(handle.ctx, handle.uid, options, r)
+		// any further write must fail once the upload is over
+		r.CloseWithError(err)
+		handle.uploaded <- err
+	}()
+}
+
+func (handle *webdavFile) Readdir(count int) ([]fs.FileInfo, error) {
+	if !handle.info.dir {
+		return nil, os.ErrInvalid
+	}
+
+	if handle.children == nil {
+		files, err := handle.fs.list(handle.ctx, handle.uid, handle.path)
+		if err != nil {
+			return nil, err
+		}
+
+		handle.children = make([]fs.FileInfo, 0, len(files))
+		for _, f := range files {
+			handle.children = append(handle.children, newWebdavFileInfo(f))
+		}
+
+		sort.Slice(handle.children, func(i, j int) bool {
+			return handle.children[i].Name() < handle.children[j].Name()
+		})
+	}
+
+	if count <= 0 {
+		children := handle.children
+		handle.children = handle.children[len(handle.children):]
+		return children, nil
+	}
+
+	if len(handle.children) == 0 {
+		return nil, io.EOF
+	}
+
+	if count > len(handle.children) {
+		count = len(handle.children)
+	}
+
+	children := handle.children[:count]
+	handle.children = handle.children[count:]
+	return children, nil
+}
+
+func (handle *webdavFile) Stat() (fs.FileInfo, error) {
+	return handle.info, nil
+}
+
+func (handle *webdavFile) Close() error {
+	if handle.reader != nil {
+		handle.reader.Close()
+		handle.reader = nil
+	}
+
+	if !handle.dirty || !handle.writable {
+		return nil
+	}
+
+	handle.dirty = false
+	if handle.writer == nil {
+		// nothing has been written, and so the file is left empty
+		handle.upload()
+	}
+
+	handle.writer.Close()
+	handle.writer = nil
+	return webdavError(<-handle.uploaded)
+}
@@ -0,0 +1,257 @@
+package directory
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"testing"
+
+	fb "github.com/alvidir/filebrowser"
+	"github.com/alvidir/filebrowser/file"
+	"go.uber.org/zap"
+)
+
+func TestWebdavFileSystem(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	dir := NewDirectory(111)
+	for index, fp := range []string{"/a_file", "/docs/b_file"} {
+		f, _ := file.NewFile(strconv.Itoa(index), path.Base(fp))
+		f.AddPermission(111, file.Owner)
+		dir.AddFile(f, fp)
+	}
+
+	dirRepo := &directoryRepositoryMock{
+		findByUserId: func(ctx context.Context, userId int32, options *RepoOptions) (*Directory, error) {
+			return dir, nil
+		},
+	}
+
+	fileRepo := &fileRepositoryMock{
+		create: func(repo *fileRepositoryMock, ctx context.Context, f *file.File) error {
+			f.SetID("folder")
+			return nil
+		},
+		find: func(repo *fileRepositoryMock, ctx context.Context, id string) (*file.File, error) {
+			for _, f := range dir.files {
+				if f.Id() == id {
+					return f, nil
+				}
+			}
+
+			return nil, fb.ErrNotFound
+		},
+	}
+
+	app := NewDirectoryApplication(dirRepo, fileRepo, newContentStoreMock(&blobStoreMock{}, logger), logger)
+	wfs := &webdavFileSystem{dirApp: app, logger: logger}
+
+	if _, err := wfs.Stat(context.TODO(), "/a_file"); !errors.Is(err, os.ErrPermission) {
+		t.Errorf("got error = %v, want = %v", err, os.ErrPermission)
+	}
+
+	ctx := context.WithValue(context.TODO(), webdavUidContextKey{}, int32(111))
+
+	for name, isDir := range map[string]bool{"/": true, "/a_file": false, "/docs": true, "/docs/b_file": false} {
+		info, err := wfs.Stat(ctx, name)
+		if err != nil {
+			t.Errorf("%s: got error = %v, want = %v", name, err, nil)
+			continue
+		}
+
+		if info.IsDir() != isDir {
+			t.Errorf("%s: got is dir = %v, want = %v", name, info.IsDir(), isDir)
+		}
+	}
+
+	if _, err := wfs.Stat(ctx, "/unknown"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("got error = %v, want = %v", err, os.ErrNotExist)
+	}
+
+	root, err := wfs.OpenFile(ctx, "/", os.O_RDONLY, 0)
+	if err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+		return
+	}
+
+	children, err := root.Readdir(0)
+	if err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+		return
+	}
+
+	want := []string{"a_file", "docs"}
+	if len(children) != len(want) {
+		t.Errorf("got children = %v, want = %v", len(children), len(want))
+		return
+	}
+
+	for index, name := range want {
+		if got := children[index].Name(); got != name {
+			t.Errorf("got child = %v, want = %v", got, name)
+		}
+	}
+
+	if err := wfs.Mkdir(ctx, "/unknown/sub", 0); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("got error = %v, want = %v", err, os.ErrNotExist)
+	}
+
+	if err := wfs.Mkdir(ctx, "/a_file", 0); !errors.Is(err, os.ErrExist) {
+		t.Errorf("got error = %v, want = %v", err, os.ErrExist)
+	}
+
+	if err := wfs.Mkdir(ctx, "/docs/sub", 0); err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+	}
+
+	if info, err := wfs.Stat(ctx, "/docs/sub"); err != nil || !info.IsDir() {
+		t.Errorf("got error = %v, want = %v", err, nil)
+	}
+
+	if err := wfs.RemoveAll(ctx, "/unknown"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("got error = %v, want = %v", err, os.ErrNotExist)
+	}
+
+	if err := wfs.Rename(ctx, "/docs", "/docs/sub/docs"); !errors.Is(err, os.ErrPermission) {
+		t.Errorf("got error = %v, want = %v", err, os.ErrPermission)
+	}
+}
+
+// memoryBlobStoreMock keeps the content of all blobs in memory.
+type memoryBlobStoreMock struct {
+	blobs map[string][]byte
+}
+
+func (mock *memoryBlobStoreMock) Put(ctx context.Context, key string, r io.Reader) (int64, error) {
+	data, err := io.ReadAll(r)
+	mock.blobs[key] = data
+	return int64(len(data)), err
+}
+
+func (mock *memoryBlobStoreMock) Get(ctx context.Context, key string, w io.Writer) error {
+	data, exists := mock.blobs[key]
+	if !exists {
+		return fb.ErrNotFound
+	}
+
+	_, err := w.Write(data)
+	return err
+}
+
+func (mock *memoryBlobStoreMock) Delete(ctx context.Context, key string) error {
+	delete(mock.blobs, key)
+	return nil
+}
+
+func (mock *memoryBlobStoreMock) Stat(ctx context.Context, key string) (*file.BlobInfo, error) {
+	data, exists := mock.blobs[key]
+	if !exists {
+		return nil, fb.ErrNotFound
+	}
+
+	return &file.BlobInfo{Size: int64(len(data))}, nil
+}
+
+func (mock *eventBusMock) EmitFileCreated(uid int32, f *file.File) error {
+	return nil
+}
+
+func (mock *eventBusMock) EmitFileUpdated(uid int32, f *file.File) error {
+	return nil
+}
+
+func TestWebdavFileStreaming(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	f, _ := file.NewFile("123", "a_file")
+	f.AddPermission(111, file.Owner)
+
+	dir := NewDirectory(111)
+	dir.AddFile(f, "/a_file")
+
+	dirRepo := &directoryRepositoryMock{
+		findByUserId: func(ctx context.Context, userId int32, options *RepoOptions) (*Directory, error) {
+			return dir, nil
+		},
+	}
+
+	fileRepo := &fileRepositoryMock{
+		find: func(repo *fileRepositoryMock, ctx context.Context, id string) (*file.File, error) {
+			return f, nil
+		},
+		save: func(repo *fileRepositoryMock, ctx context.Context, f *file.File) error {
+			return nil
+		},
+	}
+
+	content := newContentStoreMock(&memoryBlobStoreMock{blobs: make(map[string][]byte)}, logger)
+	dirApp := NewDirectoryApplication(dirRepo, fileRepo, content, logger)
+	fileApp := file.NewFileApplication(fileRepo, content, dirApp, nil, &eventBusMock{}, logger)
+	wfs := &webdavFileSystem{dirApp: dirApp, fileApp: fileApp, logger: logger}
+
+	ctx := context.WithValue(context.TODO(), webdavUidContextKey{}, int32(111))
+
+	w, err := wfs.OpenFile(ctx, "/a_file", os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0)
+	if err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+		return
+	}
+
+	for _, chunk := range []string{"hello ", "world"} {
+		if _, err := w.Write([]byte(chunk)); err != nil {
+			t.Errorf("got error = %v, want = %v", err, nil)
+		}
+	}
+
+	if _, err := w.Seek(0, io.SeekStart); err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+	}
+
+	if _, err := w.Write([]byte("bye")); !errors.Is(err, os.ErrInvalid) {
+		t.Errorf("got error = %v, want = %v", err, os.ErrInvalid)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+		return
+	}
+
+	r, err := wfs.OpenFile(ctx, "/a_file", os.O_RDONLY, 0)
+	if err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+		return
+	}
+
+	defer r.Close()
+
+	if size, err := r.Seek(0, io.SeekEnd); err != nil || size != 11 {
+		t.Errorf("got size = %v, want = %v", size, 11)
+	}
+
+	for _, test := range []struct {
+		offset int64
+		want   string
+	}{
+		{offset: 6, want: "world"},
+		{offset: 0, want: "hello world"},
+		{offset: 12, want: ""},
+	} {
+		if _, err := r.Seek(test.offset, io.SeekStart); err != nil {
+			t.Errorf("got error = %v, want = %v", err, nil)
+			continue
+		}
+
+		var buf bytes.Buffer
+		if _, err := io.Copy(&buf, r); err != nil {
+			t.Errorf("got error = %v, want = %v", err, nil)
+		} else if got := buf.String(); got != test.want {
+			t.Errorf("got content = %v, want = %v", got, test.want)
+		}
+	}
+}
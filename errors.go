@@ -3,16 +3,18 @@ package filebrowser
 import "errors"
 
 var (
-	ErrUnknown      = errors.New("E001")
-	ErrNotFound     = errors.New("E002")
-	ErrNotAvailable = errors.New("E003")
-	ErrUnauthorized = errors.New("E004")
-	ErrInvalidToken = errors.New("E005")
-	// ErrInvalidFormat = errors.New("E006")
+	ErrUnknown       = errors.New("E001")
+	ErrNotFound      = errors.New("E002")
+	ErrNotAvailable  = errors.New("E003")
+	ErrUnauthorized  = errors.New("E004")
+	ErrInvalidToken  = errors.New("E005")
+	ErrInvalidFormat = errors.New("E006")
 	ErrInvalidHeader = errors.New("E007")
 	// ErrWrongCredentials = errors.New("E008")
 	ErrRegexNotMatch = errors.New("E009")
 	ErrAlreadyExists = errors.New("E010")
+	ErrConflict      = errors.New("E011")
+	ErrQuotaExceeded = errors.New("E012")
 
 	ErrChannelClosed    = errors.New("channel closed")
 	ErrProtectedContent = errors.New("protected content")
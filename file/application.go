@@ -1,7 +1,10 @@
 package file
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"io"
 	"strconv"
 	"time"
 
@@ -9,35 +12,59 @@ import (
 	"go.uber.org/zap"
 )
 
+// RepoOptions tells how files are to be retrieved, being all their fields by default.
+type RepoOptions struct {
+	View View
+}
+
 type FileRepository interface {
 	Create(ctx context.Context, file *File) error
-	Find(context.Context, string) (*File, error)
+	Find(ctx context.Context, id string, options *RepoOptions) (*File, error)
 	FindAll(context.Context, []string) ([]*File, error)
 	Save(ctx context.Context, file *File) error
 	Delete(ctx context.Context, file *File) error
 }
 
+// BlobStore stores the content of files by key, regardless of where their metadata is kept.
+type BlobStore interface {
+	Put(ctx context.Context, key string, r io.Reader) (int64, error)
+	Get(ctx context.Context, key string, w io.Writer) error
+	Delete(ctx context.Context, key string) error
+	Stat(ctx context.Context, key string) (*BlobInfo, error)
+}
+
 type DirectoryApplication interface {
 	RegisterFile(ctx context.Context, uid int32, file *File) (string, error)
 	UnregisterFile(ctx context.Context, uid int32, file *File) error
+	TrashFile(ctx context.Context, uid int32, file *File) error
+}
+
+type GroupApplication interface {
+	MemberOf(ctx context.Context, uid int32) ([]string, error)
+	Exists(ctx context.Context, gid string) (bool, error)
 }
 
 type EventBus interface {
 	EmitFileCreated(uid int32, f *File) error
+	EmitFileUpdated(uid int32, f *File) error
 	EmitFileDeleted(uid int32, f *File) error
 }
 
 type FileApplication struct {
 	fileRepo FileRepository
+	content  *ContentStore
 	dirApp   DirectoryApplication
+	groupApp GroupApplication
 	fileBus  EventBus
 	logger   *zap.Logger
 }
 
-func NewFileApplication(repo FileRepository, dirApp DirectoryApplication, bus EventBus, logger *zap.Logger) *FileApplication {
+func NewFileApplication(repo FileRepository, content *ContentStore, dirApp DirectoryApplication, groupApp GroupApplication, bus EventBus, logger *zap.Logger) *FileApplication {
 	return &FileApplication{
 		fileRepo: repo,
+		content:  content,
 		dirApp:   dirApp,
+		groupApp: groupApp,
 		fileBus:  bus,
 		logger:   logger,
 	}
@@ -48,6 +75,8 @@ type CreateOptions struct {
 	Directory string
 	Meta      Metadata
 	Data      []byte
+	// Scope is the one of the metadata keys the request is allowed to set, those of users by default.
+	Scope MetadataScope
 }
 
 func (app *FileApplication) Create(ctx context.Context, uid int32, options *CreateOptions) (*File, error) {
@@ -56,66 +85,149 @@ func (app *FileApplication) Create(ctx context.Context, uid int32, options *Crea
 		zap.String("directory", options.Directory),
 		zap.Any("user_id", uid))
 
-	if options.Meta == nil {
-		options.Meta = make(Metadata)
+	var r io.Reader
+	if options.Data != nil {
+		r = bytes.NewReader(options.Data)
 	}
 
-	options.Meta[MetadataCreatedAtKey] = strconv.FormatInt(time.Now().Unix(), TimestampBase)
-	options.Meta[MetadataUpdatedAtKey] = options.Meta[MetadataCreatedAtKey]
+	file, err := app.create(ctx, uid, options, r)
+	if err != nil {
+		return nil, err
+	}
 
+	file.data = options.Data
+	return file, nil
+}
+
+// create creates a brand new file having the content read from r, if any. The file is registered into the
+// directory of the user only once its content is written, and it is discarded if anything fails before that.
+func (app *FileApplication) create(ctx context.Context, uid int32, options *CreateOptions, r io.Reader) (*File, error) {
 	file, err := NewFile("", options.Name)
 	if err != nil {
 		return nil, err
 	}
 
+	// the size, content type and checksum are set once the content is written
+	if err := file.PatchMetadata(options.Meta, options.Scope); err != nil {
+		return nil, err
+	}
+
 	file.AddPermission(uid, Owner)
-	file.metadata = options.Meta
-	file.data = options.Data
+
+	if err := app.content.Quotas().Reserve(ctx, file.Owners(), 0, 1); err != nil {
+		return nil, err
+	}
 
 	if err := app.fileRepo.Create(ctx, file); err != nil {
+		app.content.Quotas().Release(ctx, file.Owners(), 0, 1)
 		return nil, err
 	}
 
-	if err := app.fileBus.EmitFileCreated(uid, file); err != nil {
-		app.logger.Error("emiting file created event",
-			zap.String("file_id", file.id),
-			zap.Int32("user_id", uid),
-			zap.Error(err))
+	if r != nil {
+		if err := app.writeData(ctx, uid, file, r); err != nil {
+			app.discard(ctx, file)
+			return nil, err
+		}
 	}
 
 	file.directory = options.Directory
 	name, err := app.dirApp.RegisterFile(ctx, uid, file)
 	if err != nil {
+		app.discard(ctx, file)
 		return nil, err
 	}
 
+	// the event is emitted once registered, so it tells the final path of the file
 	file.SetName(name)
+	if err := app.fileBus.EmitFileCreated(uid, file); err != nil {
+		app.logger.Error("emiting file created event",
+			zap.String("file_id", file.id),
+			zap.Int32("user_id", uid),
+			zap.Error(err))
+	}
+
 	return file, nil
 }
 
-func (app *FileApplication) Get(ctx context.Context, uid int32, fid string) (*File, error) {
+// discard removes, together with its content, a file that failed to be created. Since the failure is already
+// being reported, any error here is logged but never returned.
+func (app *FileApplication) discard(ctx context.Context, file *File) {
+	if err := app.content.Delete(ctx, file); err != nil {
+		app.logger.Warn("deleting content of discarded file",
+			zap.String("file_id", file.id),
+			zap.Error(err))
+	}
+
+	if err := app.fileRepo.Delete(ctx, file); err != nil {
+		app.logger.Warn("deleting discarded file",
+			zap.String("file_id", file.id),
+			zap.Error(err))
+	}
+
+	app.content.Quotas().Release(ctx, file.Owners(), 0, 1)
+}
+
+type GetOptions struct {
+	// View tells which parts of the file are to be retrieved, all of them by default.
+	View View
+}
+
+func (app *FileApplication) Get(ctx context.Context, uid int32, fid string, options *GetOptions) (*File, error) {
 	app.logger.Info("processing a \"get\" file request",
 		zap.String("file_id", fid),
 		zap.Int32("user_id", uid))
 
-	file, err := app.fileRepo.Find(ctx, fid)
+	view := FullView
+	if options != nil {
+		view = options.View
+	}
+
+	file, err := app.fileRepo.Find(ctx, fid, &RepoOptions{View: view})
+	if err != nil {
+		return nil, err
+	}
+
+	perm, err := app.permission(ctx, uid, file)
 	if err != nil {
 		return nil, err
 	}
 
-	perm := file.Permission(uid)
 	if perm&(Read|Owner) == 0 {
 		return nil, fb.ErrNotAvailable
 	}
 
+	if view == BasicView {
+		file.ProtectFields(uid)
+		return file, nil
+	}
+
+	var buf bytes.Buffer
+	if err := app.content.Read(ctx, file, &buf); err != nil {
+		return nil, err
+	}
+
+	if view == DataOnlyView {
+		// the fields required to access the file are not to be disclosed
+		return &File{id: file.id, data: buf.Bytes(), protected: true}, nil
+	}
+
+	file.data = buf.Bytes()
 	file.ProtectFields(uid)
 	return file, nil
 }
 
 type UpdateOptions struct {
 	Name string
+	// Meta is merged into the metadata of the file, where an empty value removes its key.
 	Meta Metadata
+	// ReplaceMeta makes Meta replace all the metadata keys within Scope, rather than being merged into them.
+	ReplaceMeta bool
+	// Data, if not nil, replaces the content of the file, even if empty.
 	Data []byte
+	// Scope is the one of the metadata keys the request is allowed to set, those of users by default.
+	Scope MetadataScope
+	// Revision, if set, is the one the file is expected to be at; otherwise the update is rejected.
+	Revision int64
 }
 
 func (app *FileApplication) Update(ctx context.Context, uid int32, fid string, options *UpdateOptions) (*File, error) {
@@ -123,27 +235,59 @@ func (app *FileApplication) Update(ctx context.Context, uid int32, fid string, o
 		zap.String("file_id", fid),
 		zap.Int32("user_id", uid))
 
-	file, err := app.fileRepo.Find(ctx, fid)
+	file, err := app.fileRepo.Find(ctx, fid, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	if file.Permission(uid)&(Write|Owner) == 0 {
+	perm, err := app.permission(ctx, uid, file)
+	if err != nil {
+		return nil, err
+	} else if perm&(Write|Owner) == 0 {
 		return nil, fb.ErrNotAvailable
 	}
 
+	if options.Revision != 0 && options.Revision != file.revision {
+		app.logger.Warn("stale \"update\" file request",
+			zap.String("file_id", fid),
+			zap.Int64("revision", file.revision),
+			zap.Int64("expected_revision", options.Revision))
+
+		return nil, fb.ErrConflict
+	}
+
 	if len(options.Name) > 0 {
 		file.name = options.Name
 	}
 
-	if options.Data != nil {
-		file.data = options.Data
+	patch := options.Meta
+	if options.ReplaceMeta {
+		patch = make(Metadata, len(file.metadata)+len(options.Meta))
+		for key := range file.metadata {
+			patch[key] = ""
+		}
+
+		for key, value := range options.Meta {
+			patch[key] = value
+		}
+	}
+
+	if perm&Owner == 0 && options.Scope == UserScope {
+		patch = withoutOwnerMetadata(patch)
+	}
+
+	if err := file.PatchMetadata(patch, options.Scope); err != nil {
+		return nil, err
 	}
 
-	if options.Meta != nil {
-		// ensure immutable data is not overwrited
-		options.Meta[MetadataCreatedAtKey] = file.metadata[MetadataCreatedAtKey]
-		file.metadata = options.Meta
+	if options.Data != nil {
+		if err := app.writeData(ctx, uid, file, bytes.NewReader(options.Data)); err != nil {
+			return nil, err
+		}
+
+		file.data = options.Data
+		app.emitFileUpdated(uid, file)
+		return file, nil
 	}
 
 	file.metadata[MetadataUpdatedAtKey] = strconv.FormatInt(time.Now().Unix(), TimestampBase)
@@ -152,50 +296,505 @@ func (app *FileApplication) Update(ctx context.Context, uid int32, fid string, o
 		return nil, err
 	}
 
+	app.emitFileUpdated(uid, file)
 	return file, nil
 }
 
+func (app *FileApplication) emitFileUpdated(uid int32, file *File) {
+	if err := app.fileBus.EmitFileUpdated(uid, file); err != nil {
+		app.logger.Error("emiting file updated event",
+			zap.String("file_id", file.id),
+			zap.Int32("user_id", uid),
+			zap.Error(err))
+	}
+}
+
+// Delete moves the file with the given id into the user's trash, from where it can be restored until purged. A
+// file granted through groups only is in no directory of the user, and so it gets moved into its owners' trash
+// instead, provided the user can write it.
 func (app *FileApplication) Delete(ctx context.Context, uid int32, fid string) (*File, error) {
 	app.logger.Info("processing a \"delete\" file request",
 		zap.String("file_id", fid),
 		zap.Int32("user_id", uid))
 
-	f, err := app.fileRepo.Find(ctx, fid)
+	f, err := app.fileRepo.Find(ctx, fid, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	if err = app.dirApp.UnregisterFile(ctx, uid, f); err != nil {
+	perm, err := app.permission(ctx, uid, f)
+	if err != nil {
 		return nil, err
+	} else if perm == 0 || f.Permission(uid) == 0 && perm&Write == 0 {
+		app.logger.Warn("unauthorized \"delete\" file request",
+			zap.String("file_id", fid),
+			zap.Int32("user_id", uid))
+
+		return nil, fb.ErrNotAvailable
+	}
+
+	if f.Permission(uid) != 0 {
+		if err = app.dirApp.TrashFile(ctx, uid, f); err != nil {
+			return nil, err
+		}
+	} else {
+		for _, owner := range f.Owners() {
+			// an owner may have the file trashed already
+			if err = app.dirApp.TrashFile(ctx, owner, f); err != nil && !errors.Is(err, fb.ErrNotFound) {
+				return nil, err
+			}
+		}
+	}
+
+	f.ProtectFields(uid)
+	return f, nil
+}
+
+// Share grants the given permission over the file with the given id to the user grantee, who gets the
+// file registered into its own directory the first time it is shared with. Only owners can share a file.
+func (app *FileApplication) Share(ctx context.Context, uid int32, fid string, grantee int32, perm Permission) (*File, error) {
+	app.logger.Info("processing a \"share\" file request",
+		zap.String("file_id", fid),
+		zap.Int32("user_id", uid),
+		zap.Int32("grantee", grantee))
+
+	if perm&(Read|Write|Owner) == 0 {
+		return nil, fb.ErrInvalidFormat
+	}
+
+	file, err := app.fileRepo.Find(ctx, fid, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if file.Permission(uid)&Owner == 0 {
+		return nil, fb.ErrNotAvailable
 	}
 
-	if f.Permission(uid)&Owner != 0 && len(f.Owners()) == 1 {
-		// uid is the only owner of file f, and so it must be hard deleted
-		f.metadata[MetadataDeletedAtKey] = strconv.FormatInt(time.Now().Unix(), TimestampBase)
+	isNew := file.Permission(grantee) == 0
+	becomesOwner := file.Permission(grantee)&Owner == 0 && perm&Owner != 0
+	file.AddPermission(grantee, perm)
 
-		if err := app.fileRepo.Delete(ctx, f); err != nil {
-			return f, err
+	var size int64
+	if becomesOwner {
+		// a new owner consumes the file as much as any other
+		if size, err = app.content.Size(ctx, file); err != nil {
+			return nil, err
 		}
 
-		if err := app.fileBus.EmitFileDeleted(uid, f); err != nil {
-			app.logger.Error("emiting file deleted event",
-				zap.String("file_id", f.id),
-				zap.Int32("user_id", uid),
+		if err := app.content.Quotas().Reserve(ctx, []int32{grantee}, size, 1); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := app.fileRepo.Save(ctx, file); err != nil {
+		if becomesOwner {
+			app.content.Quotas().Release(ctx, []int32{grantee}, size, 1)
+		}
+
+		return nil, err
+	}
+
+	if isNew {
+		shared := *file
+		shared.directory = SharedDirectory
+		if _, err := app.dirApp.RegisterFile(ctx, grantee, &shared); err != nil {
+			// the grantee must not keep a permission over a file it cannot find in its directory
+			app.revokeShare(ctx, file, grantee, perm, becomesOwner, size)
+			return nil, err
+		}
+	}
+
+	return file, nil
+}
+
+// revokeShare takes back from the grantee the given permission it has just been granted over the file, as well
+// as the quota it was reserved for it if it became one of its owners.
+func (app *FileApplication) revokeShare(ctx context.Context, file *File, grantee int32, perm Permission, becameOwner bool, size int64) {
+	file.RevokePermission(grantee, perm)
+	if err := app.fileRepo.Save(ctx, file); err != nil {
+		app.logger.Error("revoking file share",
+			zap.String("file_id", file.id),
+			zap.Int32("grantee", grantee),
+			zap.Error(err))
+
+		return
+	}
+
+	if becameOwner {
+		app.content.Quotas().Release(ctx, []int32{grantee}, size, 1)
+	}
+}
+
+// Unshare revokes the given permission over the file with the given id from the user grantee, or all of them
+// if none is given. The file gets unregistered from the grantee's directory once it has no access to it. Only
+// owners can unshare a file, which must keep at least one owner.
+func (app *FileApplication) Unshare(ctx context.Context, uid int32, fid string, grantee int32, perm Permission) (*File, error) {
+	app.logger.Info("processing an \"unshare\" file request",
+		zap.String("file_id", fid),
+		zap.Int32("user_id", uid),
+		zap.Int32("grantee", grantee))
+
+	file, err := app.fileRepo.Find(ctx, fid, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if file.Permission(uid)&Owner == 0 {
+		return nil, fb.ErrNotAvailable
+	}
+
+	if file.Permission(grantee) == 0 {
+		return nil, fb.ErrNotFound
+	}
+
+	if perm == 0 {
+		perm = Read | Write | Owner
+	}
+
+	wasOwner := file.Permission(grantee)&Owner != 0
+	file.RevokePermission(grantee, perm)
+	if owners := file.Owners(); owners[0] == 0 {
+		// a file cannot be left without owners
+		return nil, fb.ErrNotAvailable
+	}
+
+	if err := app.fileRepo.Save(ctx, file); err != nil {
+		return nil, err
+	}
+
+	if wasOwner && file.Permission(grantee)&Owner == 0 {
+		if size, err := app.content.Size(ctx, file); err != nil {
+			app.logger.Warn("getting file size",
+				zap.String("file_id", fid),
 				zap.Error(err))
+		} else {
+			app.content.Quotas().Release(ctx, []int32{grantee}, size, 1)
+		}
+	}
+
+	if file.Permission(grantee) == 0 {
+		if err := app.dirApp.UnregisterFile(ctx, grantee, file); err != nil {
+			return nil, err
 		}
+	}
+
+	return file, nil
+}
+
+// ShareWithGroup grants the given permission over the file with the given id to all the members of the group
+// with the given id. Unlike users, groups can only be granted to read and write a file, never to own it. Only
+// owners can share a file.
+func (app *FileApplication) ShareWithGroup(ctx context.Context, uid int32, fid string, gid string, perm Permission) (*File, error) {
+	app.logger.Info("processing a \"share with group\" file request",
+		zap.String("file_id", fid),
+		zap.Int32("user_id", uid),
+		zap.String("group_id", gid))
+
+	if perm&(Read|Write) == 0 || perm&Owner != 0 {
+		return nil, fb.ErrInvalidFormat
+	}
+
+	file, err := app.fileRepo.Find(ctx, fid, nil)
+	if err != nil {
+		return nil, err
+	}
 
-		return f, nil
+	if file.Permission(uid)&Owner == 0 {
+		return nil, fb.ErrNotAvailable
 	}
 
-	if f.RevokeAccess(uid) {
-		err = app.fileRepo.Save(ctx, f)
-		return f, err
+	if exists, err := app.groupApp.Exists(ctx, gid); err != nil {
+		return nil, err
+	} else if !exists {
+		return nil, fb.ErrNotFound
 	}
 
-	app.logger.Warn("unauthorized \"delete\" file request",
+	file.AddGroupPermission(gid, perm)
+	if err := app.fileRepo.Save(ctx, file); err != nil {
+		return nil, err
+	}
+
+	return file, nil
+}
+
+// UnshareWithGroup revokes the given permission over the file with the given id from the group with the given
+// id, or all of them if none is given. Only owners can unshare a file.
+func (app *FileApplication) UnshareWithGroup(ctx context.Context, uid int32, fid string, gid string, perm Permission) (*File, error) {
+	app.logger.Info("processing an \"unshare with group\" file request",
+		zap.String("file_id", fid),
+		zap.Int32("user_id", uid),
+		zap.String("group_id", gid))
+
+	file, err := app.fileRepo.Find(ctx, fid, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if file.Permission(uid)&Owner == 0 {
+		return nil, fb.ErrNotAvailable
+	}
+
+	if file.GroupPermission(gid) == 0 {
+		return nil, fb.ErrNotFound
+	}
+
+	if perm == 0 {
+		perm = Read | Write
+	}
+
+	file.RevokeGroupPermission(gid, perm)
+	if err := app.fileRepo.Save(ctx, file); err != nil {
+		return nil, err
+	}
+
+	return file, nil
+}
+
+// ListShares returns the permissions over the file with the given id, granted either to users or groups, the
+// user uid has the right to know.
+func (app *FileApplication) ListShares(ctx context.Context, uid int32, fid string) (map[int32]Permission, map[string]Permission, error) {
+	app.logger.Info("processing a \"list shares\" file request",
 		zap.String("file_id", fid),
 		zap.Int32("user_id", uid))
 
-	return nil, fb.ErrNotAvailable
+	file, err := app.fileRepo.Find(ctx, fid, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if perm, err := app.permission(ctx, uid, file); err != nil {
+		return nil, nil, err
+	} else if perm == 0 {
+		return nil, nil, fb.ErrNotAvailable
+	}
+
+	file.ProtectFields(uid)
+	return file.permissions, file.groups, nil
+}
+
+type UploadOptions struct {
+	Id        string
+	Name      string
+	Directory string
+	Meta      Metadata
+}
+
+// Upload reads from r the content of the file described by the given options. If no id is provided a
+// brand new file is created, otherwise the content of the existing one is replaced.
+func (app *FileApplication) Upload(ctx context.Context, uid int32, options *UploadOptions, r io.Reader) (*File, error) {
+	app.logger.Info("processing an \"upload\" file request",
+		zap.String("file_id", options.Id),
+		zap.String("name", options.Name),
+		zap.Int32("user_id", uid))
+
+	if len(options.Id) == 0 {
+		return app.create(ctx, uid, &CreateOptions{
+			Name:      options.Name,
+			Directory: options.Directory,
+			Meta:      options.Meta,
+		}, r)
+	}
+
+	file, err := app.fileRepo.Find(ctx, options.Id, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if perm, err := app.permission(ctx, uid, file); err != nil {
+		return nil, err
+	} else if perm&(Write|Owner) == 0 {
+		return nil, fb.ErrNotAvailable
+	}
+
+	if err := app.writeData(ctx, uid, file, r); err != nil {
+		return nil, err
+	}
+
+	app.emitFileUpdated(uid, file)
+	return file, nil
+}
+
+// Download writes into w the content of the file with the given id, if, and only if, the user has
+// permissions to read it.
+func (app *FileApplication) Download(ctx context.Context, uid int32, fid string, w io.Writer) (*File, error) {
+	app.logger.Info("processing a \"download\" file request",
+		zap.String("file_id", fid),
+		zap.Int32("user_id", uid))
+
+	file, err := app.fileRepo.Find(ctx, fid, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if perm, err := app.permission(ctx, uid, file); err != nil {
+		return nil, err
+	} else if perm&(Read|Owner) == 0 {
+		return nil, fb.ErrNotAvailable
+	}
+
+	if err := app.content.Read(ctx, file, w); err != nil {
+		return nil, err
+	}
+
+	file.ProtectFields(uid)
+	return file, nil
+}
+
+// ListVersions returns all the versions of the file with the given id, from the newest to the oldest one.
+func (app *FileApplication) ListVersions(ctx context.Context, uid int32, fid string) ([]*Version, error) {
+	app.logger.Info("processing a \"list versions\" file request",
+		zap.String("file_id", fid),
+		zap.Int32("user_id", uid))
+
+	file, err := app.fileRepo.Find(ctx, fid, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if perm, err := app.permission(ctx, uid, file); err != nil {
+		return nil, err
+	} else if perm&(Read|Owner) == 0 {
+		return nil, fb.ErrNotAvailable
+	}
+
+	return app.content.Versions(ctx, file)
+}
+
+// GetVersion writes into w the content of the given version of the file with the given id.
+func (app *FileApplication) GetVersion(ctx context.Context, uid int32, fid string, vid string, w io.Writer) (*Version, error) {
+	app.logger.Info("processing a \"get version\" file request",
+		zap.String("file_id", fid),
+		zap.String("version_id", vid),
+		zap.Int32("user_id", uid))
+
+	file, err := app.fileRepo.Find(ctx, fid, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if perm, err := app.permission(ctx, uid, file); err != nil {
+		return nil, err
+	} else if perm&(Read|Owner) == 0 {
+		return nil, fb.ErrNotAvailable
+	}
+
+	version, err := app.content.Version(ctx, file, vid)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := app.content.ReadVersion(ctx, version, w); err != nil {
+		return nil, err
+	}
+
+	return version, nil
+}
+
+// RestoreVersion makes the content of the given version the current content of the file with the given id.
+// Since versions are immutable, the restoration is recorded as a brand new version.
+func (app *FileApplication) RestoreVersion(ctx context.Context, uid int32, fid string, vid string) (*File, error) {
+	app.logger.Info("processing a \"restore version\" file request",
+		zap.String("file_id", fid),
+		zap.String("version_id", vid),
+		zap.Int32("user_id", uid))
+
+	file, err := app.fileRepo.Find(ctx, fid, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if perm, err := app.permission(ctx, uid, file); err != nil {
+		return nil, err
+	} else if perm&(Write|Owner) == 0 {
+		return nil, fb.ErrNotAvailable
+	}
+
+	version, err := app.content.Version(ctx, file, vid)
+	if err != nil {
+		return nil, err
+	}
+
+	previous := file.blob
+	restored, err := app.content.Restore(ctx, uid, file, version)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := app.saveContent(ctx, file); err != nil {
+		app.content.Discard(ctx, file, restored, previous)
+		return nil, err
+	}
+
+	app.emitFileUpdated(uid, file)
+	file.ProtectFields(uid)
+	return file, nil
+}
+
+// GetUsage returns the storage the user uid consumes, together with the quota limiting it.
+func (app *FileApplication) GetUsage(ctx context.Context, uid int32) (*Usage, error) {
+	app.logger.Info("processing a \"get usage\" file request",
+		zap.Int32("user_id", uid))
+
+	return app.content.Quotas().Usage(ctx, uid)
+}
+
+// permission returns the permission the given user has over the given file, either granted to the user itself
+// or to any of the groups it is member of.
+func (app *FileApplication) permission(ctx context.Context, uid int32, file *File) (Permission, error) {
+	if len(file.groups) == 0 {
+		return file.Permission(uid), nil
+	}
+
+	gids, err := app.groupApp.MemberOf(ctx, uid)
+	if err != nil {
+		return 0, err
+	}
+
+	return file.EffectivePermission(uid, gids), nil
+}
+
+// writeData stores the content read from r as a brand new version of the given file, and saves it. The version
+// is discarded if the file fails to be saved, so no content is kept with no file pointing at it.
+func (app *FileApplication) writeData(ctx context.Context, uid int32, file *File, r io.Reader) error {
+	previous := file.blob
+	version, err := app.content.Write(ctx, uid, file, r)
+	if err != nil {
+		return err
+	}
+
+	if err := app.saveContent(ctx, file); err != nil {
+		app.content.Discard(ctx, file, version, previous)
+		return err
+	}
+
+	return nil
+}
+
+// saveContent saves the given file once its content has changed, pruning any version exceeding its retention.
+func (app *FileApplication) saveContent(ctx context.Context, file *File) error {
+	file.metadata[MetadataUpdatedAtKey] = strconv.FormatInt(time.Now().Unix(), TimestampBase)
+	if err := app.fileRepo.Save(ctx, file); err != nil {
+		return err
+	}
+
+	if err := app.content.Prune(ctx, file); err != nil {
+		app.logger.Warn("pruning file versions",
+			zap.String("file_id", file.id),
+			zap.Error(err))
+	}
+
+	return nil
+}
+
+// withoutOwnerMetadata returns a copy of the given patch having no key only the owners of a file can set.
+func withoutOwnerMetadata(patch Metadata) Metadata {
+	filtered := make(Metadata, len(patch))
+	for key, value := range patch {
+		if !IsOwnerMetadata(key) {
+			filtered[key] = value
+		}
+	}
 
+	return filtered
 }
@@ -1,10 +1,15 @@
 package file
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"io"
 	"strconv"
 	"testing"
+	"testing/iotest"
 	"time"
 
 	fb "github.com/alvidir/filebrowser"
@@ -14,6 +19,7 @@ import (
 type directoryApplicationMock struct {
 	registerFile   func(ctx context.Context, uid int32, file *File) (string, error)
 	unregisterFile func(ctx context.Context, uid int32, file *File) error
+	trashFile      func(ctx context.Context, uid int32, file *File) error
 }
 
 func (app *directoryApplicationMock) RegisterFile(ctx context.Context, uid int32, file *File) (string, error) {
@@ -32,12 +38,42 @@ func (app *directoryApplicationMock) UnregisterFile(ctx context.Context, uid int
 	return fb.ErrUnknown
 }
 
+func (app *directoryApplicationMock) TrashFile(ctx context.Context, uid int32, file *File) error {
+	if app.trashFile != nil {
+		return app.trashFile(ctx, uid, file)
+	}
+
+	return fb.ErrUnknown
+}
+
 func (app *directoryApplicationMock) FileSearch(ctx context.Context, uid int32, search string) ([]*File, error) {
 	return nil, fb.ErrUnknown
 }
 
+type groupApplicationMock struct {
+	memberOf func(ctx context.Context, uid int32) ([]string, error)
+	exists   func(ctx context.Context, gid string) (bool, error)
+}
+
+func (app *groupApplicationMock) MemberOf(ctx context.Context, uid int32) ([]string, error) {
+	if app.memberOf != nil {
+		return app.memberOf(ctx, uid)
+	}
+
+	return nil, nil
+}
+
+func (app *groupApplicationMock) Exists(ctx context.Context, gid string) (bool, error) {
+	if app.exists != nil {
+		return app.exists(ctx, gid)
+	}
+
+	return true, nil
+}
+
 type EventBusMock struct {
 	emitFileCreated func(repo *EventBusMock, uid int32, f *File) error
+	emitFileUpdated func(repo *EventBusMock, uid int32, f *File) error
 	emitFileDeleted func(repo *EventBusMock, uid int32, f *File) error
 }
 
@@ -49,6 +85,14 @@ func (bus *EventBusMock) EmitFileCreated(uid int32, f *File) error {
 	return nil
 }
 
+func (bus *EventBusMock) EmitFileUpdated(uid int32, f *File) error {
+	if bus.emitFileUpdated != nil {
+		return bus.emitFileUpdated(bus, uid, f)
+	}
+
+	return nil
+}
+
 func (bus *EventBusMock) EmitFileDeleted(uid int32, f *File) error {
 	if bus.emitFileDeleted != nil {
 		return bus.emitFileDeleted(bus, uid, f)
@@ -63,6 +107,7 @@ type fileRepositoryMock struct {
 	save   func(repo *fileRepositoryMock, ctx context.Context, file *File) error
 	delete func(repo *fileRepositoryMock, ctx context.Context, file *File) error
 	flags  Flag
+	view   View
 }
 
 func (mock *fileRepositoryMock) Create(ctx context.Context, file *File) error {
@@ -73,7 +118,11 @@ func (mock *fileRepositoryMock) Create(ctx context.Context, file *File) error {
 	return fb.ErrAlreadyExists
 }
 
-func (mock *fileRepositoryMock) Find(ctx context.Context, id string) (*File, error) {
+func (mock *fileRepositoryMock) Find(ctx context.Context, id string, options *RepoOptions) (*File, error) {
+	if options != nil {
+		mock.view = options.View
+	}
+
 	if mock.find != nil {
 		return mock.find(mock, ctx, id)
 	}
@@ -105,6 +154,88 @@ func (mock *fileRepositoryMock) Delete(ctx context.Context, file *File) error {
 	return fb.ErrUnknown
 }
 
+type blobStoreMock struct {
+	put    func(ctx context.Context, key string, r io.Reader) (int64, error)
+	get    func(ctx context.Context, key string, w io.Writer) error
+	delete func(ctx context.Context, key string) error
+}
+
+func (mock *blobStoreMock) Put(ctx context.Context, key string, r io.Reader) (int64, error) {
+	if mock.put != nil {
+		return mock.put(ctx, key, r)
+	}
+
+	return io.Copy(io.Discard, r)
+}
+
+func (mock *blobStoreMock) Get(ctx context.Context, key string, w io.Writer) error {
+	if mock.get != nil {
+		return mock.get(ctx, key, w)
+	}
+
+	return fb.ErrNotFound
+}
+
+func (mock *blobStoreMock) Delete(ctx context.Context, key string) error {
+	if mock.delete != nil {
+		return mock.delete(ctx, key)
+	}
+
+	return nil
+}
+
+func (mock *blobStoreMock) Stat(ctx context.Context, key string) (*BlobInfo, error) {
+	return nil, fb.ErrNotFound
+}
+
+type versionRepositoryMock struct {
+	create       func(ctx context.Context, version *Version) error
+	find         func(ctx context.Context, id string) (*Version, error)
+	findByFileId func(ctx context.Context, fid string) ([]*Version, error)
+	delete       func(ctx context.Context, version *Version) error
+}
+
+func (mock *versionRepositoryMock) Create(ctx context.Context, version *Version) error {
+	if mock.create != nil {
+		return mock.create(ctx, version)
+	}
+
+	version.id = "version"
+	return nil
+}
+
+func (mock *versionRepositoryMock) Find(ctx context.Context, id string) (*Version, error) {
+	if mock.find != nil {
+		return mock.find(ctx, id)
+	}
+
+	return nil, fb.ErrNotFound
+}
+
+func (mock *versionRepositoryMock) FindByFileId(ctx context.Context, fid string) ([]*Version, error) {
+	if mock.findByFileId != nil {
+		return mock.findByFileId(ctx, fid)
+	}
+
+	return []*Version{}, nil
+}
+
+func (mock *versionRepositoryMock) Delete(ctx context.Context, version *Version) error {
+	if mock.delete != nil {
+		return mock.delete(ctx, version)
+	}
+
+	return nil
+}
+
+func (mock *versionRepositoryMock) DeleteByFileId(ctx context.Context, fid string) error {
+	return nil
+}
+
+func newContentStoreMock(blobs BlobStore, logger *zap.Logger) *ContentStore {
+	return NewContentStore(blobs, &versionRepositoryMock{}, 0, logger)
+}
+
 func TestCreateWhenFileAlreadyExists(t *testing.T) {
 	logger, _ := zap.NewProduction()
 	defer logger.Sync()
@@ -116,7 +247,7 @@ func TestCreateWhenFileAlreadyExists(t *testing.T) {
 	}
 
 	fileRepo := &fileRepositoryMock{}
-	app := NewFileApplication(fileRepo, dirApp, &EventBusMock{}, logger)
+	app := NewFileApplication(fileRepo, newContentStoreMock(&blobStoreMock{}, logger), dirApp, &groupApplicationMock{}, &EventBusMock{}, logger)
 
 	userId := int32(999)
 	options := CreateOptions{
@@ -129,6 +260,41 @@ func TestCreateWhenFileAlreadyExists(t *testing.T) {
 	}
 }
 
+func TestCreateWhenQuotaExceeded(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	fileRepo := &fileRepositoryMock{
+		create: func(repo *fileRepositoryMock, ctx context.Context, file *File) error {
+			t.Errorf("got create = %v, want = %v", true, false)
+			return nil
+		},
+	}
+
+	usageRepo := &usageRepositoryStub{
+		usages: map[int32]*Usage{
+			999: {userId: 999, files: 1},
+		},
+	}
+
+	content := newContentStoreMock(&blobStoreMock{}, logger)
+	content.SetQuotaStore(NewQuotaStore(usageRepo, Quota{Files: 1}, logger))
+	app := NewFileApplication(fileRepo, content, &directoryApplicationMock{}, &groupApplicationMock{}, &EventBusMock{}, logger)
+
+	options := CreateOptions{
+		Name:      "example.test",
+		Directory: "path/to",
+	}
+
+	if _, err := app.Create(context.Background(), 999, &options); !errors.Is(err, fb.ErrQuotaExceeded) {
+		t.Errorf("got error = %v, want = %v", err, fb.ErrQuotaExceeded)
+	}
+
+	if usage, _ := app.GetUsage(context.Background(), 999); usage.Files() != 1 {
+		t.Errorf("got files = %v, want = %v", usage.Files(), 1)
+	}
+}
+
 func TestReadWhenFileDoesNotExists(t *testing.T) {
 	logger, _ := zap.NewProduction()
 	defer logger.Sync()
@@ -140,12 +306,12 @@ func TestReadWhenFileDoesNotExists(t *testing.T) {
 	}
 
 	fileRepo := &fileRepositoryMock{}
-	app := NewFileApplication(fileRepo, dirApp, &EventBusMock{}, logger)
+	app := NewFileApplication(fileRepo, newContentStoreMock(&blobStoreMock{}, logger), dirApp, &groupApplicationMock{}, &EventBusMock{}, logger)
 
 	userId := int32(999)
 	fid := "testing"
 
-	if _, err := app.Get(context.Background(), userId, fid); !errors.Is(err, fb.ErrNotFound) {
+	if _, err := app.Get(context.Background(), userId, fid, nil); !errors.Is(err, fb.ErrNotFound) {
 		t.Errorf("got error = %v, want = %v", err, fb.ErrNotFound)
 	}
 }
@@ -168,8 +334,12 @@ func TestCreate(t *testing.T) {
 			file.id = fileId
 			return nil
 		},
+
+		save: func(repo *fileRepositoryMock, ctx context.Context, file *File) error {
+			return nil
+		},
 	}
-	app := NewFileApplication(fileRepo, dirApp, &EventBusMock{}, logger)
+	app := NewFileApplication(fileRepo, newContentStoreMock(&blobStoreMock{}, logger), dirApp, &groupApplicationMock{}, &EventBusMock{}, logger)
 
 	userId := int32(999)
 	options := CreateOptions{
@@ -212,6 +382,83 @@ func TestCreate(t *testing.T) {
 	}
 }
 
+func TestCreateSetsContentMetadata(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	dirApp := &directoryApplicationMock{
+		registerFile: func(ctx context.Context, uid int32, file *File) (string, error) {
+			return file.name, nil
+		},
+	}
+
+	fileRepo := &fileRepositoryMock{
+		create: func(repo *fileRepositoryMock, ctx context.Context, file *File) error {
+			file.id = "999"
+			return nil
+		},
+
+		save: func(repo *fileRepositoryMock, ctx context.Context, file *File) error {
+			return nil
+		},
+	}
+
+	app := NewFileApplication(fileRepo, newContentStoreMock(&blobStoreMock{}, logger), dirApp, &groupApplicationMock{}, &EventBusMock{}, logger)
+
+	data := []byte("%PDF-1.7 some document")
+	options := CreateOptions{
+		Name: "example",
+		Meta: Metadata{
+			MetadataSizeKey: "1",
+			MetadataHashKey: "forged",
+			"custom":        "value",
+		},
+		Data: data,
+	}
+
+	file, err := app.Create(context.Background(), 999, &options)
+	if err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+		return
+	}
+
+	hash := sha256.Sum256(data)
+	want := Metadata{
+		MetadataSizeKey:        strconv.Itoa(len(data)),
+		MetadataHashKey:        hex.EncodeToString(hash[:]),
+		MetadataContentTypeKey: "application/pdf",
+		"custom":               "value",
+	}
+
+	for key, value := range want {
+		if got, _ := file.Value(key); got != value {
+			t.Errorf("%s: got value = %v, want = %v", key, got, value)
+		}
+	}
+
+	// server-managed metadata cannot be overwritten by clients
+	fileRepo.find = func(repo *fileRepositoryMock, ctx context.Context, id string) (*File, error) {
+		return file, nil
+	}
+
+	updated, err := app.Update(context.Background(), 999, file.id, &UpdateOptions{
+		Meta: Metadata{MetadataSizeKey: "1", "custom": ""},
+	})
+
+	if err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+		return
+	}
+
+	if got, _ := updated.Value(MetadataSizeKey); got != want[MetadataSizeKey] {
+		t.Errorf("got size = %v, want = %v", got, want[MetadataSizeKey])
+	}
+
+	if _, exists := updated.Value("custom"); exists {
+		t.Errorf("got custom = %v, want = %v", exists, false)
+	}
+}
+
 func TestCreateWithCustomMetadata(t *testing.T) {
 	logger, _ := zap.NewProduction()
 	defer logger.Sync()
@@ -229,7 +476,7 @@ func TestCreateWithCustomMetadata(t *testing.T) {
 			return nil
 		},
 	}
-	app := NewFileApplication(fileRepo, dirApp, &EventBusMock{}, logger)
+	app := NewFileApplication(fileRepo, newContentStoreMock(&blobStoreMock{}, logger), dirApp, &groupApplicationMock{}, &EventBusMock{}, logger)
 
 	userId := int32(999)
 	options := CreateOptions{
@@ -276,12 +523,12 @@ func TestReadWhenHasNoPermissions(t *testing.T) {
 	}
 
 	fileRepo := &fileRepositoryMock{}
-	app := NewFileApplication(fileRepo, dirApp, &EventBusMock{}, logger)
+	app := NewFileApplication(fileRepo, newContentStoreMock(&blobStoreMock{}, logger), dirApp, &groupApplicationMock{}, &EventBusMock{}, logger)
 
 	userId := int32(999)
 	fid := "testing"
 
-	if _, err := app.Get(context.Background(), userId, fid); errors.Is(err, fb.ErrNotAvailable) {
+	if _, err := app.Get(context.Background(), userId, fid, nil); errors.Is(err, fb.ErrNotAvailable) {
 		t.Errorf("got error = %v, want = %v", err, fb.ErrNotAvailable)
 	}
 }
@@ -304,8 +551,8 @@ func TestRead(t *testing.T) {
 	}
 
 	dirApp := &directoryApplicationMock{}
-	app := NewFileApplication(repo, dirApp, &EventBusMock{}, logger)
-	file, err := app.Get(context.Background(), 111, "")
+	app := NewFileApplication(repo, newContentStoreMock(&blobStoreMock{}, logger), dirApp, &groupApplicationMock{}, &EventBusMock{}, logger)
+	file, err := app.Get(context.Background(), 111, "", nil)
 	if err != nil {
 		t.Errorf("got error = %v, want = %v", err, nil)
 		return
@@ -316,7 +563,7 @@ func TestRead(t *testing.T) {
 		t.Errorf("got permissions = %+v, want = %+v", file.permissions, want)
 	}
 
-	file, err = app.Get(context.Background(), 333, "")
+	file, err = app.Get(context.Background(), 333, "", nil)
 	if err != nil {
 		t.Errorf("got error = %v, want = %v", err, nil)
 		return
@@ -327,7 +574,7 @@ func TestRead(t *testing.T) {
 		t.Errorf("got permissions = %+v, want = %+v", file.permissions, want)
 	}
 
-	file, err = app.Get(context.Background(), 222, "")
+	file, err = app.Get(context.Background(), 222, "", nil)
 	if err != nil {
 		t.Errorf("got error = %v, want = %v", err, nil)
 		return
@@ -338,13 +585,84 @@ func TestRead(t *testing.T) {
 		t.Errorf("got permission = %v, want = %v", file.permissions, want)
 	}
 
-	_, err = app.Get(context.Background(), 555, "")
+	_, err = app.Get(context.Background(), 555, "", nil)
 	if !errors.Is(err, fb.ErrNotAvailable) {
 		t.Errorf("got error = %v, want = %v", err, fb.ErrNotAvailable)
 		return
 	}
 }
 
+func TestGetWithView(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	repo := &fileRepositoryMock{
+		find: func(repo *fileRepositoryMock, ctx context.Context, id string) (*File, error) {
+			return &File{
+				id:          "123",
+				name:        "testing",
+				metadata:    Metadata{"color": "blue"},
+				permissions: map[int32]Permission{111: Owner, 222: Read},
+			}, nil
+		},
+	}
+
+	reads := 0
+	blobs := &blobStoreMock{
+		get: func(ctx context.Context, key string, w io.Writer) error {
+			reads++
+			_, err := w.Write([]byte("hello world"))
+			return err
+		},
+	}
+
+	app := NewFileApplication(repo, newContentStoreMock(blobs, logger), &directoryApplicationMock{}, &groupApplicationMock{}, &EventBusMock{}, logger)
+
+	tests := []struct {
+		view  View
+		name  string
+		data  string
+		reads int
+	}{
+		{view: FullView, name: "testing", data: "hello world", reads: 1},
+		{view: BasicView, name: "testing", data: "", reads: 0},
+		{view: DataOnlyView, name: "", data: "hello world", reads: 1},
+	}
+
+	for _, test := range tests {
+		reads = 0
+		file, err := app.Get(context.Background(), 222, "123", &GetOptions{View: test.view})
+		if err != nil {
+			t.Errorf("%v: got error = %v, want = %v", test.view, err, nil)
+			continue
+		}
+
+		if repo.view != test.view {
+			t.Errorf("%v: got repository view = %v, want = %v", test.view, repo.view, test.view)
+		}
+
+		if file.Name() != test.name {
+			t.Errorf("%v: got name = %v, want = %v", test.view, file.Name(), test.name)
+		}
+
+		if string(file.Data()) != test.data {
+			t.Errorf("%v: got data = %v, want = %v", test.view, string(file.Data()), test.data)
+		}
+
+		if reads != test.reads {
+			t.Errorf("%v: got reads = %v, want = %v", test.view, reads, test.reads)
+		}
+
+		if test.view == DataOnlyView && (len(file.permissions) > 0 || len(file.metadata) > 0) {
+			t.Errorf("%v: got file = %+v, want only id and data", test.view, file)
+		}
+	}
+
+	if _, err := app.Get(context.Background(), 333, "123", &GetOptions{View: BasicView}); !errors.Is(err, fb.ErrNotAvailable) {
+		t.Errorf("got error = %v, want = %v", err, fb.ErrNotAvailable)
+	}
+}
+
 func TestWriteWhenFileDoesNotExists(t *testing.T) {
 	logger, _ := zap.NewProduction()
 	defer logger.Sync()
@@ -356,7 +674,7 @@ func TestWriteWhenFileDoesNotExists(t *testing.T) {
 	}
 
 	fileRepo := &fileRepositoryMock{}
-	app := NewFileApplication(fileRepo, dirApp, &EventBusMock{}, logger)
+	app := NewFileApplication(fileRepo, newContentStoreMock(&blobStoreMock{}, logger), dirApp, &groupApplicationMock{}, &EventBusMock{}, logger)
 
 	userId := int32(999)
 	fid := "testing"
@@ -388,7 +706,7 @@ func TestWriteWhenHasNoPermissions(t *testing.T) {
 			}, nil
 		},
 	}
-	app := NewFileApplication(repo, dirApp, &EventBusMock{}, logger)
+	app := NewFileApplication(repo, newContentStoreMock(&blobStoreMock{}, logger), dirApp, &groupApplicationMock{}, &EventBusMock{}, logger)
 
 	fid := "testing"
 	if _, err := app.Update(context.Background(), 222, fid, &UpdateOptions{}); !errors.Is(err, fb.ErrNotAvailable) {
@@ -418,7 +736,7 @@ func TestWriteWhenCannotSave(t *testing.T) {
 	}
 
 	dirApp := &directoryApplicationMock{}
-	app := NewFileApplication(repo, dirApp, &EventBusMock{}, logger)
+	app := NewFileApplication(repo, newContentStoreMock(&blobStoreMock{}, logger), dirApp, &groupApplicationMock{}, &EventBusMock{}, logger)
 
 	fid := "testing"
 	if _, err := app.Update(context.Background(), 111, fid, &UpdateOptions{}); !errors.Is(err, fb.ErrUnknown) {
@@ -452,7 +770,7 @@ func TestWrite(t *testing.T) {
 	}
 
 	dirApp := &directoryApplicationMock{}
-	app := NewFileApplication(repo, dirApp, &EventBusMock{}, logger)
+	app := NewFileApplication(repo, newContentStoreMock(&blobStoreMock{}, logger), dirApp, &groupApplicationMock{}, &EventBusMock{}, logger)
 
 	fid := "testing"
 	options := UpdateOptions{
@@ -506,7 +824,7 @@ func TestWriteWithCustomMetadata(t *testing.T) {
 	}
 
 	dirApp := &directoryApplicationMock{}
-	app := NewFileApplication(repo, dirApp, &EventBusMock{}, logger)
+	app := NewFileApplication(repo, newContentStoreMock(&blobStoreMock{}, logger), dirApp, &groupApplicationMock{}, &EventBusMock{}, logger)
 
 	fid := "testing"
 	options := UpdateOptions{
@@ -545,7 +863,7 @@ func TestDeleteWhenFileDoesNotExists(t *testing.T) {
 	}
 
 	fileRepo := &fileRepositoryMock{}
-	app := NewFileApplication(fileRepo, dirApp, &EventBusMock{}, logger)
+	app := NewFileApplication(fileRepo, newContentStoreMock(&blobStoreMock{}, logger), dirApp, &groupApplicationMock{}, &EventBusMock{}, logger)
 
 	userId := int32(999)
 	fid := "testing"
@@ -560,7 +878,7 @@ func TestDeleteWhenHasNoPermissions(t *testing.T) {
 	defer logger.Sync()
 
 	dirApp := &directoryApplicationMock{
-		unregisterFile: func(ctx context.Context, uid int32, file *File) error {
+		trashFile: func(ctx context.Context, uid int32, file *File) error {
 			return nil
 		},
 	}
@@ -577,7 +895,7 @@ func TestDeleteWhenHasNoPermissions(t *testing.T) {
 			}, nil
 		},
 	}
-	app := NewFileApplication(repo, dirApp, &EventBusMock{}, logger)
+	app := NewFileApplication(repo, newContentStoreMock(&blobStoreMock{}, logger), dirApp, &groupApplicationMock{}, &EventBusMock{}, logger)
 
 	fid := "testing"
 	if _, err := app.Delete(context.Background(), 999, fid); !errors.Is(err, fb.ErrNotAvailable) {
@@ -585,15 +903,11 @@ func TestDeleteWhenHasNoPermissions(t *testing.T) {
 	}
 }
 
-func TestDeleteWhenCannotSave(t *testing.T) {
+func TestDeleteWhenCannotTrash(t *testing.T) {
 	logger, _ := zap.NewProduction()
 	defer logger.Sync()
 
-	dirApp := &directoryApplicationMock{
-		unregisterFile: func(ctx context.Context, uid int32, file *File) error {
-			return nil
-		},
-	}
+	dirApp := &directoryApplicationMock{}
 
 	repo := &fileRepositoryMock{
 		find: func(repo *fileRepositoryMock, ctx context.Context, id string) (*File, error) {
@@ -608,7 +922,7 @@ func TestDeleteWhenCannotSave(t *testing.T) {
 		},
 	}
 
-	app := NewFileApplication(repo, dirApp, &EventBusMock{}, logger)
+	app := NewFileApplication(repo, newContentStoreMock(&blobStoreMock{}, logger), dirApp, &groupApplicationMock{}, &EventBusMock{}, logger)
 
 	fid := "testing"
 	if _, err := app.Delete(context.Background(), 222, fid); !errors.Is(err, fb.ErrUnknown) {
@@ -616,18 +930,82 @@ func TestDeleteWhenCannotSave(t *testing.T) {
 	}
 }
 
-func TestDeleteWhenIsNotOwner(t *testing.T) {
+func TestDelete(t *testing.T) {
 	logger, _ := zap.NewProduction()
 	defer logger.Sync()
 
-	directoryRemoveFileMethodExecuted := false
-	dirApp := &directoryApplicationMock{
-		unregisterFile: func(ctx context.Context, uid int32, file *File) error {
-			directoryRemoveFileMethodExecuted = true
-			return nil
+	tests := []struct {
+		name        string
+		uid         int32
+		permissions map[int32]Permission
+	}{
+		{
+			name:        "when is not owner",
+			uid:         111,
+			permissions: map[int32]Permission{111: Read, 222: Owner},
+		},
+		{
+			name:        "when more than one owner",
+			uid:         111,
+			permissions: map[int32]Permission{111: Owner, 222: Owner},
+		},
+		{
+			name:        "when single owner",
+			uid:         111,
+			permissions: map[int32]Permission{111: Owner},
 		},
 	}
 
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			directoryTrashFileMethodExecuted := false
+			dirApp := &directoryApplicationMock{
+				trashFile: func(ctx context.Context, uid int32, file *File) error {
+					directoryTrashFileMethodExecuted = uid == test.uid
+					return nil
+				},
+			}
+
+			repo := &fileRepositoryMock{
+				find: func(repo *fileRepositoryMock, ctx context.Context, id string) (*File, error) {
+					return &File{
+						id:          "123",
+						name:        "testing",
+						metadata:    make(Metadata),
+						permissions: test.permissions,
+						data:        []byte{},
+						flags:       repo.flags,
+					}, nil
+				},
+			}
+
+			app := NewFileApplication(repo, newContentStoreMock(&blobStoreMock{}, logger), dirApp, &groupApplicationMock{}, &EventBusMock{}, logger)
+
+			file, err := app.Delete(context.Background(), test.uid, "123")
+			if err != nil {
+				t.Errorf("got error = %v, want = %v", err, nil)
+				return
+			}
+
+			if deletedAt, exists := file.metadata[MetadataDeletedAtKey]; exists {
+				t.Errorf("got deleted_at = %v, want = %v", deletedAt, nil)
+			}
+
+			if perm, exists := file.permissions[test.uid]; !exists || perm != test.permissions[test.uid] {
+				t.Errorf("got permissions = %v, want = %v", perm, test.permissions[test.uid])
+			}
+
+			if !directoryTrashFileMethodExecuted {
+				t.Errorf("directory's TrashFile method did not execute")
+			}
+		})
+	}
+}
+
+func TestUploadWhenHasNoPermissions(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
 	repo := &fileRepositoryMock{
 		find: func(repo *fileRepositoryMock, ctx context.Context, id string) (*File, error) {
 			return &File{
@@ -635,59 +1013,39 @@ func TestDeleteWhenIsNotOwner(t *testing.T) {
 				name:        "testing",
 				metadata:    make(Metadata),
 				permissions: map[int32]Permission{111: Read},
-				data:        []byte{},
 				flags:       repo.flags,
 			}, nil
 		},
-
-		save: func(repo *fileRepositoryMock, ctx context.Context, file *File) error {
-			return nil
-		},
-	}
-
-	app := NewFileApplication(repo, dirApp, &EventBusMock{}, logger)
-
-	fid := "testing"
-	file, err := app.Delete(context.Background(), 111, fid)
-	if err != nil {
-		t.Errorf("got error = %v, want = %v", err, nil)
 	}
 
-	if deletedAt, exists := file.metadata[MetadataDeletedAtKey]; exists {
-		t.Errorf("got deleted_at = %v, want = %v", deletedAt, nil)
-	}
+	dirApp := &directoryApplicationMock{}
+	app := NewFileApplication(repo, newContentStoreMock(&blobStoreMock{}, logger), dirApp, &groupApplicationMock{}, &EventBusMock{}, logger)
 
-	if perm, exists := file.permissions[111]; exists {
-		t.Errorf("got permissions = %v, want = %v", perm, nil)
+	options := UploadOptions{
+		Id: "123",
 	}
 
-	if !directoryRemoveFileMethodExecuted {
-		t.Errorf("directory's RemoveFile method did not execute")
+	data := bytes.NewReader([]byte("hello world"))
+	if _, err := app.Upload(context.Background(), 111, &options, data); !errors.Is(err, fb.ErrNotAvailable) {
+		t.Errorf("got error = %v, want = %v", err, fb.ErrNotAvailable)
 	}
 }
 
-func TestDeleteWhenMoreThanOneOwner(t *testing.T) {
+func TestUploadNewFile(t *testing.T) {
 	logger, _ := zap.NewProduction()
 	defer logger.Sync()
 
-	directoryRemoveFileMethodExecuted := false
 	dirApp := &directoryApplicationMock{
-		unregisterFile: func(ctx context.Context, uid int32, file *File) error {
-			directoryRemoveFileMethodExecuted = true
-			return nil
+		registerFile: func(ctx context.Context, uid int32, file *File) (string, error) {
+			return file.name, nil
 		},
 	}
 
+	var fileId string = "999"
 	repo := &fileRepositoryMock{
-		find: func(repo *fileRepositoryMock, ctx context.Context, id string) (*File, error) {
-			return &File{
-				id:          "123",
-				name:        "testing",
-				metadata:    make(Metadata),
-				permissions: map[int32]Permission{111: Owner, 222: Owner},
-				data:        []byte{},
-				flags:       repo.flags,
-			}, nil
+		create: func(repo *fileRepositoryMock, ctx context.Context, file *File) error {
+			file.id = fileId
+			return nil
 		},
 
 		save: func(repo *fileRepositoryMock, ctx context.Context, file *File) error {
@@ -695,84 +1053,844 @@ func TestDeleteWhenMoreThanOneOwner(t *testing.T) {
 		},
 	}
 
-	app := NewFileApplication(repo, dirApp, &EventBusMock{}, logger)
+	var written []byte
+	blobs := &blobStoreMock{
+		put: func(ctx context.Context, key string, r io.Reader) (int64, error) {
+			data, err := io.ReadAll(r)
+			written = append(written, data...)
+			return int64(len(data)), err
+		},
+	}
 
-	fid := "testing"
-	file, err := app.Delete(context.Background(), 111, fid)
-	if err != nil {
-		t.Errorf("got error = %v, want = %v", err, nil)
+	app := NewFileApplication(repo, newContentStoreMock(blobs, logger), dirApp, &groupApplicationMock{}, &EventBusMock{}, logger)
+
+	options := UploadOptions{
+		Name:      "example.test",
+		Directory: "path/to",
 	}
 
-	if deletedAt, exists := file.metadata[MetadataDeletedAtKey]; exists {
-		t.Errorf("got deleted_at = %v, want = %v", deletedAt, nil)
+	data := []byte("hello world")
+	file, err := app.Upload(context.Background(), 111, &options, bytes.NewReader(data))
+	if err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+		return
 	}
 
-	if perm, exists := file.permissions[111]; exists {
-		t.Errorf("got permissions = %v, want = %v", perm, nil)
+	if got := file.Id(); got != fileId {
+		t.Errorf("got id = %v, want = %v", got, fileId)
 	}
 
-	if perm, exists := file.permissions[222]; !exists || perm != Owner {
-		t.Errorf("got permissions = %v, want = %v", perm, Owner)
+	if perm := file.Permission(111); perm != Owner {
+		t.Errorf("got permission = %v, want = %v", perm, Owner)
 	}
 
-	if !directoryRemoveFileMethodExecuted {
-		t.Errorf("directory's RemoveFile method did not execute")
+	if !bytes.Equal(written, data) {
+		t.Errorf("got data = %v, want = %v", written, data)
 	}
 }
 
-func TestDeleteWhenSingleOwner(t *testing.T) {
+func TestUploadNewFileWhenReaderFails(t *testing.T) {
 	logger, _ := zap.NewProduction()
 	defer logger.Sync()
 
-	directoryRemoveFileMethodExecuted := false
+	registered := false
 	dirApp := &directoryApplicationMock{
-		unregisterFile: func(ctx context.Context, uid int32, file *File) error {
-			directoryRemoveFileMethodExecuted = true
-			return nil
+		registerFile: func(ctx context.Context, uid int32, file *File) (string, error) {
+			registered = true
+			return file.name, nil
 		},
 	}
 
+	var deleted *File
 	repo := &fileRepositoryMock{
-		find: func(repo *fileRepositoryMock, ctx context.Context, id string) (*File, error) {
-			return &File{
-				id:          "123",
-				name:        "testing",
-				metadata:    make(Metadata),
-				permissions: map[int32]Permission{111: Owner},
-				data:        []byte{},
-				flags:       repo.flags,
-			}, nil
+		create: func(repo *fileRepositoryMock, ctx context.Context, file *File) error {
+			file.id = "999"
+			return nil
 		},
 
 		delete: func(repo *fileRepositoryMock, ctx context.Context, file *File) error {
+			deleted = file
 			return nil
 		},
 	}
 
-	app := NewFileApplication(repo, dirApp, &EventBusMock{}, logger)
-
-	fid := "testing"
-	before := time.Now().Unix()
-	file, err := app.Delete(context.Background(), 111, fid)
-	after := time.Now().Unix()
-
-	if err != nil {
-		t.Errorf("got error = %v, want = %v", err, nil)
+	blobs := &blobStoreMock{
+		put: func(ctx context.Context, key string, r io.Reader) (int64, error) {
+			data, err := io.ReadAll(r)
+			return int64(len(data)), err
+		},
 	}
 
-	if deletedAt, exists := file.metadata[MetadataDeletedAtKey]; !exists {
-		t.Errorf("got deleted_at = %v, want > %v && < %v", deletedAt, before, after)
-	} else if unixDeletedAt, err := strconv.ParseInt(deletedAt, TimestampBase, 64); err != nil {
+	content := newContentStoreMock(blobs, logger)
+	content.SetQuotaStore(NewQuotaStore(&usageRepositoryStub{}, Quota{}, logger))
+	app := NewFileApplication(repo, content, dirApp, &groupApplicationMock{}, &EventBusMock{}, logger)
+
+	options := UploadOptions{
+		Name:      "example.test",
+		Directory: "path/to",
+	}
+
+	broken := errors.New("connection reset")
+	data := io.MultiReader(bytes.NewReader([]byte("hello")), iotest.ErrReader(broken))
+	if _, err := app.Upload(context.Background(), 111, &options, data); !errors.Is(err, broken) {
+		t.Errorf("got error = %v, want = %v", err, broken)
+	}
+
+	if registered {
+		t.Errorf("directory application's RegisterFile method did execute")
+	}
+
+	if deleted == nil || deleted.Id() != "999" {
+		t.Errorf("file repository's Delete method did not execute")
+	}
+
+	if usage, _ := app.GetUsage(context.Background(), 111); usage.Files() != 0 {
+		t.Errorf("got files = %v, want = %v", usage.Files(), 0)
+	}
+}
+
+func TestUploadExistingFileWhenSaveConflicts(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	ctx := context.Background()
+	blobs := NewLocalBlobStore(t.TempDir(), logger)
+	blobRepo := &blobRepositoryStub{keys: make(map[string]string), refs: make(map[string]int64)}
+	versionRepo := &versionRepositoryStub{}
+	usageRepo := &usageRepositoryStub{}
+
+	content := NewContentStore(blobs, versionRepo, 0, logger)
+	content.SetBlobRepository(blobRepo)
+	content.SetQuotaStore(NewQuotaStore(usageRepo, Quota{}, logger))
+
+	stored, _ := NewFile("123", "testing")
+	stored.AddPermission(111, Owner)
+	first, err := content.Write(ctx, 111, stored, bytes.NewReader([]byte("first")))
+	if err != nil {
+		t.Fatalf("got error = %v, want = %v", err, nil)
+	}
+
+	repo := &fileRepositoryMock{
+		find: func(repo *fileRepositoryMock, ctx context.Context, id string) (*File, error) {
+			return &File{
+				id:          id,
+				name:        "testing",
+				metadata:    make(Metadata),
+				permissions: map[int32]Permission{111: Owner},
+				blob:        first.blob,
+			}, nil
+		},
+
+		save: func(repo *fileRepositoryMock, ctx context.Context, file *File) error {
+			return fb.ErrConflict
+		},
+	}
+
+	app := NewFileApplication(repo, content, &directoryApplicationMock{}, &groupApplicationMock{}, &EventBusMock{}, logger)
+
+	options := UploadOptions{
+		Id: "123",
+	}
+
+	if _, err := app.Upload(ctx, 111, &options, bytes.NewReader([]byte("second"))); !errors.Is(err, fb.ErrConflict) {
+		t.Errorf("got error = %v, want = %v", err, fb.ErrConflict)
+	}
+
+	if versions, _ := versionRepo.FindByFileId(ctx, "123"); len(versions) != 1 {
+		t.Errorf("got versions = %v, want = %v", len(versions), 1)
+	}
+
+	// no file points to the discarded content, so it must not be kept
+	for key, refs := range blobRepo.refs {
+		if key == first.blob {
+			continue
+		}
+
+		if refs != 0 {
+			t.Errorf("got refs = %v, want = %v", refs, 0)
+		}
+
+		if _, err := blobs.Stat(ctx, key); !errors.Is(err, fb.ErrNotFound) {
+			t.Errorf("got error = %v, want = %v", err, fb.ErrNotFound)
+		}
+	}
+
+	if usage, _ := app.GetUsage(ctx, 111); usage.Bytes() != 5 {
+		t.Errorf("got bytes = %v, want = %v", usage.Bytes(), 5)
+	}
+}
+
+func TestUploadExistingFile(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	meta := make(Metadata)
+	createdAtValue := "000"
+	meta[MetadataCreatedAtKey] = createdAtValue
+
+	saved := false
+	repo := &fileRepositoryMock{
+		find: func(repo *fileRepositoryMock, ctx context.Context, id string) (*File, error) {
+			return &File{
+				id:          "123",
+				name:        "testing",
+				metadata:    meta,
+				permissions: map[int32]Permission{111: Owner, 222: Read, 333: Write | Read},
+				flags:       repo.flags,
+			}, nil
+		},
+
+		save: func(repo *fileRepositoryMock, ctx context.Context, file *File) error {
+			saved = true
+			return nil
+		},
+	}
+
+	var written []byte
+	blobs := &blobStoreMock{
+		put: func(ctx context.Context, key string, r io.Reader) (int64, error) {
+			data, err := io.ReadAll(r)
+			written = data
+			return int64(len(data)), err
+		},
+	}
+
+	dirApp := &directoryApplicationMock{}
+	app := NewFileApplication(repo, newContentStoreMock(blobs, logger), dirApp, &groupApplicationMock{}, &EventBusMock{}, logger)
+
+	options := UploadOptions{
+		Id: "123",
+	}
+
+	data := []byte("hello world")
+	file, err := app.Upload(context.Background(), 333, &options, bytes.NewReader(data))
+	if err != nil {
 		t.Errorf("got error = %v, want = %v", err, nil)
-	} else if unixDeletedAt > before || unixDeletedAt < after {
-		t.Errorf("got deleted_at = %v, want > %v && < %v", unixDeletedAt, before, after)
+		return
+	}
+
+	if !saved {
+		t.Errorf("file's Save method did not execute")
+	}
+
+	if !bytes.Equal(written, data) {
+		t.Errorf("got data = %v, want = %v", written, data)
 	}
 
-	if perm, exists := file.permissions[111]; !exists {
-		t.Errorf("got permissions = %v, want = %v", perm, Owner)
+	if createdAt, exists := file.metadata[MetadataCreatedAtKey]; !exists || createdAt != createdAtValue {
+		t.Errorf("got created_at = %v, want = %v", createdAt, createdAtValue)
+	}
+}
+
+func TestDownloadWhenHasNoPermissions(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	repo := &fileRepositoryMock{
+		find: func(repo *fileRepositoryMock, ctx context.Context, id string) (*File, error) {
+			return &File{
+				id:          "123",
+				name:        "testing",
+				metadata:    make(Metadata),
+				permissions: map[int32]Permission{111: Owner},
+				flags:       repo.flags,
+			}, nil
+		},
+	}
+
+	dirApp := &directoryApplicationMock{}
+	app := NewFileApplication(repo, newContentStoreMock(&blobStoreMock{}, logger), dirApp, &groupApplicationMock{}, &EventBusMock{}, logger)
+
+	var buf bytes.Buffer
+	if _, err := app.Download(context.Background(), 222, "123", &buf); !errors.Is(err, fb.ErrNotAvailable) {
+		t.Errorf("got error = %v, want = %v", err, fb.ErrNotAvailable)
+	}
+}
+
+func TestDownload(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	repo := &fileRepositoryMock{
+		find: func(repo *fileRepositoryMock, ctx context.Context, id string) (*File, error) {
+			return &File{
+				id:          "123",
+				name:        "testing",
+				metadata:    make(Metadata),
+				permissions: map[int32]Permission{111: Owner, 222: Read},
+				flags:       repo.flags,
+			}, nil
+		},
+	}
+
+	data := []byte("hello world")
+	blobs := &blobStoreMock{
+		get: func(ctx context.Context, key string, w io.Writer) error {
+			_, err := w.Write(data)
+			return err
+		},
+	}
+
+	dirApp := &directoryApplicationMock{}
+	app := NewFileApplication(repo, newContentStoreMock(blobs, logger), dirApp, &groupApplicationMock{}, &EventBusMock{}, logger)
+
+	var buf bytes.Buffer
+	file, err := app.Download(context.Background(), 222, "123", &buf)
+	if err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+		return
+	}
+
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Errorf("got data = %v, want = %v", buf.Bytes(), data)
+	}
+
+	if !file.protected {
+		t.Errorf("got protected = %v, want = %v", file.protected, true)
+	}
+}
+
+func TestListVersionsWhenHasNoPermissions(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	repo := &fileRepositoryMock{
+		find: func(repo *fileRepositoryMock, ctx context.Context, id string) (*File, error) {
+			return &File{
+				id:          "123",
+				name:        "testing",
+				metadata:    make(Metadata),
+				permissions: map[int32]Permission{111: Owner},
+				flags:       repo.flags,
+			}, nil
+		},
+	}
+
+	dirApp := &directoryApplicationMock{}
+	app := NewFileApplication(repo, newContentStoreMock(&blobStoreMock{}, logger), dirApp, &groupApplicationMock{}, &EventBusMock{}, logger)
+
+	if _, err := app.ListVersions(context.Background(), 222, "123"); !errors.Is(err, fb.ErrNotAvailable) {
+		t.Errorf("got error = %v, want = %v", err, fb.ErrNotAvailable)
+	}
+}
+
+func TestRestoreVersionWhenHasNoPermissions(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	repo := &fileRepositoryMock{
+		find: func(repo *fileRepositoryMock, ctx context.Context, id string) (*File, error) {
+			return &File{
+				id:          "123",
+				name:        "testing",
+				metadata:    make(Metadata),
+				permissions: map[int32]Permission{111: Owner, 222: Read},
+				flags:       repo.flags,
+			}, nil
+		},
+	}
+
+	dirApp := &directoryApplicationMock{}
+	app := NewFileApplication(repo, newContentStoreMock(&blobStoreMock{}, logger), dirApp, &groupApplicationMock{}, &EventBusMock{}, logger)
+
+	if _, err := app.RestoreVersion(context.Background(), 222, "123", "456"); !errors.Is(err, fb.ErrNotAvailable) {
+		t.Errorf("got error = %v, want = %v", err, fb.ErrNotAvailable)
+	}
+}
+
+func TestUpdateRecordsVersion(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	repo := &fileRepositoryMock{
+		find: func(repo *fileRepositoryMock, ctx context.Context, id string) (*File, error) {
+			return &File{
+				id:          "123",
+				name:        "testing",
+				metadata:    make(Metadata),
+				permissions: map[int32]Permission{111: Owner, 222: Read | Write},
+				flags:       repo.flags,
+			}, nil
+		},
+
+		save: func(repo *fileRepositoryMock, ctx context.Context, file *File) error {
+			return nil
+		},
+	}
+
+	var recorded *Version
+	versionRepo := &versionRepositoryMock{
+		create: func(ctx context.Context, version *Version) error {
+			recorded = version
+			return nil
+		},
+	}
+
+	content := NewContentStore(&blobStoreMock{}, versionRepo, 0, logger)
+	app := NewFileApplication(repo, content, &directoryApplicationMock{}, &groupApplicationMock{}, &EventBusMock{}, logger)
+
+	data := []byte("hello world")
+	file, err := app.Update(context.Background(), 222, "123", &UpdateOptions{Data: data})
+	if err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+		return
+	}
+
+	if recorded == nil {
+		t.Errorf("version repository's Create method did not execute")
+		return
+	}
+
+	if recorded.Author() != 222 || recorded.FileId() != file.Id() || recorded.Size() != int64(len(data)) {
+		t.Errorf("got version = %+v, want author = %v, file_id = %v, size = %v", recorded, 222, file.Id(), len(data))
+	}
+
+	if file.Blob() != recorded.blob {
+		t.Errorf("got blob = %v, want = %v", file.Blob(), recorded.blob)
+	}
+}
+
+func TestUpdateRetention(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	repo := &fileRepositoryMock{
+		find: func(repo *fileRepositoryMock, ctx context.Context, id string) (*File, error) {
+			return &File{
+				id:          "123",
+				name:        "testing",
+				metadata:    Metadata{MetadataRetentionKey: "5"},
+				permissions: map[int32]Permission{111: Owner, 222: Read | Write},
+				flags:       repo.flags,
+			}, nil
+		},
+
+		save: func(repo *fileRepositoryMock, ctx context.Context, file *File) error {
+			return nil
+		},
+	}
+
+	app := NewFileApplication(repo, newContentStoreMock(&blobStoreMock{}, logger), &directoryApplicationMock{}, &groupApplicationMock{}, &EventBusMock{}, logger)
+
+	tests := []struct {
+		name    string
+		uid     int32
+		options UpdateOptions
+		want    string
+	}{
+		{
+			name:    "owner sets retention",
+			uid:     111,
+			options: UpdateOptions{Meta: Metadata{MetadataRetentionKey: "2"}},
+			want:    "2",
+		},
+		{
+			name:    "shared user sets retention",
+			uid:     222,
+			options: UpdateOptions{Meta: Metadata{MetadataRetentionKey: "1", "color": "red"}},
+			want:    "5",
+		},
+		{
+			name:    "shared user replaces metadata",
+			uid:     222,
+			options: UpdateOptions{Meta: Metadata{"color": "red"}, ReplaceMeta: true},
+			want:    "5",
+		},
+	}
+
+	for _, test := range tests {
+		file, err := app.Update(context.Background(), test.uid, "123", &test.options)
+		if err != nil {
+			t.Errorf("%s: got error = %v, want = %v", test.name, err, nil)
+			continue
+		}
+
+		if got, _ := file.Value(MetadataRetentionKey); got != test.want {
+			t.Errorf("%s: got retention = %v, want = %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestWriteWhenRevisionIsStale(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	saved := false
+	repo := &fileRepositoryMock{
+		find: func(repo *fileRepositoryMock, ctx context.Context, id string) (*File, error) {
+			return &File{
+				id:          "123",
+				name:        "testing",
+				metadata:    make(Metadata),
+				permissions: map[int32]Permission{111: Owner},
+				flags:       repo.flags,
+				revision:    3,
+			}, nil
+		},
+
+		save: func(repo *fileRepositoryMock, ctx context.Context, file *File) error {
+			saved = true
+			return nil
+		},
+	}
+
+	app := NewFileApplication(repo, newContentStoreMock(&blobStoreMock{}, logger), &directoryApplicationMock{}, &groupApplicationMock{}, &EventBusMock{}, logger)
+
+	options := UpdateOptions{
+		Name:     "another",
+		Revision: 2,
+	}
+
+	if _, err := app.Update(context.Background(), 111, "123", &options); !errors.Is(err, fb.ErrConflict) {
+		t.Errorf("got error = %v, want = %v", err, fb.ErrConflict)
+	}
+
+	if saved {
+		t.Errorf("file's Save method did execute")
+	}
+
+	options.Revision = 3
+	if _, err := app.Update(context.Background(), 111, "123", &options); err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+	}
+
+	if !saved {
+		t.Errorf("file's Save method did not execute")
+	}
+}
+
+func TestShareWhenIsNotOwner(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	repo := &fileRepositoryMock{
+		find: func(repo *fileRepositoryMock, ctx context.Context, id string) (*File, error) {
+			return &File{
+				id:          "123",
+				name:        "testing",
+				metadata:    make(Metadata),
+				permissions: map[int32]Permission{111: Owner, 222: Read | Write},
+				flags:       repo.flags,
+			}, nil
+		},
+	}
+
+	app := NewFileApplication(repo, newContentStoreMock(&blobStoreMock{}, logger), &directoryApplicationMock{}, &groupApplicationMock{}, &EventBusMock{}, logger)
+
+	if _, err := app.Share(context.Background(), 222, "123", 333, Read); !errors.Is(err, fb.ErrNotAvailable) {
+		t.Errorf("got error = %v, want = %v", err, fb.ErrNotAvailable)
+	}
+}
+
+func TestShare(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	f := &File{
+		id:          "123",
+		name:        "testing",
+		directory:   "/path/to",
+		metadata:    make(Metadata),
+		permissions: map[int32]Permission{111: Owner},
+	}
+
+	repo := &fileRepositoryMock{
+		find: func(repo *fileRepositoryMock, ctx context.Context, id string) (*File, error) {
+			return f, nil
+		},
+
+		save: func(repo *fileRepositoryMock, ctx context.Context, file *File) error {
+			return nil
+		},
+	}
+
+	registered := make(map[int32]string)
+	dirApp := &directoryApplicationMock{
+		registerFile: func(ctx context.Context, uid int32, file *File) (string, error) {
+			registered[uid] = file.Directory()
+			return file.Name(), nil
+		},
+	}
+
+	app := NewFileApplication(repo, newContentStoreMock(&blobStoreMock{}, logger), dirApp, &groupApplicationMock{}, &EventBusMock{}, logger)
+
+	if _, err := app.Share(context.Background(), 111, "123", 222, Read); err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+		return
+	}
+
+	if _, err := app.Share(context.Background(), 111, "123", 222, Write); err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+		return
+	}
+
+	if perm := f.Permission(222); perm != Read|Write {
+		t.Errorf("got permission = %v, want = %v", perm, Read|Write)
+	}
+
+	if got := len(registered); got != 1 {
+		t.Errorf("got registrations = %v, want = %v", got, 1)
+	}
+
+	if got := registered[222]; got != SharedDirectory {
+		t.Errorf("got directory = %v, want = %v", got, SharedDirectory)
+	}
+
+	if got := f.Directory(); got != "/path/to" {
+		t.Errorf("got directory = %v, want = %v", got, "/path/to")
+	}
+}
+
+func TestUnshare(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	f := &File{
+		id:          "123",
+		name:        "testing",
+		metadata:    make(Metadata),
+		permissions: map[int32]Permission{111: Owner, 222: Read | Write},
+	}
+
+	repo := &fileRepositoryMock{
+		find: func(repo *fileRepositoryMock, ctx context.Context, id string) (*File, error) {
+			return f, nil
+		},
+
+		save: func(repo *fileRepositoryMock, ctx context.Context, file *File) error {
+			return nil
+		},
+	}
+
+	unregistered := make(map[int32]bool)
+	dirApp := &directoryApplicationMock{
+		unregisterFile: func(ctx context.Context, uid int32, file *File) error {
+			unregistered[uid] = true
+			return nil
+		},
+	}
+
+	app := NewFileApplication(repo, newContentStoreMock(&blobStoreMock{}, logger), dirApp, &groupApplicationMock{}, &EventBusMock{}, logger)
+
+	if _, err := app.Unshare(context.Background(), 111, "123", 222, Write); err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+		return
+	}
+
+	if perm := f.Permission(222); perm != Read {
+		t.Errorf("got permission = %v, want = %v", perm, Read)
+	}
+
+	if unregistered[222] {
+		t.Errorf("directory's UnregisterFile method did execute")
+	}
+
+	if _, err := app.Unshare(context.Background(), 111, "123", 222, 0); err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+		return
+	}
+
+	if perm := f.Permission(222); perm != 0 {
+		t.Errorf("got permission = %v, want = %v", perm, 0)
+	}
+
+	if !unregistered[222] {
+		t.Errorf("directory's UnregisterFile method did not execute")
+	}
+
+	if _, err := app.Unshare(context.Background(), 111, "123", 111, Owner); !errors.Is(err, fb.ErrNotAvailable) {
+		t.Errorf("got error = %v, want = %v", err, fb.ErrNotAvailable)
+	}
+}
+
+func TestShareWhenRegisterFails(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	f := &File{
+		id:          "123",
+		name:        "testing",
+		metadata:    make(Metadata),
+		permissions: map[int32]Permission{111: Owner},
+	}
+
+	saved := make(map[int32]Permission)
+	repo := &fileRepositoryMock{
+		find: func(repo *fileRepositoryMock, ctx context.Context, id string) (*File, error) {
+			return f, nil
+		},
+
+		save: func(repo *fileRepositoryMock, ctx context.Context, file *File) error {
+			saved[222] = file.Permission(222)
+			return nil
+		},
+	}
+
+	dirApp := &directoryApplicationMock{
+		registerFile: func(ctx context.Context, uid int32, file *File) (string, error) {
+			return "", fb.ErrUnknown
+		},
+	}
+
+	app := NewFileApplication(repo, newContentStoreMock(&blobStoreMock{}, logger), dirApp, &groupApplicationMock{}, &EventBusMock{}, logger)
+
+	if _, err := app.Share(context.Background(), 111, "123", 222, Read|Owner); !errors.Is(err, fb.ErrUnknown) {
+		t.Errorf("got error = %v, want = %v", err, fb.ErrUnknown)
+	}
+
+	if perm := saved[222]; perm != 0 {
+		t.Errorf("got permission = %v, want = %v", perm, 0)
+	}
+}
+
+func TestShareWithGroupWhenOwnerPermission(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	repo := &fileRepositoryMock{
+		find: func(repo *fileRepositoryMock, ctx context.Context, id string) (*File, error) {
+			t.Errorf("unexpected call to find")
+			return nil, fb.ErrUnknown
+		},
+	}
+
+	app := NewFileApplication(repo, newContentStoreMock(&blobStoreMock{}, logger), &directoryApplicationMock{}, &groupApplicationMock{}, &EventBusMock{}, logger)
+
+	if _, err := app.ShareWithGroup(context.Background(), 111, "123", "abc", Read|Owner); !errors.Is(err, fb.ErrInvalidFormat) {
+		t.Errorf("got error = %v, want = %v", err, fb.ErrInvalidFormat)
+	}
+}
+
+func TestShareWithGroupWhenGroupDoesNotExist(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	repo := &fileRepositoryMock{
+		find: func(repo *fileRepositoryMock, ctx context.Context, id string) (*File, error) {
+			return &File{
+				id:          id,
+				name:        "testing",
+				metadata:    make(Metadata),
+				permissions: map[int32]Permission{111: Owner},
+			}, nil
+		},
+		save: func(repo *fileRepositoryMock, ctx context.Context, file *File) error {
+			t.Errorf("unexpected call to save")
+			return nil
+		},
+	}
+
+	groupApp := &groupApplicationMock{
+		exists: func(ctx context.Context, gid string) (bool, error) {
+			return false, nil
+		},
+	}
+
+	app := NewFileApplication(repo, newContentStoreMock(&blobStoreMock{}, logger), &directoryApplicationMock{}, groupApp, &EventBusMock{}, logger)
+
+	if _, err := app.ShareWithGroup(context.Background(), 111, "123", "abc", Read); !errors.Is(err, fb.ErrNotFound) {
+		t.Errorf("got error = %v, want = %v", err, fb.ErrNotFound)
+	}
+}
+
+func TestPermissionsThroughGroup(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	f := &File{
+		id:          "123",
+		name:        "testing",
+		metadata:    make(Metadata),
+		permissions: map[int32]Permission{111: Owner},
+	}
+
+	repo := &fileRepositoryMock{
+		find: func(repo *fileRepositoryMock, ctx context.Context, id string) (*File, error) {
+			// protecting fields must not alter the stored file
+			found := *f
+			found.permissions = make(map[int32]Permission)
+			for uid, perm := range f.permissions {
+				found.permissions[uid] = perm
+			}
+
+			found.groups = make(map[string]Permission)
+			for gid, perm := range f.groups {
+				found.groups[gid] = perm
+			}
+
+			return &found, nil
+		},
+
+		save: func(repo *fileRepositoryMock, ctx context.Context, file *File) error {
+			f = file
+			return nil
+		},
+	}
+
+	groupApp := &groupApplicationMock{
+		memberOf: func(ctx context.Context, uid int32) ([]string, error) {
+			if uid == 222 {
+				return []string{"abc"}, nil
+			}
+
+			return []string{}, nil
+		},
+	}
+
+	var trashedBy []int32
+	dirApp := &directoryApplicationMock{
+		trashFile: func(ctx context.Context, uid int32, file *File) error {
+			trashedBy = append(trashedBy, uid)
+			return nil
+		},
+	}
+
+	app := NewFileApplication(repo, newContentStoreMock(&blobStoreMock{}, logger), dirApp, groupApp, &EventBusMock{}, logger)
+
+	if _, err := app.ShareWithGroup(context.Background(), 111, "123", "abc", Read); err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+		return
+	}
+
+	if _, err := app.Get(context.Background(), 222, "123", nil); err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+	}
+
+	if _, err := app.Get(context.Background(), 333, "123", nil); !errors.Is(err, fb.ErrNotAvailable) {
+		t.Errorf("got error = %v, want = %v", err, fb.ErrNotAvailable)
+	}
+
+	if _, err := app.Update(context.Background(), 222, "123", &UpdateOptions{Name: "other"}); !errors.Is(err, fb.ErrNotAvailable) {
+		t.Errorf("got error = %v, want = %v", err, fb.ErrNotAvailable)
+	}
+
+	if _, err := app.Delete(context.Background(), 333, "123"); !errors.Is(err, fb.ErrNotAvailable) {
+		t.Errorf("got error = %v, want = %v", err, fb.ErrNotAvailable)
+	}
+
+	if _, err := app.Delete(context.Background(), 222, "123"); !errors.Is(err, fb.ErrNotAvailable) {
+		t.Errorf("got error = %v, want = %v", err, fb.ErrNotAvailable)
+	}
+
+	if _, err := app.ShareWithGroup(context.Background(), 111, "123", "abc", Write); err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+		return
+	}
+
+	if _, err := app.Delete(context.Background(), 222, "123"); err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+	}
+
+	// the file is in no directory of the group member, but in those of its owners
+	if len(trashedBy) != 1 || trashedBy[0] != 111 {
+		t.Errorf("got trashed by = %v, want = %v", trashedBy, []int32{111})
+	}
+
+	if _, err := app.Update(context.Background(), 222, "123", &UpdateOptions{Name: "other"}); err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
 	}
 
-	if !directoryRemoveFileMethodExecuted {
-		t.Errorf("directory's RemoveFile method did not execute")
+	if got := f.Name(); got != "other" {
+		t.Errorf("got name = %v, want = %v", got, "other")
 	}
 }
@@ -0,0 +1,144 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	fb "github.com/alvidir/filebrowser"
+	"go.uber.org/zap"
+)
+
+type BlobInfo struct {
+	Key       string
+	Size      int64
+	UpdatedAt time.Time
+}
+
+// LocalBlobStore stores each blob as a regular file inside the root directory.
+type LocalBlobStore struct {
+	root   string
+	logger *zap.Logger
+}
+
+func NewLocalBlobStore(root string, logger *zap.Logger) *LocalBlobStore {
+	return &LocalBlobStore{
+		root:   root,
+		logger: logger,
+	}
+}
+
+// path returns the location of the blob with the given key, which is always kept inside the root directory.
+func (store *LocalBlobStore) path(key string) string {
+	return filepath.Join(store.root, filepath.FromSlash(path.Clean("/"+key)))
+}
+
+func (store *LocalBlobStore) Put(ctx context.Context, key string, r io.Reader) (int64, error) {
+	fp := store.path(key)
+	if err := os.MkdirAll(filepath.Dir(fp), 0750); err != nil {
+		store.logger.Error("creating blob directory",
+			zap.String("key", key),
+			zap.Error(err))
+
+		return 0, fb.ErrUnknown
+	}
+
+	// write into a temporary file first, so no partial blob is ever visible
+	tmp, err := os.CreateTemp(filepath.Dir(fp), ".blob-*")
+	if err != nil {
+		store.logger.Error("creating temporary blob",
+			zap.String("key", key),
+			zap.Error(err))
+
+		return 0, fb.ErrUnknown
+	}
+
+	defer os.Remove(tmp.Name())
+
+	size, err := io.Copy(tmp, r)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		store.logger.Error("writing blob",
+			zap.String("key", key),
+			zap.Error(err))
+
+		return 0, fb.ErrUnknown
+	}
+
+	if err := os.Rename(tmp.Name(), fp); err != nil {
+		store.logger.Error("renaming temporary blob",
+			zap.String("key", key),
+			zap.Error(err))
+
+		return 0, fb.ErrUnknown
+	}
+
+	return size, nil
+}
+
+func (store *LocalBlobStore) Get(ctx context.Context, key string, w io.Writer) error {
+	f, err := os.Open(store.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return fb.ErrNotFound
+	} else if err != nil {
+		store.logger.Error("opening blob",
+			zap.String("key", key),
+			zap.Error(err))
+
+		return fb.ErrUnknown
+	}
+
+	defer f.Close()
+
+	if _, err := io.Copy(w, f); err != nil {
+		store.logger.Error("reading blob",
+			zap.String("key", key),
+			zap.Error(err))
+
+		return fb.ErrUnknown
+	}
+
+	return nil
+}
+
+func (store *LocalBlobStore) Delete(ctx context.Context, key string) error {
+	err := os.Remove(store.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return fb.ErrNotFound
+	} else if err != nil {
+		store.logger.Error("removing blob",
+			zap.String("key", key),
+			zap.Error(err))
+
+		return fb.ErrUnknown
+	}
+
+	return nil
+}
+
+func (store *LocalBlobStore) Stat(ctx context.Context, key string) (*BlobInfo, error) {
+	info, err := os.Stat(store.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fb.ErrNotFound
+	} else if err != nil {
+		store.logger.Error("getting blob info",
+			zap.String("key", key),
+			zap.Error(err))
+
+		return nil, fb.ErrUnknown
+	}
+
+	return &BlobInfo{
+		Key:       key,
+		Size:      info.Size(),
+		UpdatedAt: info.ModTime(),
+	}, nil
+}
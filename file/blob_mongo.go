@@ -0,0 +1,211 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	fb "github.com/alvidir/filebrowser"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+)
+
+const (
+	MongoBlobCollectionName = "blobs"
+)
+
+type mongoBlob struct {
+	Key       string             `bson:"_id"`
+	Size      int64              `bson:"size"`
+	UpdatedAt time.Time          `bson:"updated_at"`
+	Data      []byte             `bson:"data,omitempty"`
+	Content   primitive.ObjectID `bson:"content,omitempty"` // id of the GridFS file holding the data, if any
+}
+
+// MongoBlobStore stores each blob as a document of the blobs collection. Any blob larger than the given
+// threshold gets its data moved into GridFS, keeping the document itself small.
+type MongoBlobStore struct {
+	conn      *mongo.Collection
+	bucket    *gridfs.Bucket
+	threshold int
+	logger    *zap.Logger
+}
+
+func NewMongoBlobStore(db *mongo.Database, threshold int, logger *zap.Logger) *MongoBlobStore {
+	bucket, err := gridfs.NewBucket(db)
+	if err != nil {
+		logger.Fatal("creating gridfs bucket",
+			zap.Error(err))
+	}
+
+	return &MongoBlobStore{
+		conn:      db.Collection(MongoBlobCollectionName),
+		bucket:    bucket,
+		threshold: threshold,
+		logger:    logger,
+	}
+}
+
+func (store *MongoBlobStore) Put(ctx context.Context, key string, r io.Reader) (int64, error) {
+	// read no more than required to know if the data fits into the document
+	data, err := io.ReadAll(io.LimitReader(r, int64(store.threshold)+1))
+	if err != nil {
+		store.logger.Error("reading blob data",
+			zap.String("key", key),
+			zap.Error(err))
+
+		return 0, err
+	}
+
+	blob := mongoBlob{
+		Key:       key,
+		Size:      int64(len(data)),
+		UpdatedAt: time.Now(),
+		Data:      data,
+	}
+
+	if len(data) > store.threshold {
+		reader := &countingReader{Reader: io.MultiReader(bytes.NewReader(data), r)}
+		if blob.Content, err = store.uploadContent(key, reader); err != nil {
+			return 0, err
+		}
+
+		blob.Size = reader.count
+		blob.Data = nil
+	}
+
+	opts := options.FindOneAndReplace().
+		SetUpsert(true).
+		SetProjection(bson.D{{Key: "content", Value: 1}})
+
+	var old mongoBlob
+	err = store.conn.FindOneAndReplace(ctx, bson.M{"_id": key}, blob, opts).Decode(&old)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		store.logger.Error("performing find one and replace on mongo",
+			zap.String("key", key),
+			zap.Error(err))
+
+		store.deleteContent(blob.Content)
+		return 0, fb.ErrUnknown
+	}
+
+	// the previous content, if any, has been replaced
+	store.deleteContent(old.Content)
+	return blob.Size, nil
+}
+
+func (store *MongoBlobStore) Get(ctx context.Context, key string, w io.Writer) error {
+	var blob mongoBlob
+	err := store.conn.FindOne(ctx, bson.M{"_id": key}).Decode(&blob)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return fb.ErrNotFound
+	} else if err != nil {
+		store.logger.Error("performing find one on mongo",
+			zap.String("key", key),
+			zap.Error(err))
+
+		return fb.ErrUnknown
+	}
+
+	if !blob.Content.IsZero() {
+		return store.downloadContent(blob.Content, w)
+	}
+
+	if _, err := w.Write(blob.Data); err != nil {
+		store.logger.Error("writing blob data",
+			zap.String("key", key),
+			zap.Error(err))
+
+		return err
+	}
+
+	return nil
+}
+
+func (store *MongoBlobStore) Delete(ctx context.Context, key string) error {
+	opts := options.FindOneAndDelete().SetProjection(bson.D{{Key: "content", Value: 1}})
+
+	var old mongoBlob
+	err := store.conn.FindOneAndDelete(ctx, bson.M{"_id": key}, opts).Decode(&old)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return fb.ErrNotFound
+	} else if err != nil {
+		store.logger.Error("performing find one and delete on mongo",
+			zap.String("key", key),
+			zap.Error(err))
+
+		return fb.ErrUnknown
+	}
+
+	store.deleteContent(old.Content)
+	return nil
+}
+
+func (store *MongoBlobStore) Stat(ctx context.Context, key string) (*BlobInfo, error) {
+	// exclude data field from being loaded
+	opts := options.FindOne().SetProjection(bson.D{{Key: "data", Value: 0}})
+
+	var blob mongoBlob
+	err := store.conn.FindOne(ctx, bson.M{"_id": key}, opts).Decode(&blob)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, fb.ErrNotFound
+	} else if err != nil {
+		store.logger.Error("performing find one on mongo",
+			zap.String("key", key),
+			zap.Error(err))
+
+		return nil, fb.ErrUnknown
+	}
+
+	return &BlobInfo{
+		Key:       blob.Key,
+		Size:      blob.Size,
+		UpdatedAt: blob.UpdatedAt,
+	}, nil
+}
+
+// uploadContent stores into GridFS all the data read from r, returning the id of the resulting GridFS file.
+func (store *MongoBlobStore) uploadContent(filename string, r io.Reader) (primitive.ObjectID, error) {
+	content, err := store.bucket.UploadFromStream(filename, r)
+	if err != nil {
+		store.logger.Error("uploading content to gridfs",
+			zap.String("filename", filename),
+			zap.Error(err))
+
+		return primitive.NilObjectID, fb.ErrUnknown
+	}
+
+	return content, nil
+}
+
+func (store *MongoBlobStore) downloadContent(content primitive.ObjectID, w io.Writer) error {
+	if _, err := store.bucket.DownloadToStream(content, w); err != nil {
+		store.logger.Error("downloading content from gridfs",
+			zap.String("content_id", content.Hex()),
+			zap.Error(err))
+
+		return fb.ErrUnknown
+	}
+
+	return nil
+}
+
+// deleteContent removes from GridFS the file with the given id, if any. Since the content is no longer
+// referenced by any document, a failure here is logged but never reported to the caller.
+func (store *MongoBlobStore) deleteContent(content primitive.ObjectID) {
+	if content.IsZero() {
+		return
+	}
+
+	if err := store.bucket.Delete(content); err != nil {
+		store.logger.Warn("deleting content from gridfs",
+			zap.String("content_id", content.Hex()),
+			zap.Error(err))
+	}
+}
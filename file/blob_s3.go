@@ -0,0 +1,114 @@
+package file
+
+import (
+	"context"
+	"io"
+
+	fb "github.com/alvidir/filebrowser"
+	"github.com/minio/minio-go/v7"
+	"go.uber.org/zap"
+)
+
+const (
+	S3PartSize    = 16 * 1024 * 1024 // 16 MiB
+	s3NoSuchKey   = "NoSuchKey"
+	s3NotFoundKey = "NotFound"
+)
+
+// S3BlobStore stores each blob as an object of the given bucket in any S3-compatible service.
+type S3BlobStore struct {
+	client *minio.Client
+	bucket string
+	logger *zap.Logger
+}
+
+func NewS3BlobStore(client *minio.Client, bucket string, logger *zap.Logger) *S3BlobStore {
+	return &S3BlobStore{
+		client: client,
+		bucket: bucket,
+		logger: logger,
+	}
+}
+
+func isS3NotFound(err error) bool {
+	code := minio.ToErrorResponse(err).Code
+	return code == s3NoSuchKey || code == s3NotFoundKey
+}
+
+func (store *S3BlobStore) Put(ctx context.Context, key string, r io.Reader) (int64, error) {
+	opts := minio.PutObjectOptions{
+		PartSize: S3PartSize,
+	}
+
+	info, err := store.client.PutObject(ctx, store.bucket, key, r, -1, opts)
+	if err != nil {
+		store.logger.Error("putting object into s3",
+			zap.String("bucket", store.bucket),
+			zap.String("key", key),
+			zap.Error(err))
+
+		return 0, fb.ErrUnknown
+	}
+
+	return info.Size, nil
+}
+
+func (store *S3BlobStore) Get(ctx context.Context, key string, w io.Writer) error {
+	object, err := store.client.GetObject(ctx, store.bucket, key, minio.GetObjectOptions{})
+	if err == nil {
+		defer object.Close()
+		_, err = io.Copy(w, object)
+	}
+
+	if isS3NotFound(err) {
+		return fb.ErrNotFound
+	} else if err != nil {
+		store.logger.Error("getting object from s3",
+			zap.String("bucket", store.bucket),
+			zap.String("key", key),
+			zap.Error(err))
+
+		return fb.ErrUnknown
+	}
+
+	return nil
+}
+
+func (store *S3BlobStore) Delete(ctx context.Context, key string) error {
+	// s3 does not report missing objects on removal
+	if _, err := store.Stat(ctx, key); err != nil {
+		return err
+	}
+
+	err := store.client.RemoveObject(ctx, store.bucket, key, minio.RemoveObjectOptions{})
+	if err != nil {
+		store.logger.Error("removing object from s3",
+			zap.String("bucket", store.bucket),
+			zap.String("key", key),
+			zap.Error(err))
+
+		return fb.ErrUnknown
+	}
+
+	return nil
+}
+
+func (store *S3BlobStore) Stat(ctx context.Context, key string) (*BlobInfo, error) {
+	info, err := store.client.StatObject(ctx, store.bucket, key, minio.StatObjectOptions{})
+	if isS3NotFound(err) {
+		return nil, fb.ErrNotFound
+	} else if err != nil {
+		store.logger.Error("getting object info from s3",
+			zap.String("bucket", store.bucket),
+			zap.String("key", key),
+			zap.Error(err))
+
+		return nil, fb.ErrUnknown
+	}
+
+	return &BlobInfo{
+		Key:       key,
+		Size:      info.Size,
+		UpdatedAt: info.LastModified,
+	}, nil
+}
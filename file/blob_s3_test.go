@@ -0,0 +1,43 @@
+package file
+
+import (
+	"context"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/johannesboyne/gofakes3"
+	"github.com/johannesboyne/gofakes3/backend/s3mem"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"go.uber.org/zap"
+)
+
+func TestS3BlobStore(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	// a local stand-in for any S3-compatible service, served over tls since the fake does not support
+	// the streaming signatures used by the client on plain http
+	server := httptest.NewTLSServer(gofakes3.New(s3mem.New()).Server())
+	defer server.Close()
+
+	endpoint, _ := url.Parse(server.URL)
+	client, err := minio.New(endpoint.Host, &minio.Options{
+		Creds:     credentials.NewStaticV4("access_key", "secret_key", ""),
+		Secure:    true,
+		Transport: server.Client().Transport,
+	})
+
+	if err != nil {
+		t.Fatalf("got error = %v, want = %v", err, nil)
+	}
+
+	bucket := "filebrowser"
+	if err := client.MakeBucket(context.Background(), bucket, minio.MakeBucketOptions{}); err != nil {
+		t.Fatalf("got error = %v, want = %v", err, nil)
+	}
+
+	store := NewS3BlobStore(client, bucket, logger)
+	testBlobStore(t, store)
+}
@@ -0,0 +1,95 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	fb "github.com/alvidir/filebrowser"
+	"go.uber.org/zap"
+)
+
+func testBlobStore(t *testing.T, store BlobStore) {
+	ctx := context.Background()
+	key := "64a1b2c3d4e5f60718293a4b"
+	data := []byte("hello world")
+
+	if size, err := store.Put(ctx, key, bytes.NewReader(data)); err != nil {
+		t.Fatalf("got error = %v, want = %v", err, nil)
+	} else if size != int64(len(data)) {
+		t.Errorf("got size = %v, want = %v", size, len(data))
+	}
+
+	var buf bytes.Buffer
+	if err := store.Get(ctx, key, &buf); err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+	} else if !bytes.Equal(buf.Bytes(), data) {
+		t.Errorf("got data = %v, want = %v", buf.Bytes(), data)
+	}
+
+	if info, err := store.Stat(ctx, key); err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+	} else if info.Size != int64(len(data)) {
+		t.Errorf("got size = %v, want = %v", info.Size, len(data))
+	}
+
+	override := []byte("bye world")
+	if _, err := store.Put(ctx, key, bytes.NewReader(override)); err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+	}
+
+	buf.Reset()
+	if err := store.Get(ctx, key, &buf); err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+	} else if !bytes.Equal(buf.Bytes(), override) {
+		t.Errorf("got data = %v, want = %v", buf.Bytes(), override)
+	}
+
+	if err := store.Delete(ctx, key); err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+	}
+
+	if err := store.Get(ctx, key, &buf); !errors.Is(err, fb.ErrNotFound) {
+		t.Errorf("got error = %v, want = %v", err, fb.ErrNotFound)
+	}
+
+	if _, err := store.Stat(ctx, key); !errors.Is(err, fb.ErrNotFound) {
+		t.Errorf("got error = %v, want = %v", err, fb.ErrNotFound)
+	}
+
+	if err := store.Delete(ctx, key); !errors.Is(err, fb.ErrNotFound) {
+		t.Errorf("got error = %v, want = %v", err, fb.ErrNotFound)
+	}
+}
+
+func TestLocalBlobStore(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	store := NewLocalBlobStore(t.TempDir(), logger)
+	testBlobStore(t, store)
+}
+
+func TestLocalBlobStoreKeepsBlobsInsideRoot(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	parent := t.TempDir()
+	root := filepath.Join(parent, "blobs")
+	store := NewLocalBlobStore(root, logger)
+
+	if _, err := store.Put(context.Background(), "../escaped", bytes.NewReader([]byte("hello world"))); err != nil {
+		t.Fatalf("got error = %v, want = %v", err, nil)
+	}
+
+	if _, err := os.Stat(filepath.Join(parent, "escaped")); err == nil {
+		t.Errorf("blob has been stored outside the root directory")
+	}
+
+	if _, err := os.Stat(filepath.Join(root, "escaped")); err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+	}
+}
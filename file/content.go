@@ -0,0 +1,472 @@
+package file
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+
+	fb "github.com/alvidir/filebrowser"
+	"go.uber.org/zap"
+)
+
+const (
+	blobKeySize = 16
+	// sniffSize is the amount of bytes the content type of any content is sniffed from.
+	sniffSize = 512
+)
+
+type VersionRepository interface {
+	Create(ctx context.Context, version *Version) error
+	Find(ctx context.Context, id string) (*Version, error)
+	FindByFileId(ctx context.Context, fid string) ([]*Version, error)
+	Delete(ctx context.Context, version *Version) error
+	DeleteByFileId(ctx context.Context, fid string) error
+}
+
+// BlobRepository counts, by the hash of their content, the references each blob has from versions of files, so
+// identical contents share a single blob.
+type BlobRepository interface {
+	// Acquire references the blob having the given hash, returning its key. If there is none yet, the blob with
+	// the given key gets registered for the hash.
+	Acquire(ctx context.Context, hash string, key string) (string, error)
+	// Retain references, once more, the blob with the given key, if registered.
+	Retain(ctx context.Context, key string) error
+	// Release dereferences the blob with the given key, if registered, returning how many references are left.
+	Release(ctx context.Context, key string) (int64, error)
+}
+
+// ContentStore keeps the content of files into a blob store, as well as the history of versions of each
+// one of them. Any version older than the retention of its file gets pruned.
+type ContentStore struct {
+	blobs       BlobStore
+	blobRepo    BlobRepository
+	versionRepo VersionRepository
+	retention   int
+	quotas      *QuotaStore
+	logger      *zap.Logger
+}
+
+func NewContentStore(blobs BlobStore, versionRepo VersionRepository, retention int, logger *zap.Logger) *ContentStore {
+	return &ContentStore{
+		blobs:       blobs,
+		versionRepo: versionRepo,
+		retention:   retention,
+		logger:      logger,
+	}
+}
+
+// SetQuotaStore makes the store account the size of the current content of each file to all its owners, so any
+// content making them exceed their quota gets rejected.
+func (store *ContentStore) SetQuotaStore(quotas *QuotaStore) {
+	store.quotas = quotas
+}
+
+// SetBlobRepository makes the store keep the content of files addressed by its hash, so identical contents are
+// stored once, no matter how many files or versions they belong to.
+func (store *ContentStore) SetBlobRepository(blobRepo BlobRepository) {
+	store.blobRepo = blobRepo
+}
+
+// Blobs returns the blob store the content is kept in.
+func (store *ContentStore) Blobs() BlobStore {
+	return store.blobs
+}
+
+// Quotas returns the quota store the content is accounted to, if any.
+func (store *ContentStore) Quotas() *QuotaStore {
+	return store.quotas
+}
+
+func newBlobKey() (string, error) {
+	key := make([]byte, blobKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(key), nil
+}
+
+// Write stores the content read from r as a brand new version of the given file, which becomes its
+// current content. The file itself is not saved.
+func (store *ContentStore) Write(ctx context.Context, uid int32, file *File, r io.Reader) (*Version, error) {
+	key, err := newBlobKey()
+	if err != nil {
+		store.logger.Error("generating blob key",
+			zap.String("file_id", file.id),
+			zap.Error(err))
+
+		return nil, fb.ErrUnknown
+	}
+
+	current, err := store.Size(ctx, file)
+	if err != nil {
+		return nil, err
+	}
+
+	owners := file.Owners()
+	available, limited, err := store.quotas.Available(ctx, owners)
+	if err != nil {
+		return nil, err
+	}
+
+	var limit *quotaReader
+	if limited {
+		// the current content is released once replaced
+		limit = &quotaReader{r: r, n: available + current}
+		r = limit
+	}
+
+	hash := sha256.New()
+	sniff := &sniffer{}
+	size, err := store.blobs.Put(ctx, key, io.TeeReader(r, io.MultiWriter(hash, sniff)))
+	if limit != nil && limit.exceeded() {
+		// blob stores may report any error as unknown
+		store.deleteBlob(ctx, key)
+		return nil, fb.ErrQuotaExceeded
+	} else if err != nil {
+		return nil, err
+	}
+
+	// the limit above is just an early cutoff, since concurrent writes may have consumed the storage meanwhile
+	if err := store.quotas.Reserve(ctx, owners, size-current, 0); err != nil {
+		store.deleteBlob(ctx, key)
+		return nil, err
+	}
+
+	digest := hex.EncodeToString(hash.Sum(nil))
+	if store.blobRepo != nil {
+		shared, err := store.blobRepo.Acquire(ctx, digest, key)
+		if err != nil {
+			store.quotas.Release(ctx, owners, size-current, 0)
+			store.deleteBlob(ctx, key)
+			return nil, err
+		}
+
+		if shared != key {
+			// an identical content is already stored, so the brand new blob is dropped in favour of it
+			store.deleteBlob(ctx, key)
+			key = shared
+		}
+	}
+
+	version := &Version{
+		fileId:      file.id,
+		author:      uid,
+		createdAt:   time.Now(),
+		size:        size,
+		hash:        digest,
+		contentType: ContentType(file.name, sniff.data),
+		blob:        key,
+	}
+
+	if err := store.versionRepo.Create(ctx, version); err != nil {
+		store.quotas.Release(ctx, owners, size-current, 0)
+		store.release(ctx, key, make(map[string]bool))
+		return nil, err
+	}
+
+	file.blob = key
+	setContentMetadata(file, version)
+	return version, nil
+}
+
+// Restore records a brand new version of the given file having the same content as the given version,
+// which becomes its current content. The file itself is not saved.
+func (store *ContentStore) Restore(ctx context.Context, uid int32, file *File, version *Version) (*Version, error) {
+	current, err := store.Size(ctx, file)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := store.quotas.Reserve(ctx, file.Owners(), version.size-current, 0); err != nil {
+		return nil, err
+	}
+
+	restored := &Version{
+		fileId:      file.id,
+		author:      uid,
+		createdAt:   time.Now(),
+		size:        version.size,
+		hash:        version.hash,
+		contentType: version.contentType,
+		blob:        version.blob,
+	}
+
+	if err := store.retain(ctx, version.blob); err != nil {
+		store.quotas.Release(ctx, file.Owners(), version.size-current, 0)
+		return nil, err
+	}
+
+	if err := store.versionRepo.Create(ctx, restored); err != nil {
+		store.quotas.Release(ctx, file.Owners(), version.size-current, 0)
+		store.release(ctx, version.blob, map[string]bool{version.blob: true})
+		return nil, err
+	}
+
+	file.blob = version.blob
+	setContentMetadata(file, restored)
+	return restored, nil
+}
+
+// Discard drops the given version, recorded as the current content of the given file by Write, Restore or Copy,
+// once the file fails to be saved. The content it replaced, stored in the blob with the given key, becomes the
+// current one again. Since the failure is already being reported, any error here is logged but never returned.
+func (store *ContentStore) Discard(ctx context.Context, file *File, version *Version, previous string) {
+	if err := store.versionRepo.Delete(ctx, version); err != nil {
+		store.logger.Warn("deleting discarded version",
+			zap.String("file_id", file.id),
+			zap.String("version_id", version.id),
+			zap.Error(err))
+	}
+
+	file.blob = previous
+	if current, err := store.Size(ctx, file); err != nil {
+		store.logger.Warn("accounting discarded content size",
+			zap.String("file_id", file.id),
+			zap.Error(err))
+	} else {
+		store.quotas.Release(ctx, file.Owners(), version.size-current, 0)
+	}
+
+	// a restored version shares its blob with the original one
+	referenced := map[string]bool{version.blob: true}
+	if versions, err := store.versionRepo.FindByFileId(ctx, file.id); err == nil {
+		delete(referenced, version.blob)
+		for _, other := range versions {
+			referenced[other.blob] = true
+		}
+	}
+
+	store.release(ctx, version.blob, referenced)
+}
+
+// Copy stores the current content of the file src as a brand new version of the file dst, which becomes its
+// current content. Both files share the same blob, if addressed by content. The file dst is not saved.
+func (store *ContentStore) Copy(ctx context.Context, uid int32, src *File, dst *File) (*Version, error) {
+	r, w := io.Pipe()
+	defer r.Close()
+
+	go func() {
+		w.CloseWithError(store.Read(ctx, src, w))
+	}()
+
+	return store.Write(ctx, uid, dst, r)
+}
+
+// ContentType returns the media type of the content of the file with the given name, whose first bytes are the
+// given ones. The extension of the name, if known, takes precedence over the content itself.
+func ContentType(name string, data []byte) string {
+	if contentType := mime.TypeByExtension(path.Ext(name)); len(contentType) > 0 {
+		return contentType
+	}
+
+	if len(data) > sniffSize {
+		data = data[:sniffSize]
+	}
+
+	return http.DetectContentType(data)
+}
+
+// setContentMetadata sets into the given file the size, content type and checksum of the given version.
+func setContentMetadata(file *File, version *Version) {
+	file.AddMetadata(MetadataSizeKey, strconv.FormatInt(version.size, 10))
+	file.AddMetadata(MetadataHashKey, version.hash)
+	if len(version.contentType) > 0 {
+		file.AddMetadata(MetadataContentTypeKey, version.contentType)
+	} else {
+		// versions recorded before sniffing their content have no type at all
+		delete(file.metadata, MetadataContentTypeKey)
+	}
+}
+
+// sniffer keeps the first bytes written into it, from which the type of a content can be sniffed.
+type sniffer struct {
+	data []byte
+}
+
+func (sniff *sniffer) Write(p []byte) (int, error) {
+	if left := sniffSize - len(sniff.data); left > 0 {
+		if len(p) < left {
+			left = len(p)
+		}
+
+		sniff.data = append(sniff.data, p[:left]...)
+	}
+
+	return len(p), nil
+}
+
+// Read writes into w the current content of the given file. A file that has never been written is considered
+// empty, while the content of any other must exist.
+func (store *ContentStore) Read(ctx context.Context, file *File, w io.Writer) error {
+	err := store.blobs.Get(ctx, file.Blob(), w)
+	if errors.Is(err, fb.ErrNotFound) && len(file.blob) == 0 {
+		// the file has never been written, or its legacy content is empty
+		return nil
+	}
+
+	return err
+}
+
+// Size returns the size of the current content of the given file, including that stored before being versioned.
+// A file with no content is considered empty.
+func (store *ContentStore) Size(ctx context.Context, file *File) (int64, error) {
+	info, err := store.blobs.Stat(ctx, file.Blob())
+	if errors.Is(err, fb.ErrNotFound) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	return info.Size, nil
+}
+
+// ReadVersion writes into w the content of the given version.
+func (store *ContentStore) ReadVersion(ctx context.Context, version *Version, w io.Writer) error {
+	return store.blobs.Get(ctx, version.blob, w)
+}
+
+// Versions returns all the versions of the given file, from the newest to the oldest one.
+func (store *ContentStore) Versions(ctx context.Context, file *File) ([]*Version, error) {
+	return store.versionRepo.FindByFileId(ctx, file.id)
+}
+
+// Version returns the version with the given id, if, and only if, it belongs to the given file.
+func (store *ContentStore) Version(ctx context.Context, file *File, vid string) (*Version, error) {
+	version, err := store.versionRepo.Find(ctx, vid)
+	if err != nil {
+		return nil, err
+	}
+
+	if version.fileId != file.id {
+		return nil, fb.ErrNotFound
+	}
+
+	return version, nil
+}
+
+// Prune removes all those versions of the given file exceeding its retention, as well as their content
+// if no longer referenced.
+func (store *ContentStore) Prune(ctx context.Context, file *File) error {
+	retention, exists := file.Retention()
+	if !exists {
+		retention = store.retention
+	}
+
+	if retention <= 0 {
+		return nil
+	}
+
+	versions, err := store.versionRepo.FindByFileId(ctx, file.id)
+	if err != nil || len(versions) <= retention {
+		return err
+	}
+
+	// a restored version shares its blob with the original one
+	referenced := map[string]bool{file.Blob(): true}
+	for _, version := range versions[:retention] {
+		referenced[version.blob] = true
+	}
+
+	for _, version := range versions[retention:] {
+		if err := store.versionRepo.Delete(ctx, version); err != nil {
+			return err
+		}
+
+		store.release(ctx, version.blob, referenced)
+	}
+
+	return nil
+}
+
+// Delete removes all the versions of the given file, as well as its content, which its owners no longer consume.
+func (store *ContentStore) Delete(ctx context.Context, file *File) error {
+	versions, err := store.versionRepo.FindByFileId(ctx, file.id)
+	if err != nil {
+		return err
+	}
+
+	current, err := store.Size(ctx, file)
+	if err != nil {
+		return err
+	}
+
+	if err := store.versionRepo.DeleteByFileId(ctx, file.id); err != nil {
+		return err
+	}
+
+	deleted := make(map[string]bool)
+	versioned := false
+	for _, version := range versions {
+		versioned = versioned || version.blob == file.Blob()
+		store.release(ctx, version.blob, deleted)
+	}
+
+	if !versioned && len(file.Blob()) > 0 {
+		store.release(ctx, file.Blob(), deleted)
+	}
+
+	store.quotas.Release(ctx, file.Owners(), current, 0)
+	return nil
+}
+
+// retain references, once more, the blob with the given key. Blobs stored before being addressed by content are
+// not counted at all.
+func (store *ContentStore) retain(ctx context.Context, key string) error {
+	if store.blobRepo == nil {
+		return nil
+	}
+
+	if err := store.blobRepo.Retain(ctx, key); err != nil && !errors.Is(err, fb.ErrNotFound) {
+		return err
+	}
+
+	return nil
+}
+
+// release drops a reference to the blob with the given key, which gets removed once no longer referenced. Blobs
+// stored before being addressed by content are removed unless marked as referenced, which they get marked as.
+func (store *ContentStore) release(ctx context.Context, key string, referenced map[string]bool) {
+	if store.blobRepo != nil {
+		refs, err := store.blobRepo.Release(ctx, key)
+		if err == nil {
+			if refs <= 0 {
+				store.deleteBlob(ctx, key)
+			}
+
+			return
+		}
+
+		if !errors.Is(err, fb.ErrNotFound) {
+			// the blob is kept, since it may still be referenced
+			store.logger.Warn("releasing blob",
+				zap.String("key", key),
+				zap.Error(err))
+
+			return
+		}
+	}
+
+	if !referenced[key] {
+		referenced[key] = true
+		store.deleteBlob(ctx, key)
+	}
+}
+
+// deleteBlob removes the blob with the given key, if any. Since the blob is no longer referenced, a
+// failure here is logged but never reported to the caller.
+func (store *ContentStore) deleteBlob(ctx context.Context, key string) {
+	if err := store.blobs.Delete(ctx, key); err != nil && !errors.Is(err, fb.ErrNotFound) {
+		store.logger.Warn("deleting blob",
+			zap.String("key", key),
+			zap.Error(err))
+	}
+}
@@ -0,0 +1,380 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"testing"
+
+	fb "github.com/alvidir/filebrowser"
+	"go.uber.org/zap"
+)
+
+// versionRepositoryStub keeps versions in memory, from the newest to the oldest one.
+type versionRepositoryStub struct {
+	versions []*Version
+}
+
+func (stub *versionRepositoryStub) Create(ctx context.Context, version *Version) error {
+	version.id = strconv.Itoa(len(stub.versions))
+	stub.versions = append([]*Version{version}, stub.versions...)
+	return nil
+}
+
+func (stub *versionRepositoryStub) Find(ctx context.Context, id string) (*Version, error) {
+	for _, version := range stub.versions {
+		if version.id == id {
+			return version, nil
+		}
+	}
+
+	return nil, fb.ErrNotFound
+}
+
+func (stub *versionRepositoryStub) FindByFileId(ctx context.Context, fid string) ([]*Version, error) {
+	versions := make([]*Version, 0, len(stub.versions))
+	for _, version := range stub.versions {
+		if version.fileId == fid {
+			versions = append(versions, version)
+		}
+	}
+
+	return versions, nil
+}
+
+func (stub *versionRepositoryStub) Delete(ctx context.Context, version *Version) error {
+	for index, v := range stub.versions {
+		if v.id == version.id {
+			stub.versions = append(stub.versions[:index], stub.versions[index+1:]...)
+			return nil
+		}
+	}
+
+	return fb.ErrNotFound
+}
+
+func (stub *versionRepositoryStub) DeleteByFileId(ctx context.Context, fid string) error {
+	versions := stub.versions[:0]
+	for _, version := range stub.versions {
+		if version.fileId != fid {
+			versions = append(versions, version)
+		}
+	}
+
+	stub.versions = versions
+	return nil
+}
+
+// blobRepositoryStub counts the references to each blob in memory.
+type blobRepositoryStub struct {
+	keys map[string]string // blob key by hash
+	refs map[string]int64  // references by blob key
+}
+
+func (stub *blobRepositoryStub) Acquire(ctx context.Context, hash string, key string) (string, error) {
+	if shared, exists := stub.keys[hash]; exists {
+		key = shared
+	}
+
+	stub.keys[hash] = key
+	stub.refs[key]++
+	return key, nil
+}
+
+func (stub *blobRepositoryStub) Retain(ctx context.Context, key string) error {
+	if _, exists := stub.refs[key]; !exists {
+		return fb.ErrNotFound
+	}
+
+	stub.refs[key]++
+	return nil
+}
+
+func (stub *blobRepositoryStub) Release(ctx context.Context, key string) (int64, error) {
+	if _, exists := stub.refs[key]; !exists {
+		return 0, fb.ErrNotFound
+	}
+
+	stub.refs[key]--
+	return stub.refs[key], nil
+}
+
+func TestContentStoreWrite(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	ctx := context.Background()
+	repo := &versionRepositoryStub{}
+	store := NewContentStore(NewLocalBlobStore(t.TempDir(), logger), repo, 0, logger)
+
+	file, _ := NewFile("123", "testing")
+	contents := []string{"first content", "second content"}
+	for _, content := range contents {
+		if _, err := store.Write(ctx, 111, file, bytes.NewReader([]byte(content))); err != nil {
+			t.Errorf("got error = %v, want = %v", err, nil)
+			return
+		}
+	}
+
+	versions, err := store.Versions(ctx, file)
+	if err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+		return
+	}
+
+	if len(versions) != len(contents) {
+		t.Errorf("got versions = %v, want = %v", len(versions), len(contents))
+		return
+	}
+
+	latest := contents[len(contents)-1]
+	hash := sha256.Sum256([]byte(latest))
+	if got, want := versions[0].Hash(), hex.EncodeToString(hash[:]); got != want {
+		t.Errorf("got hash = %v, want = %v", got, want)
+	}
+
+	if got := versions[0].Size(); got != int64(len(latest)) {
+		t.Errorf("got size = %v, want = %v", got, len(latest))
+	}
+
+	if got := versions[0].Author(); got != 111 {
+		t.Errorf("got author = %v, want = %v", got, 111)
+	}
+
+	var buf bytes.Buffer
+	if err := store.Read(ctx, file, &buf); err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+	} else if got := buf.String(); got != latest {
+		t.Errorf("got data = %v, want = %v", got, latest)
+	}
+
+	buf.Reset()
+	if err := store.ReadVersion(ctx, versions[1], &buf); err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+	} else if got := buf.String(); got != contents[0] {
+		t.Errorf("got data = %v, want = %v", got, contents[0])
+	}
+}
+
+func TestContentStoreRestore(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	ctx := context.Background()
+	repo := &versionRepositoryStub{}
+	store := NewContentStore(NewLocalBlobStore(t.TempDir(), logger), repo, 0, logger)
+
+	file, _ := NewFile("123", "testing")
+	first, _ := store.Write(ctx, 111, file, bytes.NewReader([]byte("first content")))
+	store.Write(ctx, 111, file, bytes.NewReader([]byte("second content")))
+
+	restored, err := store.Restore(ctx, 222, file, first)
+	if err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+		return
+	}
+
+	if restored.Id() == first.Id() {
+		t.Errorf("got version id = %v, want != %v", restored.Id(), first.Id())
+	}
+
+	if got := restored.Author(); got != 222 {
+		t.Errorf("got author = %v, want = %v", got, 222)
+	}
+
+	var buf bytes.Buffer
+	if err := store.Read(ctx, file, &buf); err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+	} else if got := buf.String(); got != "first content" {
+		t.Errorf("got data = %v, want = %v", got, "first content")
+	}
+
+	other, _ := NewFile("456", "other")
+	if _, err := store.Version(ctx, other, first.Id()); !errors.Is(err, fb.ErrNotFound) {
+		t.Errorf("got error = %v, want = %v", err, fb.ErrNotFound)
+	}
+}
+
+func TestContentStoreCopy(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	ctx := context.Background()
+	repo := &versionRepositoryStub{}
+	store := NewContentStore(NewLocalBlobStore(t.TempDir(), logger), repo, 0, logger)
+
+	src, _ := NewFile("123", "testing")
+	store.Write(ctx, 111, src, bytes.NewReader([]byte("some content")))
+
+	dst, _ := NewFile("456", "copy")
+	version, err := store.Copy(ctx, 222, src, dst)
+	if err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+		return
+	}
+
+	if got := version.FileId(); got != dst.Id() {
+		t.Errorf("got file id = %v, want = %v", got, dst.Id())
+	}
+
+	if dst.Blob() == src.Blob() {
+		t.Errorf("got blob = %v, want != %v", dst.Blob(), src.Blob())
+	}
+
+	// the copy keeps its content no matter the original is deleted
+	if err := store.Delete(ctx, src); err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := store.Read(ctx, dst, &buf); err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+	} else if got := buf.String(); got != "some content" {
+		t.Errorf("got data = %v, want = %v", got, "some content")
+	}
+}
+
+func TestContentStorePrune(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	ctx := context.Background()
+	repo := &versionRepositoryStub{}
+	blobs := NewLocalBlobStore(t.TempDir(), logger)
+	store := NewContentStore(blobs, repo, 10, logger)
+
+	file, _ := NewFile("123", "testing")
+	file.AddMetadata(MetadataRetentionKey, "2")
+
+	first, _ := store.Write(ctx, 111, file, bytes.NewReader([]byte("first content")))
+	store.Write(ctx, 111, file, bytes.NewReader([]byte("second content")))
+	store.Write(ctx, 111, file, bytes.NewReader([]byte("third content")))
+
+	if err := store.Prune(ctx, file); err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+		return
+	}
+
+	versions, _ := store.Versions(ctx, file)
+	if len(versions) != 2 {
+		t.Errorf("got versions = %v, want = %v", len(versions), 2)
+	}
+
+	if _, err := store.Version(ctx, file, first.Id()); !errors.Is(err, fb.ErrNotFound) {
+		t.Errorf("got error = %v, want = %v", err, fb.ErrNotFound)
+	}
+
+	if _, err := blobs.Stat(ctx, first.blob); !errors.Is(err, fb.ErrNotFound) {
+		t.Errorf("got error = %v, want = %v", err, fb.ErrNotFound)
+	}
+}
+
+func TestContentStoreDeduplication(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	ctx := context.Background()
+	blobs := NewLocalBlobStore(t.TempDir(), logger)
+	blobRepo := &blobRepositoryStub{keys: make(map[string]string), refs: make(map[string]int64)}
+	store := NewContentStore(blobs, &versionRepositoryStub{}, 0, logger)
+	store.SetBlobRepository(blobRepo)
+
+	first, _ := NewFile("123", "first")
+	store.Write(ctx, 111, first, bytes.NewReader([]byte("some content")))
+
+	second, _ := NewFile("456", "second")
+	store.Write(ctx, 222, second, bytes.NewReader([]byte("some content")))
+
+	if first.Blob() != second.Blob() {
+		t.Errorf("got blob = %v, want = %v", second.Blob(), first.Blob())
+	}
+
+	hash := sha256.Sum256([]byte("some content"))
+	if got, _ := second.Value(MetadataHashKey); got != hex.EncodeToString(hash[:]) {
+		t.Errorf("got hash = %v, want = %v", got, hex.EncodeToString(hash[:]))
+	}
+
+	if got := blobRepo.refs[first.Blob()]; got != 2 {
+		t.Errorf("got references = %v, want = %v", got, 2)
+	}
+
+	// the content is kept as long as any file references it
+	if err := store.Delete(ctx, first); err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := store.Read(ctx, second, &buf); err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+	} else if got := buf.String(); got != "some content" {
+		t.Errorf("got data = %v, want = %v", got, "some content")
+	}
+
+	if err := store.Delete(ctx, second); err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+		return
+	}
+
+	if _, err := blobs.Stat(ctx, second.Blob()); !errors.Is(err, fb.ErrNotFound) {
+		t.Errorf("got error = %v, want = %v", err, fb.ErrNotFound)
+	}
+}
+
+func TestContentStoreReadWhenBlobIsMissing(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	ctx := context.Background()
+	store := NewContentStore(NewLocalBlobStore(t.TempDir(), logger), &versionRepositoryStub{}, 0, logger)
+
+	// a file never written has no content at all
+	file, _ := NewFile("123", "testing")
+	var buf bytes.Buffer
+	if err := store.Read(ctx, file, &buf); err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+	}
+
+	// the content of a written file must exist, or it would silently read as empty
+	file.blob = "missing"
+	if err := store.Read(ctx, file, &buf); !errors.Is(err, fb.ErrNotFound) {
+		t.Errorf("got error = %v, want = %v", err, fb.ErrNotFound)
+	}
+}
+
+func TestContentStoreSizeOfLegacyContent(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	ctx := context.Background()
+	blobs := NewLocalBlobStore(t.TempDir(), logger)
+	quotas := NewQuotaStore(&usageRepositoryStub{}, Quota{}, logger)
+	store := NewContentStore(blobs, &versionRepositoryStub{}, 0, logger)
+	store.SetQuotaStore(quotas)
+
+	// content stored before being versioned is keyed by the id of its file
+	file, _ := NewFile("123", "testing")
+	file.AddPermission(111, Owner)
+	if _, err := blobs.Put(ctx, file.Id(), bytes.NewReader([]byte("hello"))); err != nil {
+		t.Fatalf("got error = %v, want = %v", err, nil)
+	}
+
+	if size, err := store.Size(ctx, file); err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+	} else if size != 5 {
+		t.Errorf("got size = %v, want = %v", size, 5)
+	}
+
+	quotas.add(ctx, file.Owners(), 5, 0)
+	if err := store.Delete(ctx, file); err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+	}
+
+	if usage, _ := quotas.Usage(ctx, 111); usage.Bytes() != 0 {
+		t.Errorf("got bytes = %v, want = %v", usage.Bytes(), 0)
+	}
+}
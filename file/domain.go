@@ -17,18 +17,65 @@ const (
 
 	FilenameRegex string = "^[^/]+$"
 
-	MetadataCreatedAtKey = "created_at"
-	MetadataUpdatedAtKey = "updated_at"
-	MetadataDeletedAtKey = "deleted_at"
-	MetadataSizeKey      = "size"
-	MetadataAppKey       = "app"
-	MetadataRefKey       = "ref"
+	// SharedDirectory is where files shared with a user are registered into its directory.
+	SharedDirectory = "/Shared with me"
+
+	MetadataCreatedAtKey   = "created_at"
+	MetadataUpdatedAtKey   = "updated_at"
+	MetadataDeletedAtKey   = "deleted_at"
+	MetadataSizeKey        = "size"  // bytes of the content, or of all the files under a folder
+	MetadataFilesKey       = "files" // amount of files under a folder
+	MetadataAppKey         = "app"
+	MetadataRefKey         = "ref"
+	MetadataRetentionKey   = "retention"
+	MetadataHashKey        = "hash" // sha-256 of the current content
+	MetadataContentTypeKey = "content_type"
+
+	MetadataKeyMaxLen   = 64
+	MetadataValueMaxLen = 1024
+	MetadataMaxKeys     = 64 // app and user keys a file may have, at most
 
 	TimestampBase = 16
 )
 
+const (
+	// UserScope holds those metadata keys any user allowed to write a file can set.
+	UserScope MetadataScope = iota
+	// AppScope holds those metadata keys only applications integrated through events can set.
+	AppScope
+	// SystemScope holds those metadata keys only the server itself can set.
+	SystemScope
+)
+
+const (
+	// FullView includes all the fields of a file, together with its content.
+	FullView View = iota
+	// BasicView includes all the fields of a file, but its content.
+	BasicView
+	// DataOnlyView includes the content of a file and just those fields required to access it.
+	DataOnlyView
+)
+
 var (
 	r, _ = regexp.Compile(FilenameRegex)
+
+	// metadataScopes relates each managed metadata key with its scope. Any other key is in the user's one.
+	metadataScopes = map[string]MetadataScope{
+		MetadataCreatedAtKey:   SystemScope,
+		MetadataUpdatedAtKey:   SystemScope,
+		MetadataDeletedAtKey:   SystemScope,
+		MetadataSizeKey:        SystemScope,
+		MetadataFilesKey:       SystemScope,
+		MetadataHashKey:        SystemScope,
+		MetadataContentTypeKey: SystemScope,
+		MetadataAppKey:         AppScope,
+		MetadataRefKey:         AppScope,
+	}
+
+	// ownerMetadataKeys holds those metadata keys in the user's scope only the owners of a file can set.
+	ownerMetadataKeys = map[string]bool{
+		MetadataRetentionKey: true, // a user must never drop the versions of a file it does not own
+	}
 )
 
 type Permission uint8
@@ -36,15 +83,44 @@ type Metadata map[string]string
 type Flag uint8
 type Ctrl uint8
 
+// View tells which parts of a file are to be retrieved.
+type View uint8
+
+// MetadataScope tells who manages a metadata key, where any scope includes all those below it.
+type MetadataScope uint8
+
+// MetadataScopeOf returns the scope the given metadata key belongs to.
+func MetadataScopeOf(key string) MetadataScope {
+	if scope, exists := metadataScopes[key]; exists {
+		return scope
+	}
+
+	return UserScope
+}
+
+// IsReservedMetadata returns true if, and only if, the given metadata key is managed by the server.
+func IsReservedMetadata(key string) bool {
+	return MetadataScopeOf(key) == SystemScope
+}
+
+// IsOwnerMetadata returns true if, and only if, the given metadata key can only be set by the owners of a file.
+func IsOwnerMetadata(key string) bool {
+	return ownerMetadataKeys[key]
+}
+
 type File struct {
 	id          string
 	name        string
 	metadata    Metadata
 	directory   string
 	permissions map[int32]Permission
-	protected   bool // true avoids the file from saving
+	groups      map[string]Permission           // permissions granted to groups of users, by group id
+	inherited   map[string]map[int32]Permission // permissions inherited from the folders containing the file, by folder id
+	protected   bool                            // true avoids the file from saving
 	flags       Flag
 	data        []byte
+	blob        string // key of the blob holding the current content of the file
+	revision    int64  // amount of times the file has been saved, for optimistic concurrency control
 }
 
 func NewFile(id string, filename string) (*File, error) {
@@ -75,6 +151,10 @@ func (file *File) Name() string {
 	return file.name
 }
 
+func (file *File) Revision() int64 {
+	return file.revision
+}
+
 func (file *File) Value(key string) (value string, exists bool) {
 	if file.metadata != nil {
 		value, exists = file.metadata[key]
@@ -112,13 +192,22 @@ func (file *File) Owners() []int32 {
 	return owners
 }
 
+// SharedWith returns all those users having any permission over the file, either granted or inherited.
 func (file *File) SharedWith() []int32 {
-	shared := make([]int32, len(file.permissions))
-
-	index := 0
+	users := make(map[int32]bool, len(file.permissions))
 	for uid := range file.permissions {
-		shared[index] = uid
-		index++
+		users[uid] = true
+	}
+
+	for _, perms := range file.inherited {
+		for uid := range perms {
+			users[uid] = true
+		}
+	}
+
+	shared := make([]int32, 0, len(users))
+	for uid := range users {
+		shared = append(shared, uid)
 	}
 
 	return shared
@@ -132,11 +221,17 @@ func (file *File) SetName(name string) {
 	file.name = name
 }
 
+// Permission returns the permission the given user has over the file, either granted or inherited from any of
+// the folders containing it.
 func (file *File) Permission(uid int32) (perm Permission) {
 	if file.permissions != nil {
 		perm = file.permissions[uid]
 	}
 
+	for _, perms := range file.inherited {
+		perm |= perms[uid]
+	}
+
 	return
 }
 
@@ -162,6 +257,111 @@ func (file *File) RevokePermission(uid int32, perm Permission) {
 	}
 }
 
+// GroupPermission returns the permission granted over the file to the group with the given id.
+func (file *File) GroupPermission(gid string) (perm Permission) {
+	if file.groups != nil {
+		perm = file.groups[gid]
+	}
+
+	return
+}
+
+func (file *File) AddGroupPermission(gid string, perm Permission) {
+	if file.groups == nil {
+		file.groups = make(map[string]Permission)
+	}
+
+	file.groups[gid] |= perm
+}
+
+func (file *File) RevokeGroupPermission(gid string, perm Permission) {
+	if file.groups == nil {
+		return
+	}
+
+	if p, exists := file.groups[gid]; !exists {
+		return
+	} else if perm = p & ^perm; perm == 0 {
+		delete(file.groups, gid)
+	} else {
+		file.groups[gid] = perm
+	}
+}
+
+// Groups returns the permissions granted over the file to groups of users, by group id.
+func (file *File) Groups() map[string]Permission {
+	return file.groups
+}
+
+// EffectivePermission returns the permission the given user has over the file, either granted to the user
+// itself or to any of the given groups it is member of.
+func (file *File) EffectivePermission(uid int32, gids []string) Permission {
+	perm := file.Permission(uid)
+	for _, gid := range gids {
+		perm |= file.GroupPermission(gid)
+	}
+
+	return perm
+}
+
+// Inherit makes the file inherit the permissions granted over the given folder, returning true if, and only if,
+// they have changed. Inherited permissions never grant the ownership of the file, and so folder owners get
+// inherited the permission to read and write it instead.
+func (file *File) Inherit(folder *File) bool {
+	perms := make(map[int32]Permission, len(folder.permissions))
+	for uid, perm := range folder.permissions {
+		if perm&Owner != 0 {
+			perm = Read | Write
+		}
+
+		if perm &= Read | Write; perm != 0 {
+			perms[uid] = perm
+		}
+	}
+
+	if current, exists := file.inherited[folder.id]; exists && len(current) == len(perms) {
+		equal := true
+		for uid, perm := range perms {
+			if current[uid] != perm {
+				equal = false
+				break
+			}
+		}
+
+		if equal {
+			return false
+		}
+	}
+
+	if file.inherited == nil {
+		file.inherited = make(map[string]map[int32]Permission)
+	}
+
+	file.inherited[folder.id] = perms
+	return true
+}
+
+// Disinherit removes from the file the permissions inherited from the folder with the given id, returning true
+// if, and only if, there was any.
+func (file *File) Disinherit(fid string) bool {
+	if _, exists := file.inherited[fid]; !exists {
+		return false
+	}
+
+	delete(file.inherited, fid)
+	return true
+}
+
+// Inherited returns the permissions inherited from the folders containing the file, by folder id.
+func (file *File) Inherited() map[string]map[int32]Permission {
+	return file.inherited
+}
+
+// IsFolder returns true if, and only if, the file stands for a folder.
+func (file *File) IsFolder() bool {
+	return file.flags&Directory != 0
+}
+
 func (file *File) RevokeAccess(uid int32) bool {
 	if file.permissions == nil {
 		return false
@@ -185,6 +385,45 @@ func (file *File) AddMetadata(key string, value string) (old string, exists bool
 	return
 }
 
+// PatchMetadata merges the given metadata into the one of the file, where an empty value removes its key. Those
+// keys out of the given scope are ignored, since they are managed by someone else.
+func (file *File) PatchMetadata(patch Metadata, scope MetadataScope) error {
+	merged := make(Metadata, len(file.metadata)+len(patch))
+	for key, value := range file.metadata {
+		merged[key] = value
+	}
+
+	for key, value := range patch {
+		if MetadataScopeOf(key) > scope {
+			continue
+		}
+
+		if len(key) == 0 || len(key) > MetadataKeyMaxLen || len(value) > MetadataValueMaxLen {
+			return fb.ErrInvalidFormat
+		}
+
+		if len(value) == 0 {
+			delete(merged, key)
+		} else {
+			merged[key] = value
+		}
+	}
+
+	count := 0
+	for key := range merged {
+		if !IsReservedMetadata(key) {
+			count++
+		}
+	}
+
+	if count > MetadataMaxKeys {
+		return fb.ErrInvalidFormat
+	}
+
+	file.metadata = merged
+	return nil
+}
+
 func (file *File) SetDirectory(dir string) {
 	file.directory = dir
 }
@@ -193,6 +432,32 @@ func (file *File) Data() []byte {
 	return file.data
 }
 
+// Blob returns the key of the blob holding the current content of the file. Files stored before keeping
+// track of versions have their content keyed by their own id.
+func (file *File) Blob() string {
+	if len(file.blob) == 0 {
+		return file.id
+	}
+
+	return file.blob
+}
+
+// Retention returns the maximum amount of versions to keep for the file, if any. A zero or negative
+// value means no limit.
+func (file *File) Retention() (retention int, exists bool) {
+	value, exists := file.Value(MetadataRetentionKey)
+	if !exists {
+		return 0, false
+	}
+
+	retention, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, false
+	}
+
+	return retention, true
+}
+
 // ProtectFields clear from the file all those fields the given user has no permissions
 // to read.
 func (file *File) ProtectFields(uid int32) {
@@ -203,6 +468,9 @@ func (file *File) ProtectFields(uid int32) {
 	}
 
 	file.MarkAsProtected()
+	file.groups = nil
+	file.inherited = nil
+
 	for id, p := range file.permissions {
 		// if the user has read-only permissions it has the right to know
 		// who are the contributors of the file
@@ -218,9 +486,87 @@ func (file *File) MarkAsProtected() {
 
 func (file *File) IsContributor(uid int32) bool {
 	// is contributor if, and only if, the user is owner or has write permissions
-	return file.permissions[uid]&(Owner|Write) != 0
+	return file.Permission(uid)&(Owner|Write) != 0
 }
 
 func (file *File) SetFlag(flag Flag) {
 	file.flags |= flag
 }
+
+// Version is an immutable snapshot of the content of a file at some point in time.
+type Version struct {
+	id          string
+	fileId      string
+	author      int32
+	createdAt   time.Time
+	size        int64
+	hash        string
+	contentType string
+	blob        string
+}
+
+func (version *Version) Id() string {
+	return version.id
+}
+
+func (version *Version) FileId() string {
+	return version.fileId
+}
+
+func (version *Version) Author() int32 {
+	return version.author
+}
+
+func (version *Version) CreatedAt() time.Time {
+	return version.createdAt
+}
+
+func (version *Version) Size() int64 {
+	return version.size
+}
+
+func (version *Version) Hash() string {
+	return version.hash
+}
+
+func (version *Version) ContentType() string {
+	return version.contentType
+}
+
+// Quota is the most storage a user may consume, either in bytes or files. A zero limit stands for no limit at all.
+type Quota struct {
+	Bytes int64
+	Files int64
+}
+
+// Usage is the storage a user consumes, which is the size of the current content and the amount of all the files
+// the user owns, together with the quota limiting it.
+type Usage struct {
+	userId int32
+	bytes  int64
+	files  int64
+	quota  Quota
+}
+
+func (usage *Usage) UserId() int32 {
+	return usage.userId
+}
+
+func (usage *Usage) Bytes() int64 {
+	return usage.bytes
+}
+
+func (usage *Usage) Files() int64 {
+	return usage.files
+}
+
+func (usage *Usage) Quota() Quota {
+	return usage.quota
+}
+
+// Exceeds returns true if, and only if, consuming the given amount of bytes and files more would make the usage
+// go beyond its quota. Releasing storage never exceeds any quota.
+func (usage *Usage) Exceeds(bytes int64, files int64) bool {
+	return bytes > 0 && usage.quota.Bytes > 0 && usage.bytes+bytes > usage.quota.Bytes ||
+		files > 0 && usage.quota.Files > 0 && usage.files+files > usage.quota.Files
+}
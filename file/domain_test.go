@@ -2,6 +2,7 @@ package file
 
 import (
 	"errors"
+	"strconv"
 	"testing"
 
 	fb "github.com/alvidir/filebrowser"
@@ -271,3 +272,103 @@ func TestValue(t *testing.T) {
 		t.Errorf("got metadata = %v, want = %v", got, want)
 	}
 }
+
+func TestInherit(t *testing.T) {
+	folder, _ := NewFile("folder", "folder")
+	folder.SetFlag(Directory)
+	folder.AddPermission(111, Owner)
+	folder.AddPermission(222, Read)
+
+	file, _ := NewFile("id", "filename")
+	file.AddPermission(333, Owner)
+
+	if !file.Inherit(folder) {
+		t.Errorf("got changed = %v, want = %v", false, true)
+	}
+
+	if file.Inherit(folder) {
+		t.Errorf("got changed = %v, want = %v", true, false)
+	}
+
+	want := map[int32]Permission{111: Read | Write, 222: Read, 333: Owner}
+	for uid, perm := range want {
+		if got := file.Permission(uid); got != perm {
+			t.Errorf("got permission = %v, want = %v", got, perm)
+		}
+	}
+
+	if owners := file.Owners(); len(owners) != 1 || owners[0] != 333 {
+		t.Errorf("got owners = %v, want = %v", owners, []int32{333})
+	}
+
+	if !file.Disinherit(folder.Id()) {
+		t.Errorf("got changed = %v, want = %v", false, true)
+	}
+
+	if got := file.Permission(222); got != 0 {
+		t.Errorf("got permission = %v, want = %v", got, 0)
+	}
+}
+
+func TestPatchMetadata(t *testing.T) {
+	file, _ := NewFile("id", "filename")
+	createdAt, _ := file.Value(MetadataCreatedAtKey)
+	file.AddMetadata("removed", "value")
+	file.AddMetadata("kept", "value")
+
+	patch := Metadata{
+		MetadataCreatedAtKey: "forged",
+		MetadataAppKey:       "forged",
+		"removed":            "",
+		"added":              "value",
+	}
+
+	if err := file.PatchMetadata(patch, UserScope); err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+		return
+	}
+
+	want := Metadata{
+		MetadataCreatedAtKey: createdAt,
+		"kept":               "value",
+		"added":              "value",
+	}
+
+	for key, value := range want {
+		if got, _ := file.Value(key); got != value {
+			t.Errorf("%s: got value = %v, want = %v", key, got, value)
+		}
+	}
+
+	for _, key := range []string{MetadataAppKey, "removed"} {
+		if got, exists := file.Value(key); exists {
+			t.Errorf("%s: got value = %v, want = %v", key, got, nil)
+		}
+	}
+
+	if err := file.PatchMetadata(Metadata{MetadataAppKey: "app"}, AppScope); err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+	} else if got, _ := file.Value(MetadataAppKey); got != "app" {
+		t.Errorf("got app = %v, want = %v", got, "app")
+	}
+
+	tooLong := make([]byte, MetadataValueMaxLen+1)
+	for index := range tooLong {
+		tooLong[index] = 'a'
+	}
+
+	tooMany := make(Metadata)
+	for index := 0; index <= MetadataMaxKeys; index++ {
+		tooMany[strconv.Itoa(index)] = "value"
+	}
+
+	for _, invalid := range []Metadata{{"": "value"}, {"key": string(tooLong)}, tooMany} {
+		if err := file.PatchMetadata(invalid, UserScope); !errors.Is(err, fb.ErrInvalidFormat) {
+			t.Errorf("got error = %v, want = %v", err, fb.ErrInvalidFormat)
+		}
+	}
+
+	if got, _ := file.Value("kept"); got != "value" {
+		t.Errorf("got kept = %v, want = %v", got, "value")
+	}
+}
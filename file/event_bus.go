@@ -2,6 +2,7 @@ package file
 
 import (
 	"encoding/json"
+	"path"
 
 	fb "github.com/alvidir/filebrowser"
 	"github.com/streadway/amqp"
@@ -13,8 +14,12 @@ type FileEventPayload struct {
 	FileName  string `json:"file_name"`
 	FileID    string `json:"file_id"`
 	Reference string `json:"file_reference"`
-	Issuer    string `json:"event_issuer"`
-	Kind      string `json:"event_kind"`
+	// Path is where the file is located in the user's directory, if the event concerns any.
+	Path string `json:"file_path,omitempty"`
+	// Destination is where the file has been moved to, if it has been moved.
+	Destination string `json:"file_destination,omitempty"`
+	Issuer      string `json:"event_issuer"`
+	Kind        string `json:"event_kind"`
 }
 
 type FileEventBus struct {
@@ -59,6 +64,23 @@ func (bus *FileEventBus) EmitFileCreated(uid int32, f *File) error {
 		Kind:     fb.EventKindCreated,
 	}
 
+	if len(f.Directory()) > 0 {
+		body.Path = path.Join("/", f.Directory(), f.Name())
+	}
+
+	return bus.emit(body)
+}
+
+func (bus *FileEventBus) EmitFileUpdated(uid int32, f *File) error {
+	body := FileEventPayload{
+		Issuer:   bus.issuer,
+		UserID:   uid,
+		AppID:    f.Metadata()[MetadataAppKey],
+		FileName: f.Name(),
+		FileID:   f.Id(),
+		Kind:     fb.EventKindUpdated,
+	}
+
 	return bus.emit(body)
 }
 
@@ -74,3 +96,49 @@ func (bus *FileEventBus) EmitFileDeleted(uid int32, f *File) error {
 
 	return bus.emit(body)
 }
+
+// EmitPathCreated notifies the given file has been placed at the path p of the user's directory.
+func (bus *FileEventBus) EmitPathCreated(uid int32, f *File, p string) error {
+	body := FileEventPayload{
+		Issuer:   bus.issuer,
+		UserID:   uid,
+		AppID:    f.Metadata()[MetadataAppKey],
+		FileName: f.Name(),
+		FileID:   f.Id(),
+		Path:     p,
+		Kind:     fb.EventKindPathCreated,
+	}
+
+	return bus.emit(body)
+}
+
+// EmitPathDeleted notifies the given file has been removed from the path p of the user's directory.
+func (bus *FileEventBus) EmitPathDeleted(uid int32, f *File, p string) error {
+	body := FileEventPayload{
+		Issuer:   bus.issuer,
+		UserID:   uid,
+		AppID:    f.Metadata()[MetadataAppKey],
+		FileName: f.Name(),
+		FileID:   f.Id(),
+		Path:     p,
+		Kind:     fb.EventKindPathDeleted,
+	}
+
+	return bus.emit(body)
+}
+
+// EmitPathMoved notifies the given file has been moved from one path of the user's directory to another.
+func (bus *FileEventBus) EmitPathMoved(uid int32, f *File, from, to string) error {
+	body := FileEventPayload{
+		Issuer:      bus.issuer,
+		UserID:      uid,
+		AppID:       f.Metadata()[MetadataAppKey],
+		FileName:    f.Name(),
+		FileID:      f.Id(),
+		Path:        from,
+		Destination: to,
+		Kind:        fb.EventKindPathMoved,
+	}
+
+	return bus.emit(body)
+}
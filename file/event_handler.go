@@ -55,6 +55,9 @@ func (handler *FileEventHandler) OnEvent(ctx context.Context, body []byte) {
 	case fb.EventKindDeleted:
 		handler.onFileDeletedEvent(ctx, event)
 
+	case fb.EventKindPathCreated, fb.EventKindPathMoved, fb.EventKindPathDeleted:
+		// changes made into a directory leave the files themselves untouched
+
 	default:
 		handler.logger.Warn("unhandled file event",
 			zap.String("kind", event.Kind))
@@ -76,6 +79,7 @@ func (handler *FileEventHandler) onFileCreatedEvent(ctx context.Context, event *
 			MetadataAppKey: event.AppID,
 			MetadataRefKey: event.FileID,
 		},
+		Scope: AppScope,
 	}
 
 	_, err := handler.fileApp.Create(ctx, event.UserID, &options)
@@ -101,6 +105,7 @@ func (handler *FileEventHandler) onFileUpdatedEvent(ctx context.Context, event *
 			MetadataAppKey: event.AppID,
 			MetadataRefKey: event.FileID,
 		},
+		Scope: AppScope,
 	}
 
 	_, err := handler.fileApp.Update(ctx, event.UserID, event.Reference, &options)
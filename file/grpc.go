@@ -1,13 +1,22 @@
 package file
 
 import (
+	"bytes"
 	"context"
+	"strings"
 
 	fb "github.com/alvidir/filebrowser"
 	"github.com/alvidir/filebrowser/proto"
 	"go.uber.org/zap"
 )
 
+const (
+	UpdateMaskName        = "name"
+	UpdateMaskData        = "data"
+	UpdateMaskMetadata    = "metadata"
+	UpdateMaskMetadataKey = "metadata."
+)
+
 type FileGrpcService struct {
 	proto.UnimplementedFileServiceServer
 	fileApp   *FileApplication
@@ -32,6 +41,109 @@ func NewPermissions(userId int32, perm Permission) *proto.Permissions {
 	}
 }
 
+func NewPermission(perms *proto.Permissions) (perm Permission) {
+	if perms.GetRead() {
+		perm |= Read
+	}
+
+	if perms.GetWrite() {
+		perm |= Write
+	}
+
+	if perms.GetOwner() {
+		perm |= Owner
+	}
+
+	return
+}
+
+func NewGroupPermissions(groupId string, perm Permission) *proto.GroupPermissions {
+	return &proto.GroupPermissions{
+		GroupId: groupId,
+		Read:    perm&Read != 0,
+		Write:   perm&Write != 0,
+	}
+}
+
+func NewGroupPermission(perms *proto.GroupPermissions) (perm Permission) {
+	if perms.GetRead() {
+		perm |= Read
+	}
+
+	if perms.GetWrite() {
+		perm |= Write
+	}
+
+	return
+}
+
+func NewView(view proto.FileView) (View, error) {
+	switch view {
+	case proto.FileView_FULL:
+		return FullView, nil
+	case proto.FileView_BASIC:
+		return BasicView, nil
+	case proto.FileView_DATA_ONLY:
+		return DataOnlyView, nil
+	default:
+		return FullView, fb.ErrInvalidFormat
+	}
+}
+
+// NewUpdateOptions returns the options updating, of the given file, just those fields listed in its mask, if any.
+// Otherwise, all those fields that are not empty get updated.
+func NewUpdateOptions(req *proto.File) (*UpdateOptions, error) {
+	options := &UpdateOptions{
+		Meta:     make(Metadata),
+		Revision: req.GetRevision(),
+	}
+
+	metadata := make(Metadata)
+	for _, meta := range req.GetMetadata() {
+		metadata[meta.GetKey()] = meta.GetValue()
+	}
+
+	mask := req.GetUpdateMask()
+	if len(mask.GetPaths()) == 0 {
+		options.Name = req.GetName()
+		options.Meta = metadata
+		options.Data = req.GetData()
+		return options, nil
+	}
+
+	for _, path := range mask.GetPaths() {
+		switch {
+		case path == UpdateMaskName:
+			if len(req.GetName()) == 0 {
+				// a file cannot be left with no name
+				return nil, fb.ErrInvalidFormat
+			}
+
+			options.Name = req.GetName()
+		case path == UpdateMaskData:
+			// unlike nil, empty data clears the content of the file
+			options.Data = append([]byte{}, req.GetData()...)
+		case path == UpdateMaskMetadata:
+			options.ReplaceMeta = true
+			for key, value := range metadata {
+				options.Meta[key] = value
+			}
+		case strings.HasPrefix(path, UpdateMaskMetadataKey):
+			key := strings.TrimPrefix(path, UpdateMaskMetadataKey)
+			if len(key) == 0 {
+				return nil, fb.ErrInvalidFormat
+			}
+
+			// a key missing in the file gets removed
+			options.Meta[key] = metadata[key]
+		default:
+			return nil, fb.ErrInvalidFormat
+		}
+	}
+
+	return options, nil
+}
+
 func NewProtoFile(file *File) *proto.File {
 	descriptor := &proto.File{
 		Id:          file.id,
@@ -41,6 +153,8 @@ func NewProtoFile(file *File) *proto.File {
 		Permissions: make([]*proto.Permissions, 0, len(file.permissions)),
 		Flags:       uint32(file.flags),
 		Data:        file.data,
+		Revision:    file.revision,
+		Groups:      make([]*proto.GroupPermissions, 0, len(file.groups)),
 	}
 
 	for key, value := range file.metadata {
@@ -54,9 +168,35 @@ func NewProtoFile(file *File) *proto.File {
 		descriptor.Permissions = append(descriptor.Permissions, NewPermissions(uid, perm))
 	}
 
+	for gid, perm := range file.groups {
+		descriptor.Groups = append(descriptor.Groups, NewGroupPermissions(gid, perm))
+	}
+
 	return descriptor
 }
 
+func NewProtoVersion(version *Version) *proto.Version {
+	return &proto.Version{
+		Id:          version.id,
+		FileId:      version.fileId,
+		Author:      version.author,
+		CreatedAt:   version.createdAt.Unix(),
+		Size:        version.size,
+		Hash:        version.hash,
+		ContentType: version.contentType,
+	}
+}
+
+func NewProtoUsage(usage *Usage) *proto.Usage {
+	return &proto.Usage{
+		UserId:   usage.userId,
+		Bytes:    usage.bytes,
+		Files:    usage.files,
+		MaxBytes: usage.quota.Bytes,
+		MaxFiles: usage.quota.Files,
+	}
+}
+
 func (server *FileGrpcService) Create(ctx context.Context, req *proto.File) (*proto.File, error) {
 	uid, err := fb.GetUidFromGrpcCtx(ctx, server.uidHeader, server.logger)
 	if err != nil {
@@ -88,7 +228,12 @@ func (server *FileGrpcService) Get(ctx context.Context, req *proto.File) (*proto
 		return nil, err
 	}
 
-	file, err := server.fileApp.Get(ctx, uid, req.GetId())
+	view, err := NewView(req.GetView())
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := server.fileApp.Get(ctx, uid, req.GetId(), &GetOptions{View: view})
 	if err != nil {
 		return nil, err
 	}
@@ -102,18 +247,144 @@ func (server *FileGrpcService) Update(ctx context.Context, req *proto.File) (*pr
 		return nil, err
 	}
 
-	options := UpdateOptions{
-		Name: req.GetName(),
-		Meta: make(Metadata),
-		Data: req.GetData(),
+	options, err := NewUpdateOptions(req)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := server.fileApp.Update(ctx, uid, req.GetId(), options)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewProtoFile(file), nil
+}
+
+func (server *FileGrpcService) Delete(ctx context.Context, req *proto.File) (*proto.File, error) {
+	uid, err := fb.GetUidFromGrpcCtx(ctx, server.uidHeader, server.logger)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := server.fileApp.Delete(ctx, uid, req.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	return NewProtoFile(file), nil
+}
+
+func (server *FileGrpcService) Upload(stream proto.FileService_UploadServer) error {
+	ctx := stream.Context()
+	uid, err := fb.GetUidFromGrpcCtx(ctx, server.uidHeader, server.logger)
+	if err != nil {
+		return err
+	}
+
+	// the first chunk must describe the file the following ones belong to
+	chunk, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+
+	req := chunk.GetFile()
+	if req == nil {
+		return fb.ErrInvalidFormat
+	}
+
+	options := UploadOptions{
+		Id:        req.GetId(),
+		Name:      req.GetName(),
+		Directory: req.GetDirectory(),
+		Meta:      make(Metadata),
 	}
 
-	metadata := make(Metadata)
 	for _, meta := range req.GetMetadata() {
-		metadata[meta.Key] = meta.Value
+		options.Meta[meta.GetKey()] = meta.GetValue()
 	}
 
-	file, err := server.fileApp.Update(ctx, uid, req.GetId(), &options)
+	reader := newChunkReader(func() ([]byte, error) {
+		chunk, err := stream.Recv()
+		if err != nil {
+			return nil, err
+		}
+
+		return chunk.GetData(), nil
+	})
+
+	file, err := server.fileApp.Upload(ctx, uid, &options, reader)
+	if err != nil {
+		return err
+	}
+
+	return stream.SendAndClose(NewProtoFile(file))
+}
+
+func (server *FileGrpcService) Download(req *proto.File, stream proto.FileService_DownloadServer) error {
+	ctx := stream.Context()
+	uid, err := fb.GetUidFromGrpcCtx(ctx, server.uidHeader, server.logger)
+	if err != nil {
+		return err
+	}
+
+	writer := newChunkWriter(func(data []byte) error {
+		return stream.Send(&proto.FileChunk{
+			Content: &proto.FileChunk_Data{
+				Data: data,
+			},
+		})
+	})
+
+	_, err = server.fileApp.Download(ctx, uid, req.GetId(), writer)
+	return err
+}
+
+func (server *FileGrpcService) ListVersions(ctx context.Context, req *proto.File) (*proto.VersionList, error) {
+	uid, err := fb.GetUidFromGrpcCtx(ctx, server.uidHeader, server.logger)
+	if err != nil {
+		return nil, err
+	}
+
+	versions, err := server.fileApp.ListVersions(ctx, uid, req.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	list := &proto.VersionList{
+		Versions: make([]*proto.Version, 0, len(versions)),
+	}
+
+	for _, version := range versions {
+		list.Versions = append(list.Versions, NewProtoVersion(version))
+	}
+
+	return list, nil
+}
+
+func (server *FileGrpcService) GetVersion(ctx context.Context, req *proto.VersionRequest) (*proto.Version, error) {
+	uid, err := fb.GetUidFromGrpcCtx(ctx, server.uidHeader, server.logger)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	version, err := server.fileApp.GetVersion(ctx, uid, req.GetFileId(), req.GetVersionId(), &buf)
+	if err != nil {
+		return nil, err
+	}
+
+	descriptor := NewProtoVersion(version)
+	descriptor.Data = buf.Bytes()
+	return descriptor, nil
+}
+
+func (server *FileGrpcService) RestoreVersion(ctx context.Context, req *proto.VersionRequest) (*proto.File, error) {
+	uid, err := fb.GetUidFromGrpcCtx(ctx, server.uidHeader, server.logger)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := server.fileApp.RestoreVersion(ctx, uid, req.GetFileId(), req.GetVersionId())
 	if err != nil {
 		return nil, err
 	}
@@ -121,16 +392,103 @@ func (server *FileGrpcService) Update(ctx context.Context, req *proto.File) (*pr
 	return NewProtoFile(file), nil
 }
 
-func (server *FileGrpcService) Delete(ctx context.Context, req *proto.File) (*proto.File, error) {
+func (server *FileGrpcService) Share(ctx context.Context, req *proto.ShareRequest) (*proto.File, error) {
 	uid, err := fb.GetUidFromGrpcCtx(ctx, server.uidHeader, server.logger)
 	if err != nil {
 		return nil, err
 	}
 
-	file, err := server.fileApp.Delete(ctx, uid, req.GetId())
+	perms := req.GetPermissions()
+	file, err := server.fileApp.Share(ctx, uid, req.GetFileId(), perms.GetUserId(), NewPermission(perms))
 	if err != nil {
 		return nil, err
 	}
 
 	return NewProtoFile(file), nil
 }
+
+func (server *FileGrpcService) Unshare(ctx context.Context, req *proto.ShareRequest) (*proto.File, error) {
+	uid, err := fb.GetUidFromGrpcCtx(ctx, server.uidHeader, server.logger)
+	if err != nil {
+		return nil, err
+	}
+
+	perms := req.GetPermissions()
+	file, err := server.fileApp.Unshare(ctx, uid, req.GetFileId(), perms.GetUserId(), NewPermission(perms))
+	if err != nil {
+		return nil, err
+	}
+
+	return NewProtoFile(file), nil
+}
+
+func (server *FileGrpcService) ShareWithGroup(ctx context.Context, req *proto.GroupShareRequest) (*proto.File, error) {
+	uid, err := fb.GetUidFromGrpcCtx(ctx, server.uidHeader, server.logger)
+	if err != nil {
+		return nil, err
+	}
+
+	perms := req.GetPermissions()
+	file, err := server.fileApp.ShareWithGroup(ctx, uid, req.GetFileId(), perms.GetGroupId(), NewGroupPermission(perms))
+	if err != nil {
+		return nil, err
+	}
+
+	return NewProtoFile(file), nil
+}
+
+func (server *FileGrpcService) UnshareWithGroup(ctx context.Context, req *proto.GroupShareRequest) (*proto.File, error) {
+	uid, err := fb.GetUidFromGrpcCtx(ctx, server.uidHeader, server.logger)
+	if err != nil {
+		return nil, err
+	}
+
+	perms := req.GetPermissions()
+	file, err := server.fileApp.UnshareWithGroup(ctx, uid, req.GetFileId(), perms.GetGroupId(), NewGroupPermission(perms))
+	if err != nil {
+		return nil, err
+	}
+
+	return NewProtoFile(file), nil
+}
+
+func (server *FileGrpcService) ListShares(ctx context.Context, req *proto.File) (*proto.ShareList, error) {
+	uid, err := fb.GetUidFromGrpcCtx(ctx, server.uidHeader, server.logger)
+	if err != nil {
+		return nil, err
+	}
+
+	perms, groups, err := server.fileApp.ListShares(ctx, uid, req.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	list := &proto.ShareList{
+		Permissions: make([]*proto.Permissions, 0, len(perms)),
+		Groups:      make([]*proto.GroupPermissions, 0, len(groups)),
+	}
+
+	for grantee, perm := range perms {
+		list.Permissions = append(list.Permissions, NewPermissions(grantee, perm))
+	}
+
+	for gid, perm := range groups {
+		list.Groups = append(list.Groups, NewGroupPermissions(gid, perm))
+	}
+
+	return list, nil
+}
+
+func (server *FileGrpcService) GetUsage(ctx context.Context, req *proto.UsageRequest) (*proto.Usage, error) {
+	uid, err := fb.GetUidFromGrpcCtx(ctx, server.uidHeader, server.logger)
+	if err != nil {
+		return nil, err
+	}
+
+	usage, err := server.fileApp.GetUsage(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewProtoUsage(usage), nil
+}
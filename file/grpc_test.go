@@ -0,0 +1,221 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	fb "github.com/alvidir/filebrowser"
+	"github.com/alvidir/filebrowser/proto"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/metadata"
+	gproto "google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+func TestFileGrpcUpdate(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(testUidHeader, "111"))
+	request := func(paths ...string) *proto.File {
+		return &proto.File{
+			Id:   "123",
+			Name: "renamed",
+			Metadata: []*proto.Metadata{
+				{Key: "color", Value: "red"},
+			},
+			UpdateMask: &fieldmaskpb.FieldMask{Paths: paths},
+		}
+	}
+
+	tests := []struct {
+		name string
+		file *proto.File
+		want *File
+		err  error
+	}{
+		{
+			name: "no mask updates all non empty fields",
+			file: request(),
+			want: &File{
+				name:     "renamed",
+				metadata: Metadata{"color": "red", "shape": "circle", MetadataSizeKey: "5"},
+				data:     []byte("hello"),
+			},
+		},
+		{
+			name: "name",
+			file: request(UpdateMaskName),
+			want: &File{
+				name:     "renamed",
+				metadata: Metadata{"color": "blue", "shape": "circle", MetadataSizeKey: "5"},
+				data:     []byte("hello"),
+			},
+		},
+		{
+			name: "empty name",
+			file: &proto.File{Id: "123", UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{UpdateMaskName}}},
+			err:  fb.ErrInvalidFormat,
+		},
+		{
+			name: "empty data",
+			file: request(UpdateMaskData),
+			want: &File{
+				name:     "notes.txt",
+				metadata: Metadata{"color": "blue", "shape": "circle", MetadataSizeKey: "0", MetadataContentTypeKey: "text/plain; charset=utf-8"},
+				data:     []byte{},
+			},
+		},
+		{
+			name: "metadata",
+			file: request(UpdateMaskMetadata),
+			want: &File{
+				name:     "notes.txt",
+				metadata: Metadata{"color": "red", MetadataSizeKey: "5"},
+				data:     []byte("hello"),
+			},
+		},
+		{
+			name: "metadata keys",
+			file: request(UpdateMaskMetadataKey+"color", UpdateMaskMetadataKey+"shape"),
+			want: &File{
+				name:     "notes.txt",
+				metadata: Metadata{"color": "red", MetadataSizeKey: "5"},
+				data:     []byte("hello"),
+			},
+		},
+		{
+			name: "empty metadata key",
+			file: request(UpdateMaskMetadataKey),
+			err:  fb.ErrInvalidFormat,
+		},
+		{
+			name: "unknown path",
+			file: request("permissions"),
+			err:  fb.ErrInvalidFormat,
+		},
+	}
+
+	for _, test := range tests {
+		repo := &fileRepositoryMock{
+			find: func(repo *fileRepositoryMock, ctx context.Context, id string) (*File, error) {
+				return &File{
+					id:          id,
+					name:        "notes.txt",
+					metadata:    Metadata{"color": "blue", "shape": "circle", MetadataSizeKey: "5"},
+					permissions: map[int32]Permission{111: Owner},
+					data:        []byte("hello"),
+				}, nil
+			},
+
+			save: func(repo *fileRepositoryMock, ctx context.Context, file *File) error {
+				return nil
+			},
+		}
+
+		app := NewFileApplication(repo, newContentStoreMock(&blobStoreMock{}, logger), &directoryApplicationMock{}, &groupApplicationMock{}, &EventBusMock{}, logger)
+		server := NewFileGrpcServer(app, testUidHeader, logger)
+
+		got, err := server.Update(ctx, test.file)
+		if !errors.Is(err, test.err) {
+			t.Errorf("%s: got error = %v, want = %v", test.name, err, test.err)
+			continue
+		}
+
+		if test.want == nil {
+			continue
+		}
+
+		if got.GetName() != test.want.name {
+			t.Errorf("%s: got name = %v, want = %v", test.name, got.GetName(), test.want.name)
+		}
+
+		if string(got.GetData()) != string(test.want.data) {
+			t.Errorf("%s: got data = %v, want = %v", test.name, got.GetData(), test.want.data)
+		}
+
+		gotMeta := make(Metadata)
+		for _, meta := range got.GetMetadata() {
+			if meta.GetKey() == MetadataUpdatedAtKey || meta.GetKey() == MetadataHashKey {
+				continue
+			}
+
+			gotMeta[meta.GetKey()] = meta.GetValue()
+		}
+
+		if len(gotMeta) != len(test.want.metadata) {
+			t.Errorf("%s: got metadata = %v, want = %v", test.name, gotMeta, test.want.metadata)
+			continue
+		}
+
+		for key, value := range test.want.metadata {
+			if gotMeta[key] != value {
+				t.Errorf("%s: got %s = %v, want = %v", test.name, key, gotMeta[key], value)
+			}
+		}
+	}
+}
+
+func TestFileGrpcUpdateWithNoMask(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	// a client unaware of the update mask sends the file as it did before
+	body, err := gproto.Marshal(&proto.File{
+		Id:       "123",
+		Name:     "renamed",
+		Metadata: []*proto.Metadata{{Key: "color", Value: "red"}},
+		Data:     []byte("bye"),
+	})
+
+	if err != nil {
+		t.Fatalf("got error = %v, want = %v", err, nil)
+	}
+
+	var req proto.File
+	if err := gproto.Unmarshal(body, &req); err != nil {
+		t.Fatalf("got error = %v, want = %v", err, nil)
+	}
+
+	var saved *File
+	repo := &fileRepositoryMock{
+		find: func(repo *fileRepositoryMock, ctx context.Context, id string) (*File, error) {
+			return &File{
+				id:          id,
+				name:        "notes.txt",
+				metadata:    Metadata{"color": "blue"},
+				permissions: map[int32]Permission{111: Owner},
+			}, nil
+		},
+
+		save: func(repo *fileRepositoryMock, ctx context.Context, file *File) error {
+			saved = file
+			return nil
+		},
+	}
+
+	app := NewFileApplication(repo, newContentStoreMock(&blobStoreMock{}, logger), &directoryApplicationMock{}, &groupApplicationMock{}, &EventBusMock{}, logger)
+	server := NewFileGrpcServer(app, testUidHeader, logger)
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(testUidHeader, "111"))
+	if _, err := server.Update(ctx, &req); err != nil {
+		t.Fatalf("got error = %v, want = %v", err, nil)
+	}
+
+	if saved == nil {
+		t.Fatalf("file repository's Save method did not execute")
+	}
+
+	if saved.Name() != "renamed" {
+		t.Errorf("got name = %v, want = %v", saved.Name(), "renamed")
+	}
+
+	if color, _ := saved.Value("color"); color != "red" {
+		t.Errorf("got color = %v, want = %v", color, "red")
+	}
+
+	if size, _ := saved.Value(MetadataSizeKey); size != "3" {
+		t.Errorf("got size = %v, want = %v", size, "3")
+	}
+}
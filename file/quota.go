@@ -0,0 +1,180 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	fb "github.com/alvidir/filebrowser"
+	"go.uber.org/zap"
+)
+
+type UsageRepository interface {
+	FindByUserId(ctx context.Context, uid int32) (*Usage, error)
+	Add(ctx context.Context, uid int32, bytes int64, files int64) error
+	AddWithin(ctx context.Context, uid int32, bytes int64, files int64, quota Quota) error
+}
+
+// QuotaStore keeps track of the storage each user consumes, rejecting any change that would make it exceed the
+// quota of the user. Users with no quota of their own are limited by the default one. A nil store limits nothing.
+type QuotaStore struct {
+	usageRepo UsageRepository
+	quota     Quota
+	logger    *zap.Logger
+}
+
+func NewQuotaStore(usageRepo UsageRepository, quota Quota, logger *zap.Logger) *QuotaStore {
+	return &QuotaStore{
+		usageRepo: usageRepo,
+		quota:     quota,
+		logger:    logger,
+	}
+}
+
+// Usage returns the storage the user uid consumes, together with the quota limiting it.
+func (store *QuotaStore) Usage(ctx context.Context, uid int32) (*Usage, error) {
+	if store == nil {
+		return &Usage{userId: uid}, nil
+	}
+
+	usage, err := store.usageRepo.FindByUserId(ctx, uid)
+	if errors.Is(err, fb.ErrNotFound) {
+		// a user consumes nothing until its first file
+		usage = &Usage{userId: uid}
+	} else if err != nil {
+		return nil, err
+	}
+
+	if usage.quota.Bytes == 0 {
+		usage.quota.Bytes = store.quota.Bytes
+	}
+
+	if usage.quota.Files == 0 {
+		usage.quota.Files = store.quota.Files
+	}
+
+	return usage, nil
+}
+
+// Reserve makes all the given owners consume the given amount of bytes and files, unless any of them would exceed
+// its quota, in which case nothing is consumed at all.
+func (store *QuotaStore) Reserve(ctx context.Context, owners []int32, bytes int64, files int64) error {
+	if store == nil || bytes == 0 && files == 0 {
+		return nil
+	}
+
+	if bytes <= 0 && files <= 0 {
+		return store.add(ctx, owners, bytes, files)
+	}
+
+	for index, uid := range owners {
+		if uid == 0 {
+			continue
+		}
+
+		err := store.usageRepo.AddWithin(ctx, uid, bytes, files, store.quota)
+		if err == nil {
+			continue
+		}
+
+		if errors.Is(err, fb.ErrQuotaExceeded) {
+			store.logger.Warn("reserving storage",
+				zap.Int32("user_id", uid),
+				zap.Int64("bytes", bytes),
+				zap.Int64("files", files),
+				zap.Error(err))
+		}
+
+		// the owners charged so far must not keep the reservation
+		store.Release(ctx, owners[:index], bytes, files)
+		return err
+	}
+
+	return nil
+}
+
+// Release makes all the given owners no longer consume the given amount of bytes and files. Since the storage is
+// already free, a failure here is logged but never reported to the caller.
+func (store *QuotaStore) Release(ctx context.Context, owners []int32, bytes int64, files int64) {
+	if store == nil || bytes == 0 && files == 0 {
+		return
+	}
+
+	if err := store.add(ctx, owners, -bytes, -files); err != nil {
+		store.logger.Warn("releasing storage",
+			zap.Int32s("user_ids", owners),
+			zap.Int64("bytes", bytes),
+			zap.Int64("files", files),
+			zap.Error(err))
+	}
+}
+
+// Available returns the least amount of bytes any of the given owners may still consume, if limited.
+func (store *QuotaStore) Available(ctx context.Context, owners []int32) (available int64, limited bool, err error) {
+	if store == nil {
+		return 0, false, nil
+	}
+
+	for _, uid := range owners {
+		if uid == 0 {
+			continue
+		}
+
+		usage, err := store.Usage(ctx, uid)
+		if err != nil {
+			return 0, false, err
+		}
+
+		if usage.quota.Bytes == 0 {
+			continue
+		}
+
+		if left := usage.quota.Bytes - usage.bytes; !limited || left < available {
+			available, limited = left, true
+		}
+	}
+
+	if available < 0 {
+		available = 0
+	}
+
+	return available, limited, nil
+}
+
+func (store *QuotaStore) add(ctx context.Context, owners []int32, bytes int64, files int64) error {
+	if store == nil || bytes == 0 && files == 0 {
+		return nil
+	}
+
+	for _, uid := range owners {
+		if uid == 0 {
+			continue
+		}
+
+		if err := store.usageRepo.Add(ctx, uid, bytes, files); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// quotaReader reads from r, failing as soon as more than n bytes have been read.
+type quotaReader struct {
+	r io.Reader
+	n int64
+}
+
+func (qr *quotaReader) Read(p []byte) (int, error) {
+	n, err := qr.r.Read(p)
+	if qr.n -= int64(n); qr.n < 0 {
+		return n, fb.ErrQuotaExceeded
+	}
+
+	return n, err
+}
+
+// exceeded returns true if, and only if, more bytes than allowed have been read.
+func (qr *quotaReader) exceeded() bool {
+	return qr.n < 0
+}
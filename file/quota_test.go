@@ -0,0 +1,240 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	fb "github.com/alvidir/filebrowser"
+	"go.uber.org/zap"
+)
+
+// usageRepositoryStub keeps the usage of each user in memory.
+type usageRepositoryStub struct {
+	usages map[int32]*Usage
+}
+
+func (stub *usageRepositoryStub) FindByUserId(ctx context.Context, uid int32) (*Usage, error) {
+	usage, exists := stub.usages[uid]
+	if !exists {
+		return nil, fb.ErrNotFound
+	}
+
+	found := *usage
+	return &found, nil
+}
+
+func (stub *usageRepositoryStub) Add(ctx context.Context, uid int32, bytes int64, files int64) error {
+	if stub.usages == nil {
+		stub.usages = make(map[int32]*Usage)
+	}
+
+	usage, exists := stub.usages[uid]
+	if !exists {
+		usage = &Usage{userId: uid}
+		stub.usages[uid] = usage
+	}
+
+	usage.bytes += bytes
+	usage.files += files
+	return nil
+}
+
+func (stub *usageRepositoryStub) AddWithin(ctx context.Context, uid int32, bytes int64, files int64, quota Quota) error {
+	within := Usage{userId: uid, quota: quota}
+	if usage, exists := stub.usages[uid]; exists {
+		within.bytes, within.files = usage.bytes, usage.files
+		if usage.quota.Bytes != 0 {
+			within.quota.Bytes = usage.quota.Bytes
+		}
+
+		if usage.quota.Files != 0 {
+			within.quota.Files = usage.quota.Files
+		}
+	}
+
+	if within.Exceeds(bytes, files) {
+		return fb.ErrQuotaExceeded
+	}
+
+	return stub.Add(ctx, uid, bytes, files)
+}
+
+func TestQuotaStoreReserve(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	ctx := context.Background()
+	repo := &usageRepositoryStub{
+		usages: map[int32]*Usage{
+			222: {userId: 222, quota: Quota{Files: 3}},
+		},
+	}
+
+	store := NewQuotaStore(repo, Quota{Bytes: 10, Files: 1}, logger)
+	if err := store.Reserve(ctx, []int32{111, 222}, 5, 1); err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+		return
+	}
+
+	// the user 111 has no quota of its own, and so it is limited by the default one
+	if err := store.Reserve(ctx, []int32{111, 222}, 0, 1); !errors.Is(err, fb.ErrQuotaExceeded) {
+		t.Errorf("got error = %v, want = %v", err, fb.ErrQuotaExceeded)
+	}
+
+	if err := store.Reserve(ctx, []int32{222}, 0, 1); err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+	}
+
+	tests := []struct {
+		uid      int32
+		bytes    int64
+		files    int64
+		maxFiles int64
+	}{
+		{uid: 111, bytes: 5, files: 1, maxFiles: 1},
+		{uid: 222, bytes: 5, files: 2, maxFiles: 3},
+	}
+
+	for _, test := range tests {
+		usage, err := store.Usage(ctx, test.uid)
+		if err != nil {
+			t.Errorf("%v: got error = %v, want = %v", test.uid, err, nil)
+			continue
+		}
+
+		if usage.Bytes() != test.bytes {
+			t.Errorf("%v: got bytes = %v, want = %v", test.uid, usage.Bytes(), test.bytes)
+		}
+
+		if usage.Files() != test.files {
+			t.Errorf("%v: got files = %v, want = %v", test.uid, usage.Files(), test.files)
+		}
+
+		if usage.Quota().Files != test.maxFiles {
+			t.Errorf("%v: got max files = %v, want = %v", test.uid, usage.Quota().Files, test.maxFiles)
+		}
+	}
+
+	store.Release(ctx, []int32{111}, 5, 1)
+	if usage, _ := store.Usage(ctx, 111); usage.Bytes() != 0 || usage.Files() != 0 {
+		t.Errorf("got usage = %v/%v, want = %v/%v", usage.Bytes(), usage.Files(), 0, 0)
+	}
+}
+
+func TestQuotaStoreReserveWhenLastOwnerExceeds(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	ctx := context.Background()
+	repo := &usageRepositoryStub{
+		usages: map[int32]*Usage{
+			222: {userId: 222, bytes: 8},
+		},
+	}
+
+	store := NewQuotaStore(repo, Quota{Bytes: 10}, logger)
+	if err := store.Reserve(ctx, []int32{111, 222}, 5, 1); !errors.Is(err, fb.ErrQuotaExceeded) {
+		t.Errorf("got error = %v, want = %v", err, fb.ErrQuotaExceeded)
+	}
+
+	// the user 111 had room enough, but it must not keep a reservation that failed as a whole
+	if usage, _ := store.Usage(ctx, 111); usage.Bytes() != 0 || usage.Files() != 0 {
+		t.Errorf("got usage = %v/%v, want = %v/%v", usage.Bytes(), usage.Files(), 0, 0)
+	}
+
+	if usage, _ := store.Usage(ctx, 222); usage.Bytes() != 8 || usage.Files() != 0 {
+		t.Errorf("got usage = %v/%v, want = %v/%v", usage.Bytes(), usage.Files(), 8, 0)
+	}
+}
+
+func TestContentStoreWriteWhenQuotaExceeded(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	ctx := context.Background()
+	quotas := NewQuotaStore(&usageRepositoryStub{}, Quota{Bytes: 10}, logger)
+	store := NewContentStore(NewLocalBlobStore(t.TempDir(), logger), &versionRepositoryStub{}, 0, logger)
+	store.SetQuotaStore(quotas)
+
+	file, _ := NewFile("123", "testing")
+	file.AddPermission(111, Owner)
+
+	// the current content is released once replaced, so it does not count against the new one
+	for _, content := range []string{"12345678", "1234567890"} {
+		if _, err := store.Write(ctx, 111, file, bytes.NewReader([]byte(content))); err != nil {
+			t.Errorf("got error = %v, want = %v", err, nil)
+			return
+		}
+	}
+
+	if _, err := store.Write(ctx, 111, file, bytes.NewReader([]byte("12345678901"))); !errors.Is(err, fb.ErrQuotaExceeded) {
+		t.Errorf("got error = %v, want = %v", err, fb.ErrQuotaExceeded)
+	}
+
+	if usage, _ := quotas.Usage(ctx, 111); usage.Bytes() != 10 {
+		t.Errorf("got bytes = %v, want = %v", usage.Bytes(), 10)
+	}
+
+	var buf bytes.Buffer
+	if err := store.Read(ctx, file, &buf); err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+	} else if got := buf.String(); got != "1234567890" {
+		t.Errorf("got data = %v, want = %v", got, "1234567890")
+	}
+
+	if err := store.Delete(ctx, file); err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+	}
+
+	if usage, _ := quotas.Usage(ctx, 111); usage.Bytes() != 0 {
+		t.Errorf("got bytes = %v, want = %v", usage.Bytes(), 0)
+	}
+}
+
+func TestContentStoreWriteWhenQuotaConsumedMeanwhile(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	ctx := context.Background()
+	usageRepo := &usageRepositoryStub{}
+
+	var deleted string
+	blobs := &blobStoreMock{
+		put: func(ctx context.Context, key string, r io.Reader) (int64, error) {
+			// a concurrent write consumes the storage once the available one has been checked
+			usageRepo.Add(ctx, 111, 8, 0)
+			return io.Copy(io.Discard, r)
+		},
+
+		delete: func(ctx context.Context, key string) error {
+			deleted = key
+			return nil
+		},
+	}
+
+	versionRepo := &versionRepositoryStub{}
+	store := NewContentStore(blobs, versionRepo, 0, logger)
+	store.SetQuotaStore(NewQuotaStore(usageRepo, Quota{Bytes: 10}, logger))
+
+	file, _ := NewFile("123", "testing")
+	file.AddPermission(111, Owner)
+
+	if _, err := store.Write(ctx, 111, file, bytes.NewReader([]byte("12345"))); !errors.Is(err, fb.ErrQuotaExceeded) {
+		t.Errorf("got error = %v, want = %v", err, fb.ErrQuotaExceeded)
+	}
+
+	if len(deleted) == 0 {
+		t.Errorf("blob store's Delete method did not execute")
+	}
+
+	if len(versionRepo.versions) != 0 {
+		t.Errorf("got versions = %v, want = %v", len(versionRepo.versions), 0)
+	}
+
+	if usage, _ := store.Quotas().Usage(ctx, 111); usage.Bytes() != 8 {
+		t.Errorf("got bytes = %v, want = %v", usage.Bytes(), 8)
+	}
+}
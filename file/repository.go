@@ -1,27 +1,38 @@
 package file
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"io"
+	"time"
 
 	fb "github.com/alvidir/filebrowser"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.uber.org/zap"
 )
 
 const (
-	MongoFileCollectionName = "files"
+	MongoFileCollectionName    = "files"
+	MongoVersionCollectionName = "versions"
+	MongoUsageCollectionName   = "usages"
+	MongoBlobRefCollectionName = "blob_refs"
 )
 
 type mongoFile struct {
-	ID          primitive.ObjectID   `bson:"_id,omitempty"`
-	Name        string               `bson:"name"`
-	Flags       Flag                 `bson:"flags"`
-	Permissions map[int32]Permission `bson:"permissions,omitempty"`
-	Metadata    map[string]string    `bson:"metadata,omitempty"`
-	Data        []byte               `bson:"data,omitempty"`
+	ID          primitive.ObjectID              `bson:"_id,omitempty"`
+	Name        string                          `bson:"name"`
+	Flags       Flag                            `bson:"flags"`
+	Permissions map[int32]Permission            `bson:"permissions,omitempty"`
+	Groups      map[string]Permission           `bson:"groups,omitempty"`
+	Inherited   map[string]map[int32]Permission `bson:"inherited,omitempty"`
+	Metadata    map[string]string               `bson:"metadata,omitempty"`
+	Blob        string                          `bson:"blob,omitempty"`
+	Revision    int64                           `bson:"revision"`
 }
 
 func newMongoFile(f *File) (*mongoFile, error) {
@@ -39,8 +50,11 @@ func newMongoFile(f *File) (*mongoFile, error) {
 		Name:        f.name,
 		Flags:       f.flags,
 		Permissions: f.permissions,
+		Groups:      f.groups,
+		Inherited:   f.inherited,
 		Metadata:    f.metadata,
-		Data:        f.data,
+		Blob:        f.blob,
+		Revision:    f.revision,
 	}, nil
 }
 
@@ -87,19 +101,27 @@ func (repo *MongoFileRepository) Create(ctx context.Context, file *File) error {
 	return fb.ErrUnknown
 }
 
-func (repo *MongoFileRepository) Find(ctx context.Context, id string) (*File, error) {
+func (repo *MongoFileRepository) Find(ctx context.Context, id string, opts *RepoOptions) (*File, error) {
 	objID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
-		repo.logger.Error("parsing file id to ObjectID",
+		repo.logger.Warn("parsing file id to ObjectID",
 			zap.String("file_id", id),
 			zap.Error(err))
 
-		return nil, fb.ErrUnknown
+		return nil, fb.ErrNotFound
+	}
+
+	findOpts := options.FindOne()
+	projection := mongoFileProjection(opts)
+	if projection != nil {
+		findOpts.SetProjection(projection)
 	}
 
 	var mfile mongoFile
-	err = repo.conn.FindOne(ctx, bson.M{"_id": objID}).Decode(&mfile)
-	if err != nil {
+	err = repo.conn.FindOne(ctx, bson.M{"_id": objID}, findOpts).Decode(&mfile)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, fb.ErrNotFound
+	} else if err != nil {
 		repo.logger.Error("performing find one on mongo",
 			zap.String("file_id", id),
 			zap.Error(err))
@@ -107,10 +129,33 @@ func (repo *MongoFileRepository) Find(ctx context.Context, id string) (*File, er
 		return nil, fb.ErrUnknown
 	}
 
-	return repo.build(&mfile), nil
+	file := repo.build(&mfile)
+	if projection != nil {
+		// a partially loaded file must never be saved, or the missing fields would get lost
+		file.MarkAsProtected()
+	}
+
+	return file, nil
 }
 
-// FindAll returns all those files matching the given ids, excluding the data field
+// mongoFileProjection returns the fields of a file document required by the given options, or nil if all of them.
+// Since the content of a file is kept apart from its document, only the data-only view has anything to exclude.
+func mongoFileProjection(opts *RepoOptions) bson.D {
+	if opts == nil || opts.View != DataOnlyView {
+		return nil
+	}
+
+	return bson.D{
+		{Key: "flags", Value: 1},
+		{Key: "permissions", Value: 1},
+		{Key: "groups", Value: 1},
+		{Key: "inherited", Value: 1},
+		{Key: "blob", Value: 1},
+		{Key: "revision", Value: 1},
+	}
+}
+
+// FindAll returns all those files matching the given ids
 func (repo *MongoFileRepository) FindAll(ctx context.Context, ids []string) ([]*File, error) {
 	objIDs := make([]primitive.ObjectID, len(ids))
 	for index, id := range ids {
@@ -126,9 +171,7 @@ func (repo *MongoFileRepository) FindAll(ctx context.Context, ids []string) ([]*
 		objIDs[index] = objID
 	}
 
-	// exclude data field from being loaded
-	opts := options.Find().SetProjection(bson.D{{Key: "data", Value: 0}})
-	cursor, err := repo.conn.Find(ctx, bson.M{"_id": bson.M{"$in": objIDs}}, opts)
+	cursor, err := repo.conn.Find(ctx, bson.M{"_id": bson.M{"$in": objIDs}})
 	if err != nil {
 		repo.logger.Error("performing find all on mongo",
 			zap.Strings("file_ids", ids),
@@ -171,7 +214,8 @@ func (repo *MongoFileRepository) Save(ctx context.Context, file *File) error {
 		return fb.ErrUnknown
 	}
 
-	result, err := repo.conn.ReplaceOne(ctx, bson.M{"_id": mFile.ID}, mFile)
+	mFile.Revision = file.revision + 1
+	result, err := repo.conn.ReplaceOne(ctx, fb.NewMongoRevisionFilter(mFile.ID, file.revision), mFile)
 	if err != nil {
 		repo.logger.Error("performing replace one on mongo",
 			zap.String("file_id", file.id),
@@ -180,14 +224,17 @@ func (repo *MongoFileRepository) Save(ctx context.Context, file *File) error {
 		return fb.ErrUnknown
 	}
 
-	if result.ModifiedCount == 0 {
-		repo.logger.Error("performing replace one on mongo",
+	if result.MatchedCount == 0 {
+		// the file has been either modified or deleted since read
+		repo.logger.Warn("performing replace one on mongo",
 			zap.String("file_id", file.id),
-			zap.Int64("modified_count", result.ModifiedCount))
+			zap.Int64("revision", file.revision),
+			zap.Error(fb.ErrConflict))
 
-		return fb.ErrUnknown
+		return fb.ErrConflict
 	}
 
+	file.revision = mFile.Revision
 	return nil
 }
 
@@ -221,13 +268,584 @@ func (repo *MongoFileRepository) Delete(ctx context.Context, file *File) error {
 	return nil
 }
 
+// mongoLegacyContent is the content a file document held before the BlobStore was introduced, either inline or
+// as the id of the GridFS file holding it.
+type mongoLegacyContent struct {
+	ID      primitive.ObjectID `bson:"_id"`
+	Data    []byte             `bson:"data,omitempty"`
+	Content primitive.ObjectID `bson:"content,omitempty"`
+}
+
+// MigrateLegacyContent moves into the given blob store the content of all those files still holding it in their
+// own document, keyed by the file id as File.Blob expects. Once moved, the content is removed from the document.
+func (repo *MongoFileRepository) MigrateLegacyContent(ctx context.Context, blobs BlobStore) error {
+	bucket, err := gridfs.NewBucket(repo.conn.Database())
+	if err != nil {
+		repo.logger.Error("creating gridfs bucket",
+			zap.Error(err))
+
+		return fb.ErrUnknown
+	}
+
+	filter := bson.M{"$or": bson.A{
+		bson.M{"data": bson.M{"$exists": true}},
+		bson.M{"content": bson.M{"$exists": true}},
+	}}
+
+	opts := options.Find().SetProjection(bson.D{{Key: "data", Value: 1}, {Key: "content", Value: 1}})
+	cursor, err := repo.conn.Find(ctx, filter, opts)
+	if err != nil {
+		repo.logger.Error("performing find legacy content on mongo",
+			zap.Error(err))
+
+		return fb.ErrUnknown
+	}
+
+	defer cursor.Close(ctx)
+
+	migrated := 0
+	for cursor.Next(ctx) {
+		var legacy mongoLegacyContent
+		if err := cursor.Decode(&legacy); err != nil {
+			repo.logger.Error("decoding legacy content",
+				zap.Error(err))
+
+			return fb.ErrUnknown
+		}
+
+		if err := repo.migrateContent(ctx, bucket, blobs, &legacy); err != nil {
+			return err
+		}
+
+		migrated++
+	}
+
+	if err := cursor.Err(); err != nil {
+		repo.logger.Error("iterating legacy content",
+			zap.Error(err))
+
+		return fb.ErrUnknown
+	}
+
+	if migrated > 0 {
+		repo.logger.Info("legacy file content migrated",
+			zap.Int("files", migrated))
+	}
+
+	return nil
+}
+
+func (repo *MongoFileRepository) migrateContent(ctx context.Context, bucket *gridfs.Bucket, blobs BlobStore, legacy *mongoLegacyContent) error {
+	var r io.Reader = bytes.NewReader(legacy.Data)
+	if !legacy.Content.IsZero() {
+		stream, err := bucket.OpenDownloadStream(legacy.Content)
+		if err != nil {
+			repo.logger.Error("opening gridfs download stream",
+				zap.String("file_id", legacy.ID.Hex()),
+				zap.String("content_id", legacy.Content.Hex()),
+				zap.Error(err))
+
+			return fb.ErrUnknown
+		}
+
+		defer stream.Close()
+		r = stream
+	}
+
+	if _, err := blobs.Put(ctx, legacy.ID.Hex(), r); err != nil {
+		return err
+	}
+
+	update := bson.M{"$unset": bson.M{"data": "", "content": ""}}
+	if _, err := repo.conn.UpdateOne(ctx, bson.M{"_id": legacy.ID}, update); err != nil {
+		repo.logger.Error("performing update one on mongo",
+			zap.String("file_id", legacy.ID.Hex()),
+			zap.Error(err))
+
+		return fb.ErrUnknown
+	}
+
+	if legacy.Content.IsZero() {
+		return nil
+	}
+
+	// the content is no longer referenced, so a failure here is logged but never reported to the caller
+	if err := bucket.Delete(legacy.Content); err != nil {
+		repo.logger.Warn("deleting legacy gridfs content",
+			zap.String("content_id", legacy.Content.Hex()),
+			zap.Error(err))
+	}
+
+	return nil
+}
+
 func (repo *MongoFileRepository) build(mfile *mongoFile) *File {
 	return &File{
 		id:          mfile.ID.Hex(),
 		name:        mfile.Name,
 		metadata:    mfile.Metadata,
 		permissions: mfile.Permissions,
+		groups:      mfile.Groups,
+		inherited:   mfile.Inherited,
 		flags:       mfile.Flags,
-		data:        mfile.Data,
+		blob:        mfile.Blob,
+		revision:    mfile.Revision,
 	}
 }
+
+type mongoVersion struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty"`
+	FileID      primitive.ObjectID `bson:"file_id"`
+	Author      int32              `bson:"author"`
+	CreatedAt   time.Time          `bson:"created_at"`
+	Size        int64              `bson:"size"`
+	Hash        string             `bson:"hash"`
+	ContentType string             `bson:"content_type,omitempty"`
+	Blob        string             `bson:"blob"`
+}
+
+func newMongoVersion(v *Version) (*mongoVersion, error) {
+	fileID, err := primitive.ObjectIDFromHex(v.fileId)
+	if err != nil {
+		return nil, err
+	}
+
+	oid := primitive.NilObjectID
+	if len(v.id) > 0 {
+		if oid, err = primitive.ObjectIDFromHex(v.id); err != nil {
+			return nil, err
+		}
+	}
+
+	return &mongoVersion{
+		ID:          oid,
+		FileID:      fileID,
+		Author:      v.author,
+		CreatedAt:   v.createdAt,
+		Size:        v.size,
+		Hash:        v.hash,
+		ContentType: v.contentType,
+		Blob:        v.blob,
+	}, nil
+}
+
+type MongoVersionRepository struct {
+	conn   *mongo.Collection
+	logger *zap.Logger
+}
+
+func NewMongoVersionRepository(db *mongo.Database, logger *zap.Logger) *MongoVersionRepository {
+	return &MongoVersionRepository{
+		conn:   db.Collection(MongoVersionCollectionName),
+		logger: logger,
+	}
+}
+
+func (repo *MongoVersionRepository) Create(ctx context.Context, version *Version) error {
+	mversion, err := newMongoVersion(version)
+	if err != nil {
+		repo.logger.Error("building mongo version",
+			zap.String("file_id", version.fileId),
+			zap.Error(err))
+
+		return fb.ErrUnknown
+	}
+
+	res, err := repo.conn.InsertOne(ctx, mversion)
+	if err != nil {
+		repo.logger.Error("performing insert one on mongo",
+			zap.String("file_id", version.fileId),
+			zap.Error(err))
+
+		return fb.ErrUnknown
+	}
+
+	if versionId, ok := res.InsertedID.(primitive.ObjectID); ok {
+		version.id = versionId.Hex()
+		return nil
+	}
+
+	repo.logger.Error("performing insert one on mongo",
+		zap.String("file_id", version.fileId),
+		zap.Error(err))
+
+	return fb.ErrUnknown
+}
+
+func (repo *MongoVersionRepository) Find(ctx context.Context, id string) (*Version, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		repo.logger.Error("parsing version id to ObjectID",
+			zap.String("version_id", id),
+			zap.Error(err))
+
+		return nil, fb.ErrNotFound
+	}
+
+	var mversion mongoVersion
+	err = repo.conn.FindOne(ctx, bson.M{"_id": objID}).Decode(&mversion)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, fb.ErrNotFound
+	} else if err != nil {
+		repo.logger.Error("performing find one on mongo",
+			zap.String("version_id", id),
+			zap.Error(err))
+
+		return nil, fb.ErrUnknown
+	}
+
+	return repo.build(&mversion), nil
+}
+
+// FindByFileId returns all the versions of the file with the given id, sorted from the newest to the oldest.
+func (repo *MongoVersionRepository) FindByFileId(ctx context.Context, fid string) ([]*Version, error) {
+	objID, err := primitive.ObjectIDFromHex(fid)
+	if err != nil {
+		repo.logger.Error("parsing file id to ObjectID",
+			zap.String("file_id", fid),
+			zap.Error(err))
+
+		return nil, fb.ErrUnknown
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}})
+	cursor, err := repo.conn.Find(ctx, bson.M{"file_id": objID}, opts)
+	if err != nil {
+		repo.logger.Error("performing find on mongo",
+			zap.String("file_id", fid),
+			zap.Error(err))
+
+		return nil, fb.ErrUnknown
+	}
+
+	var mversions []mongoVersion
+	if err := cursor.All(ctx, &mversions); err != nil {
+		repo.logger.Error("decoding found items",
+			zap.Error(err))
+
+		return nil, fb.ErrUnknown
+	}
+
+	versions := make([]*Version, len(mversions))
+	for index, mversion := range mversions {
+		versions[index] = repo.build(&mversion)
+	}
+
+	return versions, nil
+}
+
+func (repo *MongoVersionRepository) Delete(ctx context.Context, version *Version) error {
+	objID, err := primitive.ObjectIDFromHex(version.id)
+	if err != nil {
+		repo.logger.Error("parsing version id to ObjectID",
+			zap.String("version_id", version.id),
+			zap.Error(err))
+
+		return fb.ErrUnknown
+	}
+
+	if _, err := repo.conn.DeleteOne(ctx, bson.M{"_id": objID}); err != nil {
+		repo.logger.Error("performing delete one on mongo",
+			zap.String("version_id", version.id),
+			zap.Error(err))
+
+		return fb.ErrUnknown
+	}
+
+	return nil
+}
+
+func (repo *MongoVersionRepository) DeleteByFileId(ctx context.Context, fid string) error {
+	objID, err := primitive.ObjectIDFromHex(fid)
+	if err != nil {
+		repo.logger.Error("parsing file id to ObjectID",
+			zap.String("file_id", fid),
+			zap.Error(err))
+
+		return fb.ErrUnknown
+	}
+
+	if _, err := repo.conn.DeleteMany(ctx, bson.M{"file_id": objID}); err != nil {
+		repo.logger.Error("performing delete many on mongo",
+			zap.String("file_id", fid),
+			zap.Error(err))
+
+		return fb.ErrUnknown
+	}
+
+	return nil
+}
+
+func (repo *MongoVersionRepository) build(mversion *mongoVersion) *Version {
+	return &Version{
+		id:          mversion.ID.Hex(),
+		fileId:      mversion.FileID.Hex(),
+		author:      mversion.Author,
+		createdAt:   mversion.CreatedAt,
+		size:        mversion.Size,
+		hash:        mversion.Hash,
+		contentType: mversion.ContentType,
+		blob:        mversion.Blob,
+	}
+}
+
+type mongoQuota struct {
+	Bytes int64 `bson:"bytes,omitempty"`
+	Files int64 `bson:"files,omitempty"`
+}
+
+type mongoUsage struct {
+	ID     primitive.ObjectID `bson:"_id,omitempty"`
+	UserID int32              `bson:"user_id"`
+	Bytes  int64              `bson:"bytes"`
+	Files  int64              `bson:"files"`
+	Quota  *mongoQuota        `bson:"quota,omitempty"`
+}
+
+// MongoUsageRepository keeps the storage consumed by each user, as well as the quota of those users having one
+// of their own, which is set straight into mongo.
+type MongoUsageRepository struct {
+	conn   *mongo.Collection
+	logger *zap.Logger
+}
+
+func NewMongoUsageRepository(db *mongo.Database, logger *zap.Logger) *MongoUsageRepository {
+	return &MongoUsageRepository{
+		conn:   db.Collection(MongoUsageCollectionName),
+		logger: logger,
+	}
+}
+
+// CreateIndexes makes sure there is a single usage per user, so concurrent upserts cannot split it in two.
+func (repo *MongoUsageRepository) CreateIndexes(ctx context.Context) error {
+	return mongoCreateUniqueIndex(ctx, repo.conn, "user_id")
+}
+
+func (repo *MongoUsageRepository) FindByUserId(ctx context.Context, uid int32) (*Usage, error) {
+	var musage mongoUsage
+	err := repo.conn.FindOne(ctx, bson.M{"user_id": uid}).Decode(&musage)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, fb.ErrNotFound
+	} else if err != nil {
+		repo.logger.Error("performing find one on mongo",
+			zap.Int32("user_id", uid),
+			zap.Error(err))
+
+		return nil, fb.ErrUnknown
+	}
+
+	usage := &Usage{
+		userId: musage.UserID,
+		bytes:  musage.Bytes,
+		files:  musage.Files,
+	}
+
+	if musage.Quota != nil {
+		usage.quota = Quota{
+			Bytes: musage.Quota.Bytes,
+			Files: musage.Quota.Files,
+		}
+	}
+
+	return usage, nil
+}
+
+// Add increments, atomically, the storage consumed by the user uid by the given amount of bytes and files.
+func (repo *MongoUsageRepository) Add(ctx context.Context, uid int32, bytes int64, files int64) error {
+	update := bson.M{"$inc": bson.M{"bytes": bytes, "files": files}}
+	if err := mongoUpsert(func() error {
+		_, err := repo.conn.UpdateOne(ctx, bson.M{"user_id": uid}, update, options.Update().SetUpsert(true))
+		return err
+	}); err != nil {
+		repo.logger.Error("performing update one on mongo",
+			zap.Int32("user_id", uid),
+			zap.Int64("bytes", bytes),
+			zap.Int64("files", files),
+			zap.Error(err))
+
+		return fb.ErrUnknown
+	}
+
+	return nil
+}
+
+// AddWithin increments, atomically, the storage consumed by the user uid by the given amount of bytes and files,
+// unless that would make it exceed its quota, or the given one if the user has none of its own.
+func (repo *MongoUsageRepository) AddWithin(ctx context.Context, uid int32, bytes int64, files int64, quota Quota) error {
+	// the usage must exist beforehand, since a conditional update matching nothing must not upsert it
+	insert := bson.M{"$setOnInsert": bson.M{"bytes": int64(0), "files": int64(0)}}
+	if err := mongoUpsert(func() error {
+		_, err := repo.conn.UpdateOne(ctx, bson.M{"user_id": uid}, insert, options.Update().SetUpsert(true))
+		return err
+	}); err != nil {
+		repo.logger.Error("performing update one on mongo",
+			zap.Int32("user_id", uid),
+			zap.Error(err))
+
+		return fb.ErrUnknown
+	}
+
+	filter := bson.M{
+		"user_id": uid,
+		"$expr": bson.M{"$and": bson.A{
+			mongoWithinQuota("bytes", bytes, quota.Bytes),
+			mongoWithinQuota("files", files, quota.Files),
+		}},
+	}
+
+	result, err := repo.conn.UpdateOne(ctx, filter, bson.M{"$inc": bson.M{"bytes": bytes, "files": files}})
+	if err != nil {
+		repo.logger.Error("performing update one on mongo",
+			zap.Int32("user_id", uid),
+			zap.Int64("bytes", bytes),
+			zap.Int64("files", files),
+			zap.Error(err))
+
+		return fb.ErrUnknown
+	}
+
+	if result.MatchedCount == 0 {
+		return fb.ErrQuotaExceeded
+	}
+
+	return nil
+}
+
+// mongoUpsert runs the given upsert, which is retried once if a concurrent one has inserted the same document in
+// the meanwhile, since then the retry matches it instead.
+func mongoUpsert(upsert func() error) error {
+	err := upsert()
+	if mongo.IsDuplicateKeyError(err) {
+		err = upsert()
+	}
+
+	return err
+}
+
+// mongoCreateUniqueIndex makes sure no two documents of the given collection share the same value of the given
+// field.
+func mongoCreateUniqueIndex(ctx context.Context, conn *mongo.Collection, field string) error {
+	index := mongo.IndexModel{
+		Keys:    bson.D{{Key: field, Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}
+
+	_, err := conn.Indexes().CreateOne(ctx, index)
+	return err
+}
+
+// mongoWithinQuota returns the expression telling if the given field can grow by n without exceeding its quota,
+// being zero an unlimited one.
+func mongoWithinQuota(field string, n int64, fallback int64) bson.M {
+	if n <= 0 {
+		return bson.M{"$literal": true}
+	}
+
+	limit := bson.M{"$ifNull": bson.A{"$quota." + field, fallback}}
+	return bson.M{"$or": bson.A{
+		bson.M{"$lte": bson.A{limit, 0}},
+		bson.M{"$lte": bson.A{bson.M{"$add": bson.A{"$" + field, n}}, limit}},
+	}}
+}
+
+type mongoBlobRef struct {
+	ID   primitive.ObjectID `bson:"_id,omitempty"`
+	Hash string             `bson:"hash"`
+	Key  string             `bson:"key"`
+	Refs int64              `bson:"refs"`
+}
+
+// MongoBlobRepository counts the references to each blob by the hash of its content. The hash is unique across
+// the collection once its indexes are created.
+type MongoBlobRepository struct {
+	conn   *mongo.Collection
+	logger *zap.Logger
+}
+
+func NewMongoBlobRepository(db *mongo.Database, logger *zap.Logger) *MongoBlobRepository {
+	return &MongoBlobRepository{
+		conn:   db.Collection(MongoBlobRefCollectionName),
+		logger: logger,
+	}
+}
+
+// CreateIndexes makes sure there is a single reference count per hash, so concurrent upserts cannot split it in
+// two.
+func (repo *MongoBlobRepository) CreateIndexes(ctx context.Context) error {
+	return mongoCreateUniqueIndex(ctx, repo.conn, "hash")
+}
+
+func (repo *MongoBlobRepository) Acquire(ctx context.Context, hash string, key string) (string, error) {
+	update := bson.M{
+		"$inc":         bson.M{"refs": 1},
+		"$setOnInsert": bson.M{"key": key},
+	}
+
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var mref mongoBlobRef
+	if err := mongoUpsert(func() error {
+		return repo.conn.FindOneAndUpdate(ctx, bson.M{"hash": hash}, update, opts).Decode(&mref)
+	}); err != nil {
+		repo.logger.Error("performing find one and update on mongo",
+			zap.String("hash", hash),
+			zap.Error(err))
+
+		return "", fb.ErrUnknown
+	}
+
+	return mref.Key, nil
+}
+
+func (repo *MongoBlobRepository) Retain(ctx context.Context, key string) error {
+	result, err := repo.conn.UpdateOne(ctx, bson.M{"key": key}, bson.M{"$inc": bson.M{"refs": 1}})
+	if err != nil {
+		repo.logger.Error("performing update one on mongo",
+			zap.String("key", key),
+			zap.Error(err))
+
+		return fb.ErrUnknown
+	}
+
+	if result.MatchedCount == 0 {
+		return fb.ErrNotFound
+	}
+
+	return nil
+}
+
+func (repo *MongoBlobRepository) Release(ctx context.Context, key string) (int64, error) {
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var mref mongoBlobRef
+	err := repo.conn.FindOneAndUpdate(ctx, bson.M{"key": key}, bson.M{"$inc": bson.M{"refs": -1}}, opts).Decode(&mref)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return 0, fb.ErrNotFound
+	} else if err != nil {
+		repo.logger.Error("performing find one and update on mongo",
+			zap.String("key", key),
+			zap.Error(err))
+
+		return 0, fb.ErrUnknown
+	}
+
+	if mref.Refs > 0 {
+		return mref.Refs, nil
+	}
+
+	// the blob is no longer referenced, unless acquired in the meanwhile
+	result, err := repo.conn.DeleteOne(ctx, bson.M{"_id": mref.ID, "refs": bson.M{"$lte": 0}})
+	if err != nil {
+		repo.logger.Error("performing delete one on mongo",
+			zap.String("key", key),
+			zap.Error(err))
+
+		return 0, fb.ErrUnknown
+	}
+
+	if result.DeletedCount == 0 {
+		return 1, nil
+	}
+
+	return 0, nil
+}
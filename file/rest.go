@@ -0,0 +1,313 @@
+package file
+
+import (
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+
+	fb "github.com/alvidir/filebrowser"
+	"github.com/alvidir/filebrowser/proto"
+	"go.uber.org/zap"
+)
+
+const (
+	FilePathPrefix = "/files/"
+	contentSuffix  = "/content"
+)
+
+// FileRestService exposes the file application through plain HTTP, where:
+//
+//	POST   /files/             creates a file
+//	GET    /files/:id          returns the file
+//	PUT    /files/:id          updates the file
+//	DELETE /files/:id          moves the file into the trash
+//	GET    /files/:id/content  downloads the raw content of the file
+type FileRestService struct {
+	fileApp   *FileApplication
+	handler   *http.ServeMux
+	logger    *zap.Logger
+	uidHeader string
+}
+
+func NewFileRestServer(fileApp *FileApplication, logger *zap.Logger, authHeader string) *FileRestService {
+	server := &FileRestService{
+		fileApp:   fileApp,
+		handler:   http.NewServeMux(),
+		logger:    logger,
+		uidHeader: authHeader,
+	}
+
+	server.handler.HandleFunc(FilePathPrefix, server.fileHandler)
+	return server
+}
+
+func (server *FileRestService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	server.handler.ServeHTTP(w, r)
+}
+
+func (server *FileRestService) fileHandler(w http.ResponseWriter, r *http.Request) {
+	uid, err := fb.GetUidFromHttpRequest(r, server.uidHeader, server.logger)
+	if err != nil {
+		fb.HttpError(w, err)
+		return
+	}
+
+	fid := strings.TrimPrefix(r.URL.Path, FilePathPrefix)
+	if len(fid) == 0 {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		server.createHandler(w, r, uid)
+		return
+	}
+
+	content := strings.HasSuffix(fid, contentSuffix)
+	if content {
+		fid = strings.TrimSuffix(fid, contentSuffix)
+	}
+
+	if len(fid) == 0 || strings.Contains(fid, "/") {
+		http.NotFound(w, r)
+		return
+	}
+
+	if content {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		server.downloadHandler(w, r, uid, fid)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		server.getHandler(w, r, uid, fid)
+	case http.MethodPut:
+		server.updateHandler(w, r, uid, fid)
+	case http.MethodDelete:
+		server.deleteHandler(w, r, uid, fid)
+	default:
+		w.Header().Set("Allow", strings.Join([]string{http.MethodGet, http.MethodPut, http.MethodDelete}, ", "))
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+func (server *FileRestService) createHandler(w http.ResponseWriter, r *http.Request, uid int32) {
+	var req proto.File
+	if err := fb.ReadJson(r, &req); err != nil {
+		fb.HttpError(w, err)
+		return
+	}
+
+	options := CreateOptions{
+		Name:      req.GetName(),
+		Directory: req.GetDirectory(),
+		Meta:      make(Metadata),
+		Data:      req.GetData(),
+	}
+
+	for _, meta := range req.GetMetadata() {
+		options.Meta[meta.GetKey()] = meta.GetValue()
+	}
+
+	file, err := server.fileApp.Create(r.Context(), uid, &options)
+	if err != nil {
+		fb.HttpError(w, err)
+		return
+	}
+
+	fb.WriteJson(w, http.StatusCreated, NewProtoFile(file), server.logger)
+}
+
+func (server *FileRestService) getHandler(w http.ResponseWriter, r *http.Request, uid int32, fid string) {
+	var options GetOptions
+	if name := r.URL.Query().Get("view"); len(name) > 0 {
+		view, exists := proto.FileView_value[name]
+		if !exists {
+			fb.HttpError(w, fb.ErrInvalidFormat)
+			return
+		}
+
+		options.View, _ = NewView(proto.FileView(view))
+	}
+
+	file, err := server.fileApp.Get(r.Context(), uid, fid, &options)
+	if err != nil {
+		fb.HttpError(w, err)
+		return
+	}
+
+	fb.WriteJson(w, http.StatusOK, NewProtoFile(file), server.logger)
+}
+
+func (server *FileRestService) updateHandler(w http.ResponseWriter, r *http.Request, uid int32, fid string) {
+	var req proto.File
+	if err := fb.ReadJson(r, &req); err != nil {
+		fb.HttpError(w, err)
+		return
+	}
+
+	options, err := NewUpdateOptions(&req)
+	if err != nil {
+		fb.HttpError(w, err)
+		return
+	}
+
+	file, err := server.fileApp.Update(r.Context(), uid, fid, options)
+	if err != nil {
+		fb.HttpError(w, err)
+		return
+	}
+
+	fb.WriteJson(w, http.StatusOK, NewProtoFile(file), server.logger)
+}
+
+func (server *FileRestService) deleteHandler(w http.ResponseWriter, r *http.Request, uid int32, fid string) {
+	file, err := server.fileApp.Delete(r.Context(), uid, fid)
+	if err != nil {
+		fb.HttpError(w, err)
+		return
+	}
+
+	fb.WriteJson(w, http.StatusOK, NewProtoFile(file), server.logger)
+}
+
+func (server *FileRestService) downloadHandler(w http.ResponseWriter, r *http.Request, uid int32, fid string) {
+	file, err := server.fileApp.Get(r.Context(), uid, fid, &GetOptions{View: BasicView})
+	if err != nil {
+		fb.HttpError(w, err)
+		return
+	}
+
+	if contentType, exists := file.Value(MetadataContentTypeKey); exists {
+		w.Header().Set("Content-Type", contentType)
+	} else if contentType := mime.TypeByExtension(path.Ext(file.Name())); len(contentType) > 0 {
+		// the content has been written before its type was sniffed
+		w.Header().Set("Content-Type", contentType)
+	}
+
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": file.Name(),
+	}))
+
+	// headers are sent along with the first bytes of content, so any error before them can still be replied
+	body := &countingWriter{Writer: w}
+	if _, err := server.fileApp.Download(r.Context(), uid, fid, body); err != nil && body.count == 0 {
+		w.Header().Del("Content-Disposition")
+		fb.HttpError(w, err)
+	} else if err != nil {
+		server.logger.Error("writing http response",
+			zap.String("file_id", fid),
+			zap.Error(err))
+	}
+}
+
+// RegisterRoutes describes into the given registry all the routes served by the service.
+func (server *FileRestService) RegisterRoutes(registry *fb.RestRegistry) {
+	registry.AddSchema("Metadata", &fb.Schema{
+		Type:        "object",
+		Description: "Merged into the metadata of the file, where an empty value removes its key. Server and application managed keys are ignored",
+		Properties: map[string]*fb.Schema{
+			"key":   {Type: "string"},
+			"value": {Type: "string"},
+		},
+	})
+
+	registry.AddSchema("Permissions", &fb.Schema{
+		Type: "object",
+		Properties: map[string]*fb.Schema{
+			"userId": {Type: "integer", Format: "int32"},
+			"read":   {Type: "boolean"},
+			"write":  {Type: "boolean"},
+			"owner":  {Type: "boolean"},
+		},
+	})
+
+	registry.AddSchema("GroupPermissions", &fb.Schema{
+		Type: "object",
+		Properties: map[string]*fb.Schema{
+			"groupId": {Type: "string"},
+			"read":    {Type: "boolean"},
+			"write":   {Type: "boolean"},
+		},
+	})
+
+	registry.AddSchema("File", &fb.Schema{
+		Type: "object",
+		Properties: map[string]*fb.Schema{
+			"id":          {Type: "string"},
+			"name":        {Type: "string"},
+			"directory":   {Type: "string"},
+			"metadata":    {Type: "array", Items: fb.SchemaRef("Metadata")},
+			"permissions": {Type: "array", Items: fb.SchemaRef("Permissions")},
+			"flags":       {Type: "integer", Format: "int32"},
+			"data":        {Type: "string", Format: "byte", Description: "The base64 encoded content of the file"},
+			"revision":    {Type: "string", Format: "int64"},
+			"groups":      {Type: "array", Items: fb.SchemaRef("GroupPermissions")},
+			"updateMask":  {Type: "string", Description: "The comma separated fields to update, all those not empty by default"},
+		},
+	})
+
+	fileId := fb.Parameter{Name: "id", In: "path", Required: true, Schema: &fb.Schema{Type: "string"}}
+	fileView := fb.Parameter{
+		Name:        "view",
+		In:          "query",
+		Description: "The parts of the file to return, all of them by default",
+		Schema:      &fb.Schema{Type: "string", Enum: []string{"BASIC", "FULL", "DATA_ONLY"}},
+	}
+
+	file := fb.Response{Description: "The file", ContentType: fb.JsonContentType, Schema: fb.SchemaRef("File")}
+
+	registry.Register(
+		fb.Route{
+			Method:    http.MethodPost,
+			Path:      FilePathPrefix,
+			Summary:   "Creates a file",
+			Request:   fb.SchemaRef("File"),
+			Responses: map[int]fb.Response{http.StatusCreated: file},
+			Errors:    []error{fb.ErrAlreadyExists, fb.ErrInvalidFormat, fb.ErrQuotaExceeded},
+		},
+		fb.Route{
+			Method:     http.MethodGet,
+			Path:       FilePathPrefix + "{id}",
+			Summary:    "Returns the file with the given id",
+			Parameters: []fb.Parameter{fileId, fileView},
+			Responses:  map[int]fb.Response{http.StatusOK: file},
+			Errors:     []error{fb.ErrNotFound, fb.ErrNotAvailable, fb.ErrInvalidFormat},
+		},
+		fb.Route{
+			Method:     http.MethodPut,
+			Path:       FilePathPrefix + "{id}",
+			Summary:    "Updates the file with the given id",
+			Parameters: []fb.Parameter{fileId},
+			Request:    fb.SchemaRef("File"),
+			Responses:  map[int]fb.Response{http.StatusOK: file},
+			Errors:     []error{fb.ErrNotFound, fb.ErrNotAvailable, fb.ErrConflict, fb.ErrQuotaExceeded},
+		},
+		fb.Route{
+			Method:     http.MethodDelete,
+			Path:       FilePathPrefix + "{id}",
+			Summary:    "Moves the file with the given id into the trash",
+			Parameters: []fb.Parameter{fileId},
+			Responses:  map[int]fb.Response{http.StatusOK: file},
+			Errors:     []error{fb.ErrNotFound, fb.ErrNotAvailable},
+		},
+		fb.Route{
+			Method:     http.MethodGet,
+			Path:       FilePathPrefix + "{id}" + contentSuffix,
+			Summary:    "Downloads the raw content of the file with the given id",
+			Parameters: []fb.Parameter{fileId},
+			Responses: map[int]fb.Response{
+				http.StatusOK: {Description: "The content of the file", ContentType: fb.BinaryContentType, Schema: &fb.Schema{Type: "string", Format: "binary"}},
+			},
+			Errors: []error{fb.ErrNotFound, fb.ErrNotAvailable},
+		},
+	)
+}
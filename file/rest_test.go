@@ -0,0 +1,186 @@
+package file
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alvidir/filebrowser/proto"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+const testUidHeader = "X-Uid"
+
+func newTestFileRestServer(logger *zap.Logger) *FileRestService {
+	repo := &fileRepositoryMock{
+		find: func(repo *fileRepositoryMock, ctx context.Context, id string) (*File, error) {
+			return &File{
+				id:          id,
+				name:        "notes.txt",
+				metadata:    make(Metadata),
+				permissions: map[int32]Permission{111: Owner},
+			}, nil
+		},
+		save: func(repo *fileRepositoryMock, ctx context.Context, file *File) error {
+			return nil
+		},
+	}
+
+	blobs := &blobStoreMock{
+		get: func(ctx context.Context, key string, w io.Writer) error {
+			_, err := w.Write([]byte("hello world"))
+			return err
+		},
+	}
+
+	app := NewFileApplication(repo, newContentStoreMock(blobs, logger), &directoryApplicationMock{}, &groupApplicationMock{}, &EventBusMock{}, logger)
+	return NewFileRestServer(app, logger, testUidHeader)
+}
+
+func TestFileRestGet(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	server := newTestFileRestServer(logger)
+
+	req := httptest.NewRequest(http.MethodGet, FilePathPrefix+"123", nil)
+	req.Header.Set(testUidHeader, "111")
+
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status = %v, want = %v", rec.Code, http.StatusOK)
+	}
+
+	var got proto.File
+	if err := protojson.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("got error = %v, want = %v", err, nil)
+	}
+
+	if got.GetId() != "123" || string(got.GetData()) != "hello world" {
+		t.Errorf("got file = %v, want id = %v", &got, "123")
+	}
+
+	req = httptest.NewRequest(http.MethodGet, FilePathPrefix+"123?view=BASIC", nil)
+	req.Header.Set(testUidHeader, "111")
+
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status = %v, want = %v", rec.Code, http.StatusOK)
+	}
+
+	got.Reset()
+	if err := protojson.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("got error = %v, want = %v", err, nil)
+	}
+
+	if got.GetName() != "notes.txt" || len(got.GetData()) > 0 {
+		t.Errorf("got file = %v, want name = %v and no data", &got, "notes.txt")
+	}
+
+	req = httptest.NewRequest(http.MethodGet, FilePathPrefix+"123?view=NONE", nil)
+	req.Header.Set(testUidHeader, "111")
+
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("got status = %v, want = %v", rec.Code, http.StatusBadRequest)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, FilePathPrefix+"123", nil)
+	req.Header.Set(testUidHeader, "222")
+
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("got status = %v, want = %v", rec.Code, http.StatusForbidden)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, FilePathPrefix+"123", nil)
+
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("got status = %v, want = %v", rec.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestFileRestDownload(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	server := newTestFileRestServer(logger)
+
+	req := httptest.NewRequest(http.MethodGet, FilePathPrefix+"123"+contentSuffix, nil)
+	req.Header.Set(testUidHeader, "111")
+
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status = %v, want = %v", rec.Code, http.StatusOK)
+	}
+
+	if got := rec.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/plain") {
+		t.Errorf("got content type = %v, want = %v", got, "text/plain")
+	}
+
+	if got := rec.Body.String(); got != "hello world" {
+		t.Errorf("got body = %v, want = %v", got, "hello world")
+	}
+
+	req = httptest.NewRequest(http.MethodPost, FilePathPrefix+"123"+contentSuffix, nil)
+	req.Header.Set(testUidHeader, "111")
+
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("got status = %v, want = %v", rec.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestFileRestUpdateWithMask(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	server := newTestFileRestServer(logger)
+
+	body := `{"name": "renamed.txt", "metadata": [{"key": "color", "value": "red"}], "updateMask": "name"}`
+	req := httptest.NewRequest(http.MethodPut, FilePathPrefix+"123", strings.NewReader(body))
+	req.Header.Set(testUidHeader, "111")
+
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status = %v, want = %v", rec.Code, http.StatusOK)
+	}
+
+	var got proto.File
+	if err := protojson.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("got error = %v, want = %v", err, nil)
+	}
+
+	if got.GetName() != "renamed.txt" {
+		t.Errorf("got name = %v, want = %v", got.GetName(), "renamed.txt")
+	}
+
+	for _, meta := range got.GetMetadata() {
+		if meta.GetKey() == "color" {
+			t.Errorf("got metadata key = %v, want = %v", meta.GetKey(), nil)
+		}
+	}
+
+	body = `{"updateMask": "name"}`
+	req = httptest.NewRequest(http.MethodPut, FilePathPrefix+"123", strings.NewReader(body))
+	req.Header.Set(testUidHeader, "111")
+
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("got status = %v, want = %v", rec.Code, http.StatusBadRequest)
+	}
+}
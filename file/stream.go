@@ -0,0 +1,90 @@
+package file
+
+import "io"
+
+const (
+	ChunkSize = 64 * 1024 // 64 KiB
+)
+
+// chunkReader is an io.Reader over a sequence of chunks, each of them provided by the recv function. The
+// recv function must return io.EOF once there are no more chunks to read.
+type chunkReader struct {
+	recv func() ([]byte, error)
+	buf  []byte
+}
+
+func newChunkReader(recv func() ([]byte, error)) *chunkReader {
+	return &chunkReader{
+		recv: recv,
+	}
+}
+
+func (reader *chunkReader) Read(p []byte) (int, error) {
+	for len(reader.buf) == 0 {
+		chunk, err := reader.recv()
+		if err != nil {
+			return 0, err
+		}
+
+		reader.buf = chunk
+	}
+
+	n := copy(p, reader.buf)
+	reader.buf = reader.buf[n:]
+	return n, nil
+}
+
+// chunkWriter is an io.Writer that splits any written content into chunks no larger than ChunkSize, each of
+// them delivered to the send function.
+type chunkWriter struct {
+	send func([]byte) error
+}
+
+func newChunkWriter(send func([]byte) error) *chunkWriter {
+	return &chunkWriter{
+		send: send,
+	}
+}
+
+func (writer *chunkWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		size := len(p)
+		if size > ChunkSize {
+			size = ChunkSize
+		}
+
+		if err := writer.send(p[:size]); err != nil {
+			return written, err
+		}
+
+		written += size
+		p = p[size:]
+	}
+
+	return written, nil
+}
+
+// countingReader is an io.Reader that keeps track of how many bytes have been read from the underlying one.
+type countingReader struct {
+	io.Reader
+	count int64
+}
+
+func (reader *countingReader) Read(p []byte) (int, error) {
+	n, err := reader.Reader.Read(p)
+	reader.count += int64(n)
+	return n, err
+}
+
+// countingWriter is an io.Writer that keeps track of how many bytes have been written into the underlying one.
+type countingWriter struct {
+	io.Writer
+	count int64
+}
+
+func (writer *countingWriter) Write(p []byte) (int, error) {
+	n, err := writer.Writer.Write(p)
+	writer.count += int64(n)
+	return n, err
+}
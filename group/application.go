@@ -0,0 +1,168 @@
+package group
+
+import (
+	"context"
+	"errors"
+
+	fb "github.com/alvidir/filebrowser"
+	"go.uber.org/zap"
+)
+
+type GroupRepository interface {
+	Create(ctx context.Context, group *Group) error
+	Find(ctx context.Context, id string) (*Group, error)
+	FindByMember(ctx context.Context, uid int32) ([]*Group, error)
+	Save(ctx context.Context, group *Group) error
+	Delete(ctx context.Context, group *Group) error
+}
+
+type GroupApplication struct {
+	groupRepo GroupRepository
+	logger    *zap.Logger
+}
+
+func NewGroupApplication(groupRepo GroupRepository, logger *zap.Logger) *GroupApplication {
+	return &GroupApplication{
+		groupRepo: groupRepo,
+		logger:    logger,
+	}
+}
+
+// Create creates a brand new group with the given name, owned by the user uid.
+func (app *GroupApplication) Create(ctx context.Context, uid int32, name string) (*Group, error) {
+	app.logger.Info("processing a \"create\" group request",
+		zap.String("name", name),
+		zap.Int32("user_id", uid))
+
+	group, err := NewGroup(name, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := app.groupRepo.Create(ctx, group); err != nil {
+		return nil, err
+	}
+
+	return group, nil
+}
+
+// Get returns the group with the given id, if, and only if, the user uid is member of it.
+func (app *GroupApplication) Get(ctx context.Context, uid int32, gid string) (*Group, error) {
+	app.logger.Info("processing a \"get\" group request",
+		zap.String("group_id", gid),
+		zap.Int32("user_id", uid))
+
+	group, err := app.groupRepo.Find(ctx, gid)
+	if err != nil {
+		return nil, err
+	}
+
+	if !group.IsMember(uid) {
+		return nil, fb.ErrNotAvailable
+	}
+
+	return group, nil
+}
+
+// Delete removes the group with the given id. Only the owner of a group can delete it.
+func (app *GroupApplication) Delete(ctx context.Context, uid int32, gid string) (*Group, error) {
+	app.logger.Info("processing a \"delete\" group request",
+		zap.String("group_id", gid),
+		zap.Int32("user_id", uid))
+
+	group, err := app.groupRepo.Find(ctx, gid)
+	if err != nil {
+		return nil, err
+	}
+
+	if group.owner != uid {
+		return nil, fb.ErrNotAvailable
+	}
+
+	if err := app.groupRepo.Delete(ctx, group); err != nil {
+		return nil, err
+	}
+
+	return group, nil
+}
+
+// AddMember adds the user member into the group with the given id. Only the owner of a group can add members.
+func (app *GroupApplication) AddMember(ctx context.Context, uid int32, gid string, member int32) (*Group, error) {
+	app.logger.Info("processing an \"add member\" group request",
+		zap.String("group_id", gid),
+		zap.Int32("user_id", uid),
+		zap.Int32("member", member))
+
+	group, err := app.groupRepo.Find(ctx, gid)
+	if err != nil {
+		return nil, err
+	}
+
+	if group.owner != uid {
+		return nil, fb.ErrNotAvailable
+	}
+
+	if !group.AddMember(member) {
+		return nil, fb.ErrAlreadyExists
+	}
+
+	if err := app.groupRepo.Save(ctx, group); err != nil {
+		return nil, err
+	}
+
+	return group, nil
+}
+
+// RemoveMember removes the user member from the group with the given id. Only the owner of a group can remove
+// any member, while the rest of them can only leave it. The owner itself cannot leave its own group.
+func (app *GroupApplication) RemoveMember(ctx context.Context, uid int32, gid string, member int32) (*Group, error) {
+	app.logger.Info("processing a \"remove member\" group request",
+		zap.String("group_id", gid),
+		zap.Int32("user_id", uid),
+		zap.Int32("member", member))
+
+	group, err := app.groupRepo.Find(ctx, gid)
+	if err != nil {
+		return nil, err
+	}
+
+	if group.owner != uid && member != uid || member == group.owner {
+		return nil, fb.ErrNotAvailable
+	}
+
+	if !group.RemoveMember(member) {
+		return nil, fb.ErrNotFound
+	}
+
+	if err := app.groupRepo.Save(ctx, group); err != nil {
+		return nil, err
+	}
+
+	return group, nil
+}
+
+// MemberOf returns the ids of all those groups the user uid is member of.
+func (app *GroupApplication) MemberOf(ctx context.Context, uid int32) ([]string, error) {
+	groups, err := app.groupRepo.FindByMember(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	gids := make([]string, len(groups))
+	for index, group := range groups {
+		gids[index] = group.id
+	}
+
+	return gids, nil
+}
+
+// Exists returns true if, and only if, there is a group with the given id.
+func (app *GroupApplication) Exists(ctx context.Context, gid string) (bool, error) {
+	if _, err := app.groupRepo.Find(ctx, gid); errors.Is(err, fb.ErrNotFound) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
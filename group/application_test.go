@@ -0,0 +1,121 @@
+package group
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	fb "github.com/alvidir/filebrowser"
+	"go.uber.org/zap"
+)
+
+type groupRepositoryMock struct {
+	find func(ctx context.Context, id string) (*Group, error)
+	save func(ctx context.Context, group *Group) error
+}
+
+func (mock *groupRepositoryMock) Create(ctx context.Context, group *Group) error {
+	return fb.ErrUnknown
+}
+
+func (mock *groupRepositoryMock) Find(ctx context.Context, id string) (*Group, error) {
+	if mock.find != nil {
+		return mock.find(ctx, id)
+	}
+
+	return nil, fb.ErrNotFound
+}
+
+func (mock *groupRepositoryMock) FindByMember(ctx context.Context, uid int32) ([]*Group, error) {
+	return nil, fb.ErrUnknown
+}
+
+func (mock *groupRepositoryMock) Save(ctx context.Context, group *Group) error {
+	if mock.save != nil {
+		return mock.save(ctx, group)
+	}
+
+	return fb.ErrUnknown
+}
+
+func (mock *groupRepositoryMock) Delete(ctx context.Context, group *Group) error {
+	return fb.ErrUnknown
+}
+
+func TestAddMember(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	group, _ := NewGroup("team", 111)
+	repo := &groupRepositoryMock{
+		find: func(ctx context.Context, id string) (*Group, error) {
+			return group, nil
+		},
+		save: func(ctx context.Context, group *Group) error {
+			return nil
+		},
+	}
+
+	app := NewGroupApplication(repo, logger)
+
+	if _, err := app.AddMember(context.Background(), 222, "abc", 333); !errors.Is(err, fb.ErrNotAvailable) {
+		t.Errorf("got error = %v, want = %v", err, fb.ErrNotAvailable)
+	}
+
+	if _, err := app.AddMember(context.Background(), 111, "abc", 222); err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+	}
+
+	if _, err := app.AddMember(context.Background(), 111, "abc", 222); !errors.Is(err, fb.ErrAlreadyExists) {
+		t.Errorf("got error = %v, want = %v", err, fb.ErrAlreadyExists)
+	}
+
+	if !group.IsMember(222) {
+		t.Errorf("got is member = %v, want = %v", false, true)
+	}
+}
+
+func TestRemoveMember(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	group, _ := NewGroup("team", 111)
+	group.AddMember(222)
+	group.AddMember(333)
+
+	repo := &groupRepositoryMock{
+		find: func(ctx context.Context, id string) (*Group, error) {
+			return group, nil
+		},
+		save: func(ctx context.Context, group *Group) error {
+			return nil
+		},
+	}
+
+	app := NewGroupApplication(repo, logger)
+
+	tests := []struct {
+		name   string
+		uid    int32
+		member int32
+		err    error
+	}{
+		{name: "member removing another one", uid: 222, member: 333, err: fb.ErrNotAvailable},
+		{name: "owner leaving its own group", uid: 111, member: 111, err: fb.ErrNotAvailable},
+		{name: "member leaving the group", uid: 222, member: 222, err: nil},
+		{name: "owner removing a member", uid: 111, member: 333, err: nil},
+		{name: "owner removing a non member", uid: 111, member: 444, err: fb.ErrNotFound},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := app.RemoveMember(context.Background(), test.uid, "abc", test.member); !errors.Is(err, test.err) {
+				t.Errorf("got error = %v, want = %v", err, test.err)
+			}
+		})
+	}
+
+	if got := group.Members(); len(got) != 1 || got[0] != 111 {
+		t.Errorf("got members = %v, want = %v", got, []int32{111})
+	}
+}
@@ -0,0 +1,76 @@
+package group
+
+import (
+	"strings"
+
+	fb "github.com/alvidir/filebrowser"
+)
+
+// Group is a named set of users permissions can be granted to as a whole.
+type Group struct {
+	id      string
+	name    string
+	owner   int32
+	members []int32
+}
+
+// NewGroup returns a brand new group with the given name, having the user owner as its single member.
+func NewGroup(name string, owner int32) (*Group, error) {
+	if len(strings.TrimSpace(name)) == 0 {
+		return nil, fb.ErrInvalidFormat
+	}
+
+	return &Group{
+		name:    name,
+		owner:   owner,
+		members: []int32{owner},
+	}, nil
+}
+
+func (group *Group) Id() string {
+	return group.id
+}
+
+func (group *Group) Name() string {
+	return group.name
+}
+
+func (group *Group) Owner() int32 {
+	return group.owner
+}
+
+func (group *Group) Members() []int32 {
+	return group.members
+}
+
+func (group *Group) IsMember(uid int32) bool {
+	for _, member := range group.members {
+		if member == uid {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AddMember adds the given user into the group, returning false if it was already a member.
+func (group *Group) AddMember(uid int32) bool {
+	if group.IsMember(uid) {
+		return false
+	}
+
+	group.members = append(group.members, uid)
+	return true
+}
+
+// RemoveMember removes the given user from the group, returning false if it was not a member.
+func (group *Group) RemoveMember(uid int32) bool {
+	for index, member := range group.members {
+		if member == uid {
+			group.members = append(group.members[:index], group.members[index+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,103 @@
+package group
+
+import (
+	"context"
+
+	fb "github.com/alvidir/filebrowser"
+	"github.com/alvidir/filebrowser/proto"
+	"go.uber.org/zap"
+)
+
+type GroupGrpcService struct {
+	proto.UnimplementedGroupServiceServer
+	groupApp  *GroupApplication
+	logger    *zap.Logger
+	uidHeader string
+}
+
+func NewGroupGrpcServer(groupApp *GroupApplication, authHeader string, logger *zap.Logger) *GroupGrpcService {
+	return &GroupGrpcService{
+		groupApp:  groupApp,
+		logger:    logger,
+		uidHeader: authHeader,
+	}
+}
+
+func NewProtoGroup(group *Group) *proto.Group {
+	return &proto.Group{
+		Id:      group.id,
+		Name:    group.name,
+		Owner:   group.owner,
+		Members: group.members,
+	}
+}
+
+func (server *GroupGrpcService) Create(ctx context.Context, req *proto.Group) (*proto.Group, error) {
+	uid, err := fb.GetUidFromGrpcCtx(ctx, server.uidHeader, server.logger)
+	if err != nil {
+		return nil, err
+	}
+
+	group, err := server.groupApp.Create(ctx, uid, req.GetName())
+	if err != nil {
+		return nil, err
+	}
+
+	return NewProtoGroup(group), nil
+}
+
+func (server *GroupGrpcService) Get(ctx context.Context, req *proto.Group) (*proto.Group, error) {
+	uid, err := fb.GetUidFromGrpcCtx(ctx, server.uidHeader, server.logger)
+	if err != nil {
+		return nil, err
+	}
+
+	group, err := server.groupApp.Get(ctx, uid, req.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	return NewProtoGroup(group), nil
+}
+
+func (server *GroupGrpcService) Delete(ctx context.Context, req *proto.Group) (*proto.Group, error) {
+	uid, err := fb.GetUidFromGrpcCtx(ctx, server.uidHeader, server.logger)
+	if err != nil {
+		return nil, err
+	}
+
+	group, err := server.groupApp.Delete(ctx, uid, req.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	return NewProtoGroup(group), nil
+}
+
+func (server *GroupGrpcService) AddMember(ctx context.Context, req *proto.MemberRequest) (*proto.Group, error) {
+	uid, err := fb.GetUidFromGrpcCtx(ctx, server.uidHeader, server.logger)
+	if err != nil {
+		return nil, err
+	}
+
+	group, err := server.groupApp.AddMember(ctx, uid, req.GetGroupId(), req.GetUserId())
+	if err != nil {
+		return nil, err
+	}
+
+	return NewProtoGroup(group), nil
+}
+
+func (server *GroupGrpcService) RemoveMember(ctx context.Context, req *proto.MemberRequest) (*proto.Group, error) {
+	uid, err := fb.GetUidFromGrpcCtx(ctx, server.uidHeader, server.logger)
+	if err != nil {
+		return nil, err
+	}
+
+	group, err := server.groupApp.RemoveMember(ctx, uid, req.GetGroupId(), req.GetUserId())
+	if err != nil {
+		return nil, err
+	}
+
+	return NewProtoGroup(group), nil
+}
@@ -0,0 +1,197 @@
+package group
+
+import (
+	"context"
+	"errors"
+
+	fb "github.com/alvidir/filebrowser"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+)
+
+const (
+	MongoGroupCollectionName = "groups"
+)
+
+type mongoGroup struct {
+	ID      primitive.ObjectID `bson:"_id,omitempty"`
+	Name    string             `bson:"name"`
+	Owner   int32              `bson:"owner"`
+	Members []int32            `bson:"members"`
+}
+
+func newMongoGroup(group *Group) (*mongoGroup, error) {
+	oid := primitive.NilObjectID
+
+	if len(group.id) > 0 {
+		var err error
+		if oid, err = primitive.ObjectIDFromHex(group.id); err != nil {
+			return nil, err
+		}
+	}
+
+	return &mongoGroup{
+		ID:      oid,
+		Name:    group.name,
+		Owner:   group.owner,
+		Members: group.members,
+	}, nil
+}
+
+type MongoGroupRepository struct {
+	conn   *mongo.Collection
+	logger *zap.Logger
+}
+
+func NewMongoGroupRepository(db *mongo.Database, logger *zap.Logger) *MongoGroupRepository {
+	return &MongoGroupRepository{
+		conn:   db.Collection(MongoGroupCollectionName),
+		logger: logger,
+	}
+}
+
+func (repo *MongoGroupRepository) Create(ctx context.Context, group *Group) error {
+	mgroup, err := newMongoGroup(group)
+	if err != nil {
+		repo.logger.Error("building mongo group",
+			zap.String("group_name", group.name),
+			zap.Error(err))
+
+		return fb.ErrUnknown
+	}
+
+	res, err := repo.conn.InsertOne(ctx, mgroup)
+	if err != nil {
+		repo.logger.Error("performing insert one on mongo",
+			zap.String("group_name", group.name),
+			zap.Error(err))
+
+		return fb.ErrUnknown
+	}
+
+	if groupId, ok := res.InsertedID.(primitive.ObjectID); ok {
+		group.id = groupId.Hex()
+		return nil
+	}
+
+	repo.logger.Error("performing insert one on mongo",
+		zap.String("group_name", group.name),
+		zap.Error(err))
+
+	return fb.ErrUnknown
+}
+
+func (repo *MongoGroupRepository) Find(ctx context.Context, id string) (*Group, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		repo.logger.Warn("parsing group id to ObjectID",
+			zap.String("group_id", id),
+			zap.Error(err))
+
+		return nil, fb.ErrNotFound
+	}
+
+	var mgroup mongoGroup
+	err = repo.conn.FindOne(ctx, bson.M{"_id": objID}).Decode(&mgroup)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, fb.ErrNotFound
+	} else if err != nil {
+		repo.logger.Error("performing find one on mongo",
+			zap.String("group_id", id),
+			zap.Error(err))
+
+		return nil, fb.ErrUnknown
+	}
+
+	return repo.build(&mgroup), nil
+}
+
+// FindByMember returns all those groups the given user is member of.
+func (repo *MongoGroupRepository) FindByMember(ctx context.Context, uid int32) ([]*Group, error) {
+	cursor, err := repo.conn.Find(ctx, bson.M{"members": uid})
+	if err != nil {
+		repo.logger.Error("performing find on mongo",
+			zap.Int32("user_id", uid),
+			zap.Error(err))
+
+		return nil, fb.ErrUnknown
+	}
+
+	var mgroups []mongoGroup
+	if err := cursor.All(ctx, &mgroups); err != nil {
+		repo.logger.Error("decoding found items",
+			zap.Error(err))
+
+		return nil, fb.ErrUnknown
+	}
+
+	groups := make([]*Group, len(mgroups))
+	for index, mgroup := range mgroups {
+		groups[index] = repo.build(&mgroup)
+	}
+
+	return groups, nil
+}
+
+func (repo *MongoGroupRepository) Save(ctx context.Context, group *Group) error {
+	mgroup, err := newMongoGroup(group)
+	if err != nil {
+		repo.logger.Error("building mongo group",
+			zap.String("group_id", group.id),
+			zap.Error(err))
+
+		return fb.ErrUnknown
+	}
+
+	result, err := repo.conn.ReplaceOne(ctx, bson.M{"_id": mgroup.ID}, mgroup)
+	if err != nil {
+		repo.logger.Error("performing replace one on mongo",
+			zap.String("group_id", group.id),
+			zap.Error(err))
+
+		return fb.ErrUnknown
+	}
+
+	if result.MatchedCount == 0 {
+		return fb.ErrNotFound
+	}
+
+	return nil
+}
+
+func (repo *MongoGroupRepository) Delete(ctx context.Context, group *Group) error {
+	objID, err := primitive.ObjectIDFromHex(group.id)
+	if err != nil {
+		repo.logger.Error("parsing group id to ObjectID",
+			zap.String("group_id", group.id),
+			zap.Error(err))
+
+		return fb.ErrUnknown
+	}
+
+	result, err := repo.conn.DeleteOne(ctx, bson.M{"_id": objID})
+	if err != nil {
+		repo.logger.Error("performing delete one on mongo",
+			zap.String("group_id", group.id),
+			zap.Error(err))
+
+		return fb.ErrUnknown
+	}
+
+	if result.DeletedCount == 0 {
+		return fb.ErrNotFound
+	}
+
+	return nil
+}
+
+func (repo *MongoGroupRepository) build(mgroup *mongoGroup) *Group {
+	return &Group{
+		id:      mgroup.ID.Hex(),
+		name:    mgroup.Name,
+		owner:   mgroup.Owner,
+		members: mgroup.Members,
+	}
+}
@@ -17,8 +17,14 @@ func intoInt32(s string) (int32, error) {
 	}
 }
 
+// GetUidFromGrpcCtx returns the id of the user the given context has been authenticated for, if any. Otherwise
+// the user id is taken from the given header, unless empty.
 func GetUidFromGrpcCtx(ctx context.Context, header string, logger *zap.Logger) (int32, error) {
-	if meta, exists := metadata.FromIncomingContext(ctx); !exists {
+	if uid, exists := UidFromContext(ctx); exists {
+		return uid, nil
+	} else if len(header) == 0 {
+		return 0, ErrUnauthorized
+	} else if meta, exists := metadata.FromIncomingContext(ctx); !exists {
 		return 0, ErrUnauthorized
 	} else if values := meta.Get(header); len(values) == 0 {
 		return 0, ErrUnauthorized
@@ -34,8 +40,14 @@ func GetUidFromGrpcCtx(ctx context.Context, header string, logger *zap.Logger) (
 	}
 }
 
+// GetUidFromHttpRequest returns the id of the user the given request has been authenticated for, if any.
+// Otherwise the user id is taken from the given header, unless empty.
 func GetUidFromHttpRequest(r *http.Request, header string, logger *zap.Logger) (int32, error) {
-	if values, exits := r.Header[header]; !exits || len(values) == 0 {
+	if uid, exists := UidFromContext(r.Context()); exists {
+		return uid, nil
+	} else if len(header) == 0 {
+		return 0, ErrUnauthorized
+	} else if values, exits := r.Header[header]; !exits || len(values) == 0 {
 		return 0, ErrUnauthorized
 	} else if uid, err := intoInt32(values[0]); err != nil {
 		logger.Warn("parsing header data into int32",
@@ -0,0 +1,170 @@
+package link
+
+import (
+	"context"
+	"io"
+	"time"
+
+	fb "github.com/alvidir/filebrowser"
+	"github.com/alvidir/filebrowser/file"
+	"go.uber.org/zap"
+)
+
+type LinkRepository interface {
+	Create(ctx context.Context, link *Link) error
+	Find(ctx context.Context, id string) (*Link, error)
+	AddDownload(ctx context.Context, link *Link) error
+	Delete(ctx context.Context, link *Link) error
+}
+
+type LinkApplication struct {
+	linkRepo LinkRepository
+	fileRepo file.FileRepository
+	content  *file.ContentStore
+	logger   *zap.Logger
+}
+
+func NewLinkApplication(linkRepo LinkRepository, fileRepo file.FileRepository, content *file.ContentStore, logger *zap.Logger) *LinkApplication {
+	return &LinkApplication{
+		linkRepo: linkRepo,
+		fileRepo: fileRepo,
+		content:  content,
+		logger:   logger,
+	}
+}
+
+type CreateOptions struct {
+	FileId       string
+	Permission   file.Permission
+	ExpiresAt    time.Time
+	MaxDownloads int32
+	Password     string
+}
+
+// Create mints a brand new link over the file described by the given options, returning the token that
+// resolves it. Only owners can create links, which never grant more than their author's own permission.
+func (app *LinkApplication) Create(ctx context.Context, uid int32, options *CreateOptions) (*Link, string, error) {
+	app.logger.Info("processing a \"create\" link request",
+		zap.String("file_id", options.FileId),
+		zap.Int32("user_id", uid))
+
+	if options.Permission == 0 {
+		options.Permission = file.Read
+	}
+
+	if options.MaxDownloads < 0 || !options.ExpiresAt.IsZero() && options.ExpiresAt.Before(time.Now()) {
+		return nil, "", fb.ErrInvalidFormat
+	}
+
+	if options.Permission&file.Read == 0 {
+		// a link that cannot be read could never be resolved
+		return nil, "", fb.ErrInvalidFormat
+	}
+
+	f, err := app.fileRepo.Find(ctx, options.FileId, nil)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if f.Permission(uid)&file.Owner == 0 {
+		return nil, "", fb.ErrNotAvailable
+	}
+
+	link, token, err := NewLink(f.Id(), uid, options.Permission&(file.Read|file.Write))
+	if err != nil {
+		app.logger.Error("generating link token",
+			zap.String("file_id", options.FileId),
+			zap.Error(err))
+
+		return nil, "", fb.ErrUnknown
+	}
+
+	link.SetExpiration(options.ExpiresAt)
+	link.SetMaxDownloads(options.MaxDownloads)
+	if err := link.SetPassword(options.Password); err != nil {
+		app.logger.Error("hashing link password",
+			zap.String("file_id", options.FileId),
+			zap.Error(err))
+
+		return nil, "", fb.ErrUnknown
+	}
+
+	if err := app.linkRepo.Create(ctx, link); err != nil {
+		return nil, "", err
+	}
+
+	return link, token, nil
+}
+
+// Revoke invalidates the link resolved by the given token. Only its author or an owner of the file can do so.
+func (app *LinkApplication) Revoke(ctx context.Context, uid int32, token string) (*Link, error) {
+	app.logger.Info("processing a \"revoke\" link request",
+		zap.Int32("user_id", uid))
+
+	link, err := app.linkRepo.Find(ctx, TokenId(token))
+	if err != nil {
+		return nil, err
+	}
+
+	if link.author != uid {
+		f, err := app.fileRepo.Find(ctx, link.fileId, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		if f.Permission(uid)&file.Owner == 0 {
+			return nil, fb.ErrNotAvailable
+		}
+	}
+
+	if err := app.linkRepo.Delete(ctx, link); err != nil {
+		return nil, err
+	}
+
+	return link, nil
+}
+
+// Resolve returns the file the given token resolves to, if, and only if, the link is still valid and the given
+// password, if required, unlocks it. Every resolution counts as a download of the link.
+func (app *LinkApplication) Resolve(ctx context.Context, token string, password string) (*file.File, error) {
+	app.logger.Info("processing a \"resolve\" link request")
+
+	link, err := app.linkRepo.Find(ctx, TokenId(token))
+	if err != nil {
+		return nil, err
+	}
+
+	if link.IsExpired(time.Now()) || link.IsExhausted() || link.permission&file.Read == 0 {
+		return nil, fb.ErrNotAvailable
+	}
+
+	if err := link.Authorize(password); err != nil {
+		app.logger.Warn("unauthorized \"resolve\" link request",
+			zap.String("link_id", link.id),
+			zap.Error(err))
+
+		return nil, err
+	}
+
+	f, err := app.fileRepo.Find(ctx, link.fileId, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if f.Permission(link.author)&file.Owner == 0 {
+		// the link is no longer valid since its author lost the ownership of the file
+		return nil, fb.ErrNotAvailable
+	}
+
+	if err := app.linkRepo.AddDownload(ctx, link); err != nil {
+		return nil, err
+	}
+
+	f.ProtectFields(link.author)
+	return f, nil
+}
+
+// Download writes into w the content of the given file, as resolved by a link.
+func (app *LinkApplication) Download(ctx context.Context, f *file.File, w io.Writer) error {
+	return app.content.Read(ctx, f, w)
+}
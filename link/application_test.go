@@ -0,0 +1,230 @@
+package link
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	fb "github.com/alvidir/filebrowser"
+	"github.com/alvidir/filebrowser/file"
+	"go.uber.org/zap"
+)
+
+type linkRepositoryMock struct {
+	links map[string]*Link
+}
+
+func (mock *linkRepositoryMock) Create(ctx context.Context, link *Link) error {
+	mock.links[link.id] = link
+	return nil
+}
+
+func (mock *linkRepositoryMock) Find(ctx context.Context, id string) (*Link, error) {
+	if link, exists := mock.links[id]; exists {
+		return link, nil
+	}
+
+	return nil, fb.ErrNotFound
+}
+
+func (mock *linkRepositoryMock) AddDownload(ctx context.Context, link *Link) error {
+	if link.IsExhausted() {
+		return fb.ErrNotAvailable
+	}
+
+	link.downloads++
+	return nil
+}
+
+func (mock *linkRepositoryMock) Delete(ctx context.Context, link *Link) error {
+	delete(mock.links, link.id)
+	return nil
+}
+
+type fileRepositoryMock struct {
+	file *file.File
+}
+
+func (mock *fileRepositoryMock) Create(ctx context.Context, f *file.File) error {
+	return fb.ErrUnknown
+}
+
+func (mock *fileRepositoryMock) Find(ctx context.Context, id string, options *file.RepoOptions) (*file.File, error) {
+	if mock.file != nil && mock.file.Id() == id {
+		return mock.file, nil
+	}
+
+	return nil, fb.ErrNotFound
+}
+
+func (mock *fileRepositoryMock) FindAll(context.Context, []string) ([]*file.File, error) {
+	return nil, errors.New("unimplemented")
+}
+
+func (mock *fileRepositoryMock) Save(ctx context.Context, f *file.File) error {
+	return fb.ErrUnknown
+}
+
+func (mock *fileRepositoryMock) Delete(ctx context.Context, f *file.File) error {
+	return fb.ErrUnknown
+}
+
+type blobStoreMock struct {
+	data []byte
+}
+
+func (mock *blobStoreMock) Put(ctx context.Context, key string, r io.Reader) (int64, error) {
+	return io.Copy(io.Discard, r)
+}
+
+func (mock *blobStoreMock) Get(ctx context.Context, key string, w io.Writer) error {
+	_, err := w.Write(mock.data)
+	return err
+}
+
+func (mock *blobStoreMock) Delete(ctx context.Context, key string) error {
+	return nil
+}
+
+func (mock *blobStoreMock) Stat(ctx context.Context, key string) (*file.BlobInfo, error) {
+	return nil, fb.ErrNotFound
+}
+
+func newLinkApplication(f *file.File, data []byte, logger *zap.Logger) *LinkApplication {
+	content := file.NewContentStore(&blobStoreMock{data: data}, nil, 0, logger)
+	return NewLinkApplication(&linkRepositoryMock{links: make(map[string]*Link)}, &fileRepositoryMock{file: f}, content, logger)
+}
+
+func TestCreateWhenIsNotOwner(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	f, _ := file.NewFile("123", "testing")
+	f.AddPermission(111, file.Owner)
+	f.AddPermission(222, file.Read)
+
+	app := newLinkApplication(f, nil, logger)
+	if _, _, err := app.Create(context.Background(), 222, &CreateOptions{FileId: "123"}); !errors.Is(err, fb.ErrNotAvailable) {
+		t.Errorf("got error = %v, want = %v", err, fb.ErrNotAvailable)
+	}
+}
+
+func TestCreateWhenCannotRead(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	f, _ := file.NewFile("123", "testing")
+	f.AddPermission(111, file.Owner)
+
+	app := newLinkApplication(f, nil, logger)
+	if _, _, err := app.Create(context.Background(), 111, &CreateOptions{FileId: "123", Permission: file.Write}); !errors.Is(err, fb.ErrInvalidFormat) {
+		t.Errorf("got error = %v, want = %v", err, fb.ErrInvalidFormat)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	f, _ := file.NewFile("123", "testing")
+	f.AddPermission(111, file.Owner)
+
+	data := []byte("hello world")
+	app := newLinkApplication(f, data, logger)
+
+	options := CreateOptions{
+		FileId:       "123",
+		ExpiresAt:    time.Now().Add(time.Hour),
+		MaxDownloads: 1,
+		Password:     "secret",
+	}
+
+	link, token, err := app.Create(context.Background(), 111, &options)
+	if err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+		return
+	}
+
+	if got := link.Permission(); got != file.Read {
+		t.Errorf("got permission = %v, want = %v", got, file.Read)
+	}
+
+	if _, err := app.Resolve(context.Background(), "unknown", "secret"); !errors.Is(err, fb.ErrNotFound) {
+		t.Errorf("got error = %v, want = %v", err, fb.ErrNotFound)
+	}
+
+	if _, err := app.Resolve(context.Background(), token, "wrong"); !errors.Is(err, fb.ErrUnauthorized) {
+		t.Errorf("got error = %v, want = %v", err, fb.ErrUnauthorized)
+	}
+
+	resolved, err := app.Resolve(context.Background(), token, "secret")
+	if err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := app.Download(context.Background(), resolved, &buf); err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+	} else if !bytes.Equal(buf.Bytes(), data) {
+		t.Errorf("got data = %v, want = %v", buf.Bytes(), data)
+	}
+
+	if _, err := app.Resolve(context.Background(), token, "secret"); !errors.Is(err, fb.ErrNotAvailable) {
+		t.Errorf("got error = %v, want = %v", err, fb.ErrNotAvailable)
+	}
+}
+
+func TestResolveWhenAuthorIsNoLongerOwner(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	f, _ := file.NewFile("123", "testing")
+	f.AddPermission(111, file.Owner)
+	f.AddPermission(222, file.Owner)
+
+	app := newLinkApplication(f, nil, logger)
+
+	_, token, err := app.Create(context.Background(), 222, &CreateOptions{FileId: "123"})
+	if err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+		return
+	}
+
+	f.RevokeAccess(222)
+	if _, err := app.Resolve(context.Background(), token, ""); !errors.Is(err, fb.ErrNotAvailable) {
+		t.Errorf("got error = %v, want = %v", err, fb.ErrNotAvailable)
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	f, _ := file.NewFile("123", "testing")
+	f.AddPermission(111, file.Owner)
+	f.AddPermission(222, file.Read)
+
+	app := newLinkApplication(f, nil, logger)
+
+	_, token, err := app.Create(context.Background(), 111, &CreateOptions{FileId: "123"})
+	if err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+		return
+	}
+
+	if _, err := app.Revoke(context.Background(), 222, token); !errors.Is(err, fb.ErrNotAvailable) {
+		t.Errorf("got error = %v, want = %v", err, fb.ErrNotAvailable)
+	}
+
+	if _, err := app.Revoke(context.Background(), 111, token); err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+	}
+
+	if _, err := app.Resolve(context.Background(), token, ""); !errors.Is(err, fb.ErrNotFound) {
+		t.Errorf("got error = %v, want = %v", err, fb.ErrNotFound)
+	}
+}
@@ -0,0 +1,137 @@
+package link
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+
+	fb "github.com/alvidir/filebrowser"
+	"github.com/alvidir/filebrowser/file"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	tokenSize = 32
+)
+
+// Link grants access over a file to anyone knowing its token, no matter whether they have an account or not.
+type Link struct {
+	id           string // hash of the token, which is never stored
+	fileId       string
+	author       int32
+	permission   file.Permission
+	createdAt    time.Time
+	expiresAt    time.Time // zero means the link never expires
+	maxDownloads int32     // zero means no limit
+	downloads    int32
+	password     []byte // bcrypt hash of the password, if any
+}
+
+// NewLink returns a brand new link over the given file, together with the token resolving it.
+func NewLink(fileId string, author int32, perm file.Permission) (*Link, string, error) {
+	raw := make([]byte, tokenSize)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, "", err
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(raw)
+	return &Link{
+		id:         TokenId(token),
+		fileId:     fileId,
+		author:     author,
+		permission: perm,
+		createdAt:  time.Now(),
+	}, token, nil
+}
+
+// TokenId returns the id of the link resolved by the given token.
+func TokenId(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+func (link *Link) Id() string {
+	return link.id
+}
+
+func (link *Link) FileId() string {
+	return link.fileId
+}
+
+func (link *Link) Author() int32 {
+	return link.author
+}
+
+func (link *Link) Permission() file.Permission {
+	return link.permission
+}
+
+func (link *Link) CreatedAt() time.Time {
+	return link.createdAt
+}
+
+func (link *Link) ExpiresAt() time.Time {
+	return link.expiresAt
+}
+
+func (link *Link) MaxDownloads() int32 {
+	return link.maxDownloads
+}
+
+func (link *Link) Downloads() int32 {
+	return link.downloads
+}
+
+func (link *Link) SetExpiration(expiresAt time.Time) {
+	link.expiresAt = expiresAt
+}
+
+func (link *Link) SetMaxDownloads(max int32) {
+	link.maxDownloads = max
+}
+
+// SetPassword protects the link with the given password. An empty password removes any protection.
+func (link *Link) SetPassword(password string) error {
+	if len(password) == 0 {
+		link.password = nil
+		return nil
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	link.password = hash
+	return nil
+}
+
+// IsProtected returns true if, and only if, the link requires a password.
+func (link *Link) IsProtected() bool {
+	return len(link.password) > 0
+}
+
+// IsExpired returns true if, and only if, the link is no longer valid at the given time.
+func (link *Link) IsExpired(now time.Time) bool {
+	return !link.expiresAt.IsZero() && !now.Before(link.expiresAt)
+}
+
+// IsExhausted returns true if, and only if, the link has no downloads left.
+func (link *Link) IsExhausted() bool {
+	return link.maxDownloads > 0 && link.downloads >= link.maxDownloads
+}
+
+// Authorize returns nil if, and only if, the given password unlocks the link.
+func (link *Link) Authorize(password string) error {
+	if !link.IsProtected() {
+		return nil
+	}
+
+	if err := bcrypt.CompareHashAndPassword(link.password, []byte(password)); err != nil {
+		return fb.ErrUnauthorized
+	}
+
+	return nil
+}
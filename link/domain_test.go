@@ -0,0 +1,85 @@
+package link
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	fb "github.com/alvidir/filebrowser"
+	"github.com/alvidir/filebrowser/file"
+)
+
+func TestNewLink(t *testing.T) {
+	link, token, err := NewLink("123", 999, file.Read)
+	if err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+		return
+	}
+
+	if got := link.Id(); got != TokenId(token) {
+		t.Errorf("got id = %v, want = %v", got, TokenId(token))
+	}
+
+	if got := link.Id(); got == token {
+		t.Errorf("got id = %v, want != %v", got, token)
+	}
+
+	_, another, _ := NewLink("123", 999, file.Read)
+	if another == token {
+		t.Errorf("got token = %v, want != %v", another, token)
+	}
+}
+
+func TestLinkIsExpired(t *testing.T) {
+	link, _, _ := NewLink("123", 999, file.Read)
+	if link.IsExpired(time.Now()) {
+		t.Errorf("got expired = %v, want = %v", true, false)
+	}
+
+	link.SetExpiration(time.Now().Add(time.Hour))
+	if link.IsExpired(time.Now()) {
+		t.Errorf("got expired = %v, want = %v", true, false)
+	}
+
+	if !link.IsExpired(time.Now().Add(2 * time.Hour)) {
+		t.Errorf("got expired = %v, want = %v", false, true)
+	}
+}
+
+func TestLinkIsExhausted(t *testing.T) {
+	link, _, _ := NewLink("123", 999, file.Read)
+	link.downloads = 10
+
+	if link.IsExhausted() {
+		t.Errorf("got exhausted = %v, want = %v", true, false)
+	}
+
+	link.SetMaxDownloads(10)
+	if !link.IsExhausted() {
+		t.Errorf("got exhausted = %v, want = %v", false, true)
+	}
+}
+
+func TestLinkAuthorize(t *testing.T) {
+	link, _, _ := NewLink("123", 999, file.Read)
+	if err := link.Authorize("anything"); err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+	}
+
+	if err := link.SetPassword("secret"); err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+		return
+	}
+
+	if !link.IsProtected() {
+		t.Errorf("got protected = %v, want = %v", false, true)
+	}
+
+	if err := link.Authorize("wrong"); !errors.Is(err, fb.ErrUnauthorized) {
+		t.Errorf("got error = %v, want = %v", err, fb.ErrUnauthorized)
+	}
+
+	if err := link.Authorize("secret"); err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+	}
+}
@@ -0,0 +1,86 @@
+package link
+
+import (
+	"context"
+	"time"
+
+	fb "github.com/alvidir/filebrowser"
+	"github.com/alvidir/filebrowser/file"
+	"github.com/alvidir/filebrowser/proto"
+	"go.uber.org/zap"
+)
+
+type LinkGrpcService struct {
+	proto.UnimplementedLinkServiceServer
+	linkApp   *LinkApplication
+	logger    *zap.Logger
+	uidHeader string
+}
+
+func NewLinkGrpcServer(linkApp *LinkApplication, authHeader string, logger *zap.Logger) *LinkGrpcService {
+	return &LinkGrpcService{
+		linkApp:   linkApp,
+		logger:    logger,
+		uidHeader: authHeader,
+	}
+}
+
+func NewProtoLink(link *Link, token string) *proto.Link {
+	descriptor := &proto.Link{
+		Token:        token,
+		FileId:       link.fileId,
+		Permissions:  file.NewPermissions(link.author, link.permission),
+		CreatedAt:    link.createdAt.Unix(),
+		MaxDownloads: link.maxDownloads,
+		Downloads:    link.downloads,
+		Protected:    link.IsProtected(),
+	}
+
+	if !link.expiresAt.IsZero() {
+		descriptor.ExpiresAt = link.expiresAt.Unix()
+	}
+
+	return descriptor
+}
+
+func (server *LinkGrpcService) Create(ctx context.Context, req *proto.LinkRequest) (*proto.Link, error) {
+	uid, err := fb.GetUidFromGrpcCtx(ctx, server.uidHeader, server.logger)
+	if err != nil {
+		return nil, err
+	}
+
+	options := CreateOptions{
+		FileId:       req.GetFileId(),
+		MaxDownloads: req.GetMaxDownloads(),
+		Password:     req.GetPassword(),
+	}
+
+	if perms := req.GetPermissions(); perms != nil {
+		options.Permission = file.NewPermission(perms)
+	}
+
+	if expiresAt := req.GetExpiresAt(); expiresAt > 0 {
+		options.ExpiresAt = time.Unix(expiresAt, 0)
+	}
+
+	link, token, err := server.linkApp.Create(ctx, uid, &options)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewProtoLink(link, token), nil
+}
+
+func (server *LinkGrpcService) Revoke(ctx context.Context, req *proto.Link) (*proto.Link, error) {
+	uid, err := fb.GetUidFromGrpcCtx(ctx, server.uidHeader, server.logger)
+	if err != nil {
+		return nil, err
+	}
+
+	link, err := server.linkApp.Revoke(ctx, uid, req.GetToken())
+	if err != nil {
+		return nil, err
+	}
+
+	return NewProtoLink(link, ""), nil
+}
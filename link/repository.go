@@ -0,0 +1,156 @@
+package link
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	fb "github.com/alvidir/filebrowser"
+	"github.com/alvidir/filebrowser/file"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+)
+
+const (
+	MongoLinkCollectionName = "links"
+)
+
+type mongoLink struct {
+	ID           string             `bson:"_id"`
+	FileID       primitive.ObjectID `bson:"file_id"`
+	Author       int32              `bson:"author"`
+	Permission   file.Permission    `bson:"permission"`
+	CreatedAt    time.Time          `bson:"created_at"`
+	ExpiresAt    time.Time          `bson:"expires_at,omitempty"`
+	MaxDownloads int32              `bson:"max_downloads"`
+	Downloads    int32              `bson:"downloads"`
+	Password     []byte             `bson:"password,omitempty"`
+}
+
+func newMongoLink(link *Link) (*mongoLink, error) {
+	fileID, err := primitive.ObjectIDFromHex(link.fileId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &mongoLink{
+		ID:           link.id,
+		FileID:       fileID,
+		Author:       link.author,
+		Permission:   link.permission,
+		CreatedAt:    link.createdAt,
+		ExpiresAt:    link.expiresAt,
+		MaxDownloads: link.maxDownloads,
+		Downloads:    link.downloads,
+		Password:     link.password,
+	}, nil
+}
+
+type MongoLinkRepository struct {
+	conn   *mongo.Collection
+	logger *zap.Logger
+}
+
+func NewMongoLinkRepository(db *mongo.Database, logger *zap.Logger) *MongoLinkRepository {
+	return &MongoLinkRepository{
+		conn:   db.Collection(MongoLinkCollectionName),
+		logger: logger,
+	}
+}
+
+func (repo *MongoLinkRepository) Create(ctx context.Context, link *Link) error {
+	mlink, err := newMongoLink(link)
+	if err != nil {
+		repo.logger.Error("building mongo link",
+			zap.String("file_id", link.fileId),
+			zap.Error(err))
+
+		return fb.ErrUnknown
+	}
+
+	if _, err := repo.conn.InsertOne(ctx, mlink); err != nil {
+		repo.logger.Error("performing insert one on mongo",
+			zap.String("file_id", link.fileId),
+			zap.Error(err))
+
+		return fb.ErrUnknown
+	}
+
+	return nil
+}
+
+func (repo *MongoLinkRepository) Find(ctx context.Context, id string) (*Link, error) {
+	var mlink mongoLink
+	err := repo.conn.FindOne(ctx, bson.M{"_id": id}).Decode(&mlink)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, fb.ErrNotFound
+	} else if err != nil {
+		repo.logger.Error("performing find one on mongo",
+			zap.String("link_id", id),
+			zap.Error(err))
+
+		return nil, fb.ErrUnknown
+	}
+
+	return repo.build(&mlink), nil
+}
+
+// AddDownload counts a new download of the given link if, and only if, it has any left.
+func (repo *MongoLinkRepository) AddDownload(ctx context.Context, link *Link) error {
+	filter := bson.M{
+		"_id": link.id,
+		"$or": bson.A{
+			bson.M{"max_downloads": 0},
+			bson.M{"$expr": bson.M{"$lt": bson.A{"$downloads", "$max_downloads"}}},
+		},
+	}
+
+	result, err := repo.conn.UpdateOne(ctx, filter, bson.M{"$inc": bson.M{"downloads": 1}})
+	if err != nil {
+		repo.logger.Error("performing update one on mongo",
+			zap.String("link_id", link.id),
+			zap.Error(err))
+
+		return fb.ErrUnknown
+	}
+
+	if result.MatchedCount == 0 {
+		return fb.ErrNotAvailable
+	}
+
+	link.downloads++
+	return nil
+}
+
+func (repo *MongoLinkRepository) Delete(ctx context.Context, link *Link) error {
+	result, err := repo.conn.DeleteOne(ctx, bson.M{"_id": link.id})
+	if err != nil {
+		repo.logger.Error("performing delete one on mongo",
+			zap.String("link_id", link.id),
+			zap.Error(err))
+
+		return fb.ErrUnknown
+	}
+
+	if result.DeletedCount == 0 {
+		return fb.ErrNotFound
+	}
+
+	return nil
+}
+
+func (repo *MongoLinkRepository) build(mlink *mongoLink) *Link {
+	return &Link{
+		id:           mlink.ID,
+		fileId:       mlink.FileID.Hex(),
+		author:       mlink.Author,
+		permission:   mlink.Permission,
+		createdAt:    mlink.CreatedAt,
+		expiresAt:    mlink.ExpiresAt,
+		maxDownloads: mlink.MaxDownloads,
+		downloads:    mlink.Downloads,
+		password:     mlink.Password,
+	}
+}
@@ -0,0 +1,98 @@
+package link
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"strings"
+
+	fb "github.com/alvidir/filebrowser"
+	"go.uber.org/zap"
+)
+
+const (
+	LinkPathPrefix = "/link/"
+	PasswordHeader = "X-Link-Password"
+)
+
+// LinkRestService resolves links with no authentication at all, since knowing the token is what grants access.
+type LinkRestService struct {
+	app     *LinkApplication
+	handler *http.ServeMux
+	logger  *zap.Logger
+}
+
+func NewLinkRestServer(app *LinkApplication, logger *zap.Logger) *LinkRestService {
+	server := &LinkRestService{
+		app:     app,
+		handler: http.NewServeMux(),
+		logger:  logger,
+	}
+
+	server.handler.HandleFunc(LinkPathPrefix, server.downloadHandler)
+	return server
+}
+
+func (server *LinkRestService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	server.handler.ServeHTTP(w, r)
+}
+
+func (server *LinkRestService) downloadHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	token := strings.TrimPrefix(r.URL.Path, LinkPathPrefix)
+	if len(token) == 0 || strings.Contains(token, "/") {
+		http.NotFound(w, r)
+		return
+	}
+
+	f, err := server.app.Resolve(r.Context(), token, r.Header.Get(PasswordHeader))
+	if err != nil {
+		if errors.Is(err, fb.ErrNotAvailable) {
+			// the link did exist, but it is no longer valid
+			http.Error(w, err.Error(), http.StatusGone)
+		} else {
+			fb.HttpError(w, err)
+		}
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": f.Name(),
+	}))
+
+	if err := server.app.Download(r.Context(), f, w); err != nil {
+		// the headers may have been already sent, so the error cannot be reported to the client
+		server.logger.Error("streaming link content",
+			zap.String("file_id", f.Id()),
+			zap.Error(err))
+	}
+}
+
+// RegisterRoutes describes into the given registry all the routes served by the service.
+func (server *LinkRestService) RegisterRoutes(registry *fb.RestRegistry) {
+	registry.Register(fb.Route{
+		Method:  http.MethodGet,
+		Path:    LinkPathPrefix + "{token}",
+		Summary: "Downloads the file the given link resolves to",
+		Parameters: []fb.Parameter{
+			{Name: "token", In: "path", Required: true, Schema: &fb.Schema{Type: "string"}},
+			{Name: PasswordHeader, In: "header", Description: "The password of the link, if protected", Schema: &fb.Schema{Type: "string"}},
+		},
+		Responses: map[int]fb.Response{
+			http.StatusOK: {Description: "The content of the file", ContentType: fb.BinaryContentType, Schema: &fb.Schema{Type: "string", Format: "binary"}},
+			http.StatusGone: {
+				Description: "The link has expired, has been exhausted or no longer grants access to the file",
+				ContentType: fb.TextContentType,
+				Schema:      fb.SchemaRef("Error"),
+			},
+		},
+		Errors: []error{fb.ErrNotFound, fb.ErrUnauthorized},
+		Public: true,
+	})
+}
@@ -3,6 +3,8 @@ package filebrowser
 import (
 	"context"
 
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.mongodb.org/mongo-driver/mongo/readpref"
@@ -20,3 +22,13 @@ func NewMongoDBConn(uri, database string) (*mongo.Database, error) {
 
 	return client.Database(database), nil
 }
+
+// NewMongoRevisionFilter returns a filter matching the document with the given id if, and only if, it is still
+// at the given revision. Documents stored before keeping track of revisions are considered at revision zero.
+func NewMongoRevisionFilter(id primitive.ObjectID, revision int64) bson.M {
+	if revision == 0 {
+		return bson.M{"_id": id, "revision": bson.M{"$in": bson.A{0, nil}}}
+	}
+
+	return bson.M{"_id": id, "revision": revision}
+}
@@ -0,0 +1,513 @@
+package filebrowser
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+const (
+	OpenApiVersion = "3.0.3"
+	OpenApiPath    = "/openapi.json"
+
+	TextContentType   = "text/plain"
+	BinaryContentType = "application/octet-stream"
+
+	greedyParamSuffix = "..."
+)
+
+// Schema is the subset of the OpenAPI schema object requests are validated against.
+type Schema struct {
+	Ref         string             `json:"$ref,omitempty"`
+	Type        string             `json:"type,omitempty"`
+	Format      string             `json:"format,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	Items       *Schema            `json:"items,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+	Enum        []string           `json:"enum,omitempty"`
+}
+
+// SchemaRef returns a schema referencing the component schema with the given name.
+func SchemaRef(name string) *Schema {
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// Parameter describes a parameter of a route, located either in its "path", "query" or "header".
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// Response describes the body of a response, if any.
+type Response struct {
+	Description string
+	ContentType string
+	Schema      *Schema
+}
+
+// Route describes an endpoint of the REST service. A path parameter ending with "..." (e.g. {path...}) may only
+// be the last segment of the path, and matches all the remaining ones.
+type Route struct {
+	Method     string
+	Path       string
+	Summary    string
+	Parameters []Parameter
+	Request    *Schema
+	Responses  map[int]Response
+	Errors     []error
+	Public     bool // the route requires no authentication
+}
+
+// RestRegistry keeps track of all the routes served by the REST service, from which the OpenAPI document
+// is produced and incoming requests are validated.
+type RestRegistry struct {
+	title   string
+	version string
+	routes  []*Route
+	schemas map[string]*Schema
+	logger  *zap.Logger
+}
+
+func NewRestRegistry(title, version string, logger *zap.Logger) *RestRegistry {
+	registry := &RestRegistry{
+		title:   title,
+		version: version,
+		schemas: make(map[string]*Schema),
+		logger:  logger,
+	}
+
+	registry.Register(Route{
+		Method:  http.MethodGet,
+		Path:    OpenApiPath,
+		Summary: "Returns the OpenAPI document of the service",
+		Responses: map[int]Response{
+			http.StatusOK: {Description: "The OpenAPI document", ContentType: JsonContentType, Schema: &Schema{Type: "object"}},
+		},
+		Public: true,
+	})
+
+	return registry
+}
+
+// Register adds the given routes into the registry.
+func (registry *RestRegistry) Register(routes ...Route) {
+	for index := range routes {
+		registry.routes = append(registry.routes, &routes[index])
+	}
+}
+
+// AddSchema adds the given schema as a component, so routes can reference it by name.
+func (registry *RestRegistry) AddSchema(name string, schema *Schema) {
+	registry.schemas[name] = schema
+}
+
+// Document returns the OpenAPI document describing all the registered routes.
+func (registry *RestRegistry) Document() map[string]interface{} {
+	paths := make(map[string]map[string]interface{})
+	for _, route := range registry.routes {
+		p := strings.ReplaceAll(route.Path, greedyParamSuffix+"}", "}")
+		if _, exists := paths[p]; !exists {
+			paths[p] = make(map[string]interface{})
+		}
+
+		paths[p][strings.ToLower(route.Method)] = registry.operation(route)
+	}
+
+	schemas := make(map[string]*Schema, len(registry.schemas)+1)
+	for name, schema := range registry.schemas {
+		schemas[name] = schema
+	}
+
+	schemas["Error"] = errorSchema()
+
+	return map[string]interface{}{
+		"openapi": OpenApiVersion,
+		"info": map[string]interface{}{
+			"title":   registry.title,
+			"version": registry.version,
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": schemas,
+			"securitySchemes": map[string]interface{}{
+				"bearer": map[string]interface{}{
+					"type":         "http",
+					"scheme":       "bearer",
+					"bearerFormat": "JWT",
+				},
+			},
+		},
+	}
+}
+
+func errorSchema() *Schema {
+	codes := make([]string, 0, len(errorStatuses))
+	for _, known := range errorStatuses {
+		if code := known.err.Error(); strings.HasPrefix(code, "E") {
+			codes = append(codes, code)
+		}
+	}
+
+	return &Schema{
+		Type:        "string",
+		Description: "The code of the error",
+		Enum:        codes,
+	}
+}
+
+func (registry *RestRegistry) operation(route *Route) map[string]interface{} {
+	responses := make(map[string]interface{})
+	for code, response := range route.Responses {
+		responses[strconv.Itoa(code)] = newResponseObject(response)
+	}
+
+	// errors sharing the same status are described by the same response
+	errs := make(map[int][]string)
+	for _, err := range route.Errors {
+		code := HttpStatus(err)
+		errs[code] = append(errs[code], err.Error())
+	}
+
+	if !route.Public {
+		errs[http.StatusUnauthorized] = append(errs[http.StatusUnauthorized], ErrUnauthorized.Error())
+	}
+
+	if route.Request != nil || len(route.Parameters) > 0 {
+		errs[http.StatusBadRequest] = append(errs[http.StatusBadRequest], ErrInvalidFormat.Error())
+	}
+
+	for code, reasons := range errs {
+		if _, exists := route.Responses[code]; exists {
+			continue
+		}
+
+		responses[strconv.Itoa(code)] = newResponseObject(Response{
+			Description: fmt.Sprintf("%s (%s)", http.StatusText(code), strings.Join(uniqueStrings(reasons), ", ")),
+			ContentType: TextContentType,
+			Schema:      SchemaRef("Error"),
+		})
+	}
+
+	operation := map[string]interface{}{
+		"summary":   route.Summary,
+		"responses": responses,
+	}
+
+	if len(route.Parameters) > 0 {
+		operation["parameters"] = route.Parameters
+	}
+
+	if route.Request != nil {
+		operation["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content": map[string]interface{}{
+				JsonContentType: map[string]interface{}{
+					"schema": route.Request,
+				},
+			},
+		}
+	}
+
+	if route.Public {
+		operation["security"] = []interface{}{}
+	} else {
+		operation["security"] = []interface{}{map[string]interface{}{"bearer": []string{}}}
+	}
+
+	return operation
+}
+
+func newResponseObject(response Response) map[string]interface{} {
+	object := map[string]interface{}{
+		"description": response.Description,
+	}
+
+	if response.Schema != nil {
+		object["content"] = map[string]interface{}{
+			response.ContentType: map[string]interface{}{
+				"schema": response.Schema,
+			},
+		}
+	}
+
+	return object
+}
+
+func uniqueStrings(values []string) []string {
+	set := make(map[string]struct{}, len(values))
+	unique := make([]string, 0, len(values))
+	for _, value := range values {
+		if _, exists := set[value]; !exists {
+			set[value] = struct{}{}
+			unique = append(unique, value)
+		}
+	}
+
+	sort.Strings(unique)
+	return unique
+}
+
+// ServeHTTP replies with the OpenAPI document of the registry.
+func (registry *RestRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := json.Marshal(registry.Document())
+	if err != nil {
+		registry.logger.Error("marshaling openapi document",
+			zap.Error(err))
+
+		HttpError(w, ErrUnknown)
+		return
+	}
+
+	w.Header().Set("Content-Type", JsonContentType)
+	if _, err := w.Write(body); err != nil {
+		registry.logger.Error("writing http response",
+			zap.Error(err))
+	}
+}
+
+// matchPath returns the path parameters of the given path, if, and only if, it matches the given template.
+func matchPath(template string, p string) (map[string]string, bool) {
+	segments := strings.Split(template, "/")
+	values := strings.Split(p, "/")
+	params := make(map[string]string)
+
+	for index, segment := range segments {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, greedyParamSuffix+"}") {
+			if index < len(values) {
+				params[segment[1:len(segment)-len(greedyParamSuffix)-1]] = strings.Join(values[index:], "/")
+			}
+
+			return params, index <= len(values)
+		}
+
+		if index >= len(values) {
+			return nil, false
+		}
+
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			if len(values[index]) == 0 {
+				return nil, false
+			}
+
+			params[segment[1:len(segment)-1]] = values[index]
+		} else if segment != values[index] {
+			return nil, false
+		}
+	}
+
+	return params, len(segments) == len(values)
+}
+
+// Middleware rejects any request not matching a registered route, or not satisfying its parameters and body.
+func (registry *RestRegistry) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var allowed []string
+		for _, route := range registry.routes {
+			params, matches := matchPath(route.Path, r.URL.Path)
+			if !matches {
+				continue
+			}
+
+			if route.Method != r.Method {
+				allowed = append(allowed, route.Method)
+				continue
+			}
+
+			if err := registry.validate(route, r, params); err != nil {
+				registry.logger.Warn("validating http request",
+					zap.String("method", r.Method),
+					zap.String("path", r.URL.Path),
+					zap.Error(err))
+
+				http.Error(w, ErrInvalidFormat.Error(), http.StatusBadRequest)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if len(allowed) > 0 {
+			w.Header().Set("Allow", strings.Join(uniqueStrings(allowed), ", "))
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		HttpError(w, ErrNotFound)
+	})
+}
+
+func (registry *RestRegistry) validate(route *Route, r *http.Request, params map[string]string) error {
+	for _, param := range route.Parameters {
+		var value string
+		var exists bool
+
+		switch param.In {
+		case "path":
+			value, exists = params[param.Name]
+		case "query":
+			exists = r.URL.Query().Has(param.Name)
+			value = r.URL.Query().Get(param.Name)
+		case "header":
+			exists = len(r.Header.Values(param.Name)) > 0
+			value = r.Header.Get(param.Name)
+		}
+
+		if !exists || len(value) == 0 {
+			// path parameters are already enforced when matching the path, where a trailing one may be empty
+			if param.Required && param.In != "path" {
+				return fmt.Errorf("parameter %s is required", param.Name)
+			}
+
+			continue
+		}
+
+		if err := registry.validateValue(param.Schema, value, param.Name); err != nil {
+			return err
+		}
+	}
+
+	if route.Request == nil {
+		return nil
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+
+	// the body must be readable again by the handler
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	if len(bytes.TrimSpace(body)) == 0 {
+		body = []byte("{}")
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return err
+	}
+
+	return registry.validateValue(route.Request, value, "body")
+}
+
+func (registry *RestRegistry) resolve(schema *Schema) *Schema {
+	for schema != nil && len(schema.Ref) > 0 {
+		schema = registry.schemas[strings.TrimPrefix(schema.Ref, "#/components/schemas/")]
+	}
+
+	return schema
+}
+
+// validateValue returns an error if the given value, as decoded from JSON or taken from a parameter, does not
+// satisfy the given schema.
+func (registry *RestRegistry) validateValue(schema *Schema, value interface{}, name string) error {
+	if schema = registry.resolve(schema); schema == nil {
+		return nil
+	}
+
+	if raw, ok := value.(string); ok && (schema.Type == "integer" || schema.Type == "number" || schema.Type == "boolean") {
+		// parameters are always strings, no matter their type
+		var decoded interface{}
+		if err := json.Unmarshal([]byte(raw), &decoded); err == nil {
+			value = decoded
+		}
+	}
+
+	switch schema.Type {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s must be an object", name)
+		}
+
+		for _, required := range schema.Required {
+			if _, exists := object[required]; !exists {
+				return fmt.Errorf("%s.%s is required", name, required)
+			}
+		}
+
+		for key, property := range schema.Properties {
+			if field, exists := object[key]; exists && field != nil {
+				if err := registry.validateValue(property, field, name+"."+key); err != nil {
+					return err
+				}
+			}
+		}
+
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s must be an array", name)
+		}
+
+		for index, item := range items {
+			if err := registry.validateValue(schema.Items, item, fmt.Sprintf("%s[%d]", name, index)); err != nil {
+				return err
+			}
+		}
+
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			if _, isNumber := value.(float64); isNumber && schema.Format == "int64" {
+				return nil
+			}
+
+			return fmt.Errorf("%s must be a string", name)
+		}
+
+		if schema.Format == "byte" {
+			if _, err := base64.StdEncoding.DecodeString(s); err != nil {
+				if _, err := base64.URLEncoding.DecodeString(s); err != nil {
+					return fmt.Errorf("%s must be base64 encoded", name)
+				}
+			}
+		} else if schema.Format == "int64" {
+			if _, err := strconv.ParseInt(s, 10, 64); err != nil {
+				return fmt.Errorf("%s must be an integer", name)
+			}
+		}
+
+		if len(schema.Enum) > 0 {
+			for _, allowed := range schema.Enum {
+				if s == allowed {
+					return nil
+				}
+			}
+
+			return fmt.Errorf("%s must be any of %v", name, schema.Enum)
+		}
+
+	case "integer":
+		number, ok := value.(float64)
+		if !ok || number != math.Trunc(number) {
+			return fmt.Errorf("%s must be an integer", name)
+		}
+
+	case "number":
+		if _, ok := value.(float64); !ok {
+			return fmt.Errorf("%s must be a number", name)
+		}
+
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s must be a boolean", name)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,151 @@
+package filebrowser
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestMatchPath(t *testing.T) {
+	tests := []struct {
+		template string
+		path     string
+		matches  bool
+		params   map[string]string
+	}{
+		{template: "/files/", path: "/files/", matches: true},
+		{template: "/files/", path: "/files/123", matches: false},
+		{template: "/files/{id}", path: "/files/123", matches: true, params: map[string]string{"id": "123"}},
+		{template: "/files/{id}", path: "/files/", matches: false},
+		{template: "/files/{id}", path: "/files/123/content", matches: false},
+		{template: "/files/{id}/content", path: "/files/123/content", matches: true, params: map[string]string{"id": "123"}},
+		{template: "/directory/{path...}", path: "/directory/a/b/c", matches: true, params: map[string]string{"path": "a/b/c"}},
+		{template: "/directory/{path...}", path: "/directory/", matches: true, params: map[string]string{"path": ""}},
+		{template: "/directory/{path...}", path: "/files/a", matches: false},
+	}
+
+	for _, test := range tests {
+		params, matches := matchPath(test.template, test.path)
+		if matches != test.matches {
+			t.Errorf("%s %s: got matches = %v, want = %v", test.template, test.path, matches, test.matches)
+			continue
+		}
+
+		for name, want := range test.params {
+			if got := params[name]; got != want {
+				t.Errorf("%s %s: got %s = %v, want = %v", test.template, test.path, name, got, want)
+			}
+		}
+	}
+}
+
+func TestRestRegistryMiddleware(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	registry := NewRestRegistry("test", "v1", logger)
+	registry.AddSchema("Item", &Schema{
+		Type:     "object",
+		Required: []string{"name"},
+		Properties: map[string]*Schema{
+			"name":  {Type: "string"},
+			"count": {Type: "integer"},
+			"data":  {Type: "string", Format: "byte"},
+		},
+	})
+
+	registry.Register(
+		Route{
+			Method:  http.MethodPost,
+			Path:    "/items/",
+			Request: SchemaRef("Item"),
+		},
+		Route{
+			Method: http.MethodGet,
+			Path:   "/search",
+			Parameters: []Parameter{
+				{Name: "q", In: "query", Required: true, Schema: &Schema{Type: "string"}},
+				{Name: "limit", In: "query", Schema: &Schema{Type: "integer"}},
+			},
+		},
+	)
+
+	var body string
+	handler := registry.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		body = string(raw)
+	}))
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{name: "valid body", method: http.MethodPost, path: "/items/", body: `{"name": "item", "count": 2, "data": "aGVsbG8="}`, status: http.StatusOK},
+		{name: "missing required field", method: http.MethodPost, path: "/items/", body: `{"count": 2}`, status: http.StatusBadRequest},
+		{name: "wrong field type", method: http.MethodPost, path: "/items/", body: `{"name": "item", "count": "two"}`, status: http.StatusBadRequest},
+		{name: "not base64 data", method: http.MethodPost, path: "/items/", body: `{"name": "item", "data": "%%%"}`, status: http.StatusBadRequest},
+		{name: "malformed body", method: http.MethodPost, path: "/items/", body: `{"name":`, status: http.StatusBadRequest},
+		{name: "wrong method", method: http.MethodGet, path: "/items/", status: http.StatusMethodNotAllowed},
+		{name: "unknown path", method: http.MethodGet, path: "/unknown", status: http.StatusNotFound},
+		{name: "valid query", method: http.MethodGet, path: "/search?q=a&limit=10", status: http.StatusOK},
+		{name: "missing query", method: http.MethodGet, path: "/search", status: http.StatusBadRequest},
+		{name: "wrong query type", method: http.MethodGet, path: "/search?q=a&limit=ten", status: http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		body = ""
+
+		req := httptest.NewRequest(test.method, test.path, strings.NewReader(test.body))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != test.status {
+			t.Errorf("%s: got status = %v, want = %v", test.name, rec.Code, test.status)
+		}
+
+		if rec.Code == http.StatusOK && body != test.body {
+			t.Errorf("%s: got body = %v, want = %v", test.name, body, test.body)
+		}
+	}
+}
+
+func TestRestRegistryDocument(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	registry := NewRestRegistry("test", "v1", logger)
+	registry.Register(Route{
+		Method: http.MethodGet,
+		Path:   "/directory/{path...}",
+		Responses: map[int]Response{
+			http.StatusOK: {Description: "ok"},
+		},
+		Errors: []error{ErrNotFound, ErrNotAvailable},
+	})
+
+	paths, _ := registry.Document()["paths"].(map[string]map[string]interface{})
+	operation, exists := paths["/directory/{path}"]["get"].(map[string]interface{})
+	if !exists {
+		t.Fatalf("got paths = %v, want = %v", paths, "/directory/{path}")
+	}
+
+	responses, _ := operation["responses"].(map[string]interface{})
+	for _, code := range []string{"200", "401", "403", "404"} {
+		if _, exists := responses[code]; !exists {
+			t.Errorf("got responses = %v, want = %v", responses, code)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	registry.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, OpenApiPath, nil))
+	if !strings.Contains(rec.Body.String(), `"openapi":"`+OpenApiVersion+`"`) {
+		t.Errorf("got document = %v, want = %v", rec.Body.String(), OpenApiVersion)
+	}
+}
@@ -2,16 +2,23 @@ package filebrowser
 
 import (
 	"context"
-	"sync"
 
 	"github.com/streadway/amqp"
 	"go.uber.org/zap"
 )
 
 const (
-	EventKindCreated = "created"
-	EventKindDeleted = "deleted"
-	ExchangeType     = "fanout"
+	EventKindCreated  = "created"
+	EventKindUpdated  = "updated"
+	EventKindMoved    = "moved"
+	EventKindDeleted  = "deleted"
+	EventKindDisabled = "disabled"
+	ExchangeType      = "fanout"
+
+	// path events tell about the changes made into the directory of a user, rather than into the files themselves
+	EventKindPathCreated = "path_created"
+	EventKindPathMoved   = "path_moved"
+	EventKindPathDeleted = "path_deleted"
 )
 
 type EventHandler func(ctx context.Context, body []byte)
@@ -82,6 +89,8 @@ func (bus *RabbitMqEventBus) QueueBind(exchange, queue string) error {
 	return nil
 }
 
+// Consume handles, one after another and in the same order they were delivered, all the events from the given
+// queue, until the context gets cancelled or the channel closed.
 func (bus *RabbitMqEventBus) Consume(ctx context.Context, queue string, handler EventHandler) error {
 	events, err := bus.chann.Consume(
 		queue, // queue
@@ -103,9 +112,6 @@ func (bus *RabbitMqEventBus) Consume(ctx context.Context, queue string, handler
 	bus.logger.Info("waiting for events",
 		zap.String("queue", queue))
 
-	var wg sync.WaitGroup
-	defer wg.Wait()
-
 	for {
 		select {
 		case event, ok := <-events:
@@ -116,11 +122,8 @@ func (bus *RabbitMqEventBus) Consume(ctx context.Context, queue string, handler
 				return ErrChannelClosed
 			}
 
-			wg.Add(1)
-			go func(ctx context.Context, wg *sync.WaitGroup) {
-				defer wg.Done()
-				handler(ctx, event.Body)
-			}(ctx, &wg)
+			// events are handled one at a time, so that their order is kept
+			handler(ctx, event.Body)
 
 		case <-ctx.Done():
 			bus.logger.Warn("context cancelled",
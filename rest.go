@@ -0,0 +1,50 @@
+package filebrowser
+
+import (
+	"io"
+	"net/http"
+
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+const JsonContentType = "application/json"
+
+// ReadJson decodes the body of the given request into msg, which is expected to follow the JSON mapping of
+// protobuf, so REST and gRPC clients share the very same representation.
+func ReadJson(r *http.Request, msg proto.Message) error {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return ErrInvalidFormat
+	}
+
+	if len(body) == 0 {
+		return nil
+	}
+
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(body, msg); err != nil {
+		return ErrInvalidFormat
+	}
+
+	return nil
+}
+
+// WriteJson replies to the request with the JSON representation of msg and the given status code.
+func WriteJson(w http.ResponseWriter, code int, msg proto.Message, logger *zap.Logger) {
+	body, err := protojson.Marshal(msg)
+	if err != nil {
+		logger.Error("marshaling json response",
+			zap.Error(err))
+
+		HttpError(w, ErrUnknown)
+		return
+	}
+
+	w.Header().Set("Content-Type", JsonContentType)
+	w.WriteHeader(code)
+	if _, err := w.Write(body); err != nil {
+		logger.Error("writing http response",
+			zap.Error(err))
+	}
+}
@@ -0,0 +1,152 @@
+package filebrowser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+const (
+	LogoutPath = "/logout"
+
+	redisRevokedSessionKey = "filebrowser:session:%s:revoked"
+	redisRevokedUserKey    = "filebrowser:user:%d:revoked_at"
+)
+
+// SessionStore keeps track of those sessions that have been revoked before expiring, so their tokens are no
+// longer accepted.
+type SessionStore interface {
+	RevokeSession(ctx context.Context, session *Session) error
+	RevokeUser(ctx context.Context, uid int32) error
+	IsRevoked(ctx context.Context, session *Session) (bool, error)
+}
+
+type RedisSessionStore struct {
+	client *redis.Client
+	logger *zap.Logger
+}
+
+func NewRedisSessionStore(client *redis.Client, logger *zap.Logger) *RedisSessionStore {
+	return &RedisSessionStore{
+		client: client,
+		logger: logger,
+	}
+}
+
+// RevokeSession revokes the given session until it expires. A session with no expiration is revoked forever.
+func (store *RedisSessionStore) RevokeSession(ctx context.Context, session *Session) error {
+	if len(session.id) == 0 {
+		// a session with no id can only be revoked by revoking all the sessions of its user
+		return ErrInvalidToken
+	}
+
+	var ttl time.Duration
+	if !session.expiresAt.IsZero() {
+		if ttl = time.Until(session.expiresAt); ttl <= 0 {
+			return nil
+		}
+	}
+
+	key := fmt.Sprintf(redisRevokedSessionKey, session.id)
+	if err := store.client.Set(ctx, key, session.uid, ttl).Err(); err != nil {
+		store.logger.Error("performing set on redis",
+			zap.String("session_id", session.id),
+			zap.Error(err))
+
+		return ErrUnknown
+	}
+
+	return nil
+}
+
+// RevokeUser revokes all the sessions the given user has been issued until now.
+func (store *RedisSessionStore) RevokeUser(ctx context.Context, uid int32) error {
+	key := fmt.Sprintf(redisRevokedUserKey, uid)
+	if err := store.client.Set(ctx, key, time.Now().Unix(), 0).Err(); err != nil {
+		store.logger.Error("performing set on redis",
+			zap.Int32("user_id", uid),
+			zap.Error(err))
+
+		return ErrUnknown
+	}
+
+	return nil
+}
+
+// IsRevoked returns true if, and only if, either the given session or all the sessions of its user issued
+// after it have been revoked.
+func (store *RedisSessionStore) IsRevoked(ctx context.Context, session *Session) (bool, error) {
+	if len(session.id) > 0 {
+		key := fmt.Sprintf(redisRevokedSessionKey, session.id)
+		if count, err := store.client.Exists(ctx, key).Result(); err != nil {
+			store.logger.Error("performing exists on redis",
+				zap.String("session_id", session.id),
+				zap.Error(err))
+
+			return false, ErrUnknown
+		} else if count > 0 {
+			return true, nil
+		}
+	}
+
+	key := fmt.Sprintf(redisRevokedUserKey, session.uid)
+	value, err := store.client.Get(ctx, key).Result()
+	if errors.Is(err, redis.Nil) {
+		return false, nil
+	} else if err != nil {
+		store.logger.Error("performing get on redis",
+			zap.Int32("user_id", session.uid),
+			zap.Error(err))
+
+		return false, ErrUnknown
+	}
+
+	revokedAt, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		store.logger.Error("parsing user revocation timestamp",
+			zap.Int32("user_id", session.uid),
+			zap.String("value", value),
+			zap.Error(err))
+
+		return false, ErrUnknown
+	}
+
+	// tokens have a precision of seconds, so those issued the same second the user was revoked are revoked too
+	return session.issuedAt.Unix() <= revokedAt, nil
+}
+
+// NewLogoutHandler returns a handler revoking the session of those requests that have been authenticated.
+func NewLogoutHandler(sessions SessionStore, logger *zap.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		session, exists := SessionFromContext(r.Context())
+		if !exists {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		if err := sessions.RevokeSession(r.Context(), session); errors.Is(err, ErrInvalidToken) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		} else if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		logger.Info("session revoked",
+			zap.String("session_id", session.id),
+			zap.Int32("user_id", session.uid))
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
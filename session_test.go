@@ -0,0 +1,168 @@
+package filebrowser
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+func newTestSessionStore(t *testing.T, logger *zap.Logger) (*RedisSessionStore, *miniredis.Miniredis) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	return NewRedisSessionStore(client, logger), server
+}
+
+func TestRevokeSession(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	store, server := newTestSessionStore(t, logger)
+
+	session := &Session{
+		id:        "session",
+		uid:       999,
+		issuedAt:  time.Now(),
+		expiresAt: time.Now().Add(time.Hour),
+	}
+
+	another := &Session{
+		id:       "another",
+		uid:      999,
+		issuedAt: time.Now(),
+	}
+
+	if err := store.RevokeSession(context.Background(), session); err != nil {
+		t.Fatalf("got error = %v, want = %v", err, nil)
+	}
+
+	if revoked, err := store.IsRevoked(context.Background(), session); err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+	} else if !revoked {
+		t.Errorf("got revoked = %v, want = %v", revoked, true)
+	}
+
+	if revoked, err := store.IsRevoked(context.Background(), another); err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+	} else if revoked {
+		t.Errorf("got revoked = %v, want = %v", revoked, false)
+	}
+
+	// the revocation is kept no longer than the session itself
+	server.FastForward(time.Hour)
+	if revoked, err := store.IsRevoked(context.Background(), session); err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+	} else if revoked {
+		t.Errorf("got revoked = %v, want = %v", revoked, false)
+	}
+
+	if err := store.RevokeSession(context.Background(), &Session{uid: 999}); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("got error = %v, want = %v", err, ErrInvalidToken)
+	}
+}
+
+func TestRevokeUser(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	store, _ := newTestSessionStore(t, logger)
+
+	before := &Session{
+		id:       "before",
+		uid:      999,
+		issuedAt: time.Now().Add(-time.Minute),
+	}
+
+	after := &Session{
+		id:       "after",
+		uid:      999,
+		issuedAt: time.Now().Add(time.Minute),
+	}
+
+	another := &Session{
+		id:       "another",
+		uid:      888,
+		issuedAt: time.Now().Add(-time.Minute),
+	}
+
+	if err := store.RevokeUser(context.Background(), 999); err != nil {
+		t.Fatalf("got error = %v, want = %v", err, nil)
+	}
+
+	for _, test := range []struct {
+		session *Session
+		revoked bool
+	}{
+		{session: before, revoked: true},
+		{session: after, revoked: false},
+		{session: another, revoked: false},
+	} {
+		if revoked, err := store.IsRevoked(context.Background(), test.session); err != nil {
+			t.Errorf("%s: got error = %v, want = %v", test.session.id, err, nil)
+		} else if revoked != test.revoked {
+			t.Errorf("%s: got revoked = %v, want = %v", test.session.id, revoked, test.revoked)
+		}
+	}
+}
+
+func TestTokenVerifierWithSessionStore(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	store, server := newTestSessionStore(t, logger)
+
+	secret := []byte("secret")
+	verifier := NewTokenVerifier(secret, "", 0, logger)
+	verifier.SetSessionStore(store)
+
+	token := newTestToken(t, jwt.SigningMethodHS256, secret, jwt.RegisteredClaims{
+		ID:        "session",
+		Subject:   "999",
+		IssuedAt:  jwt.NewNumericDate(time.Now().Add(-time.Minute)),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	})
+
+	session, err := verifier.Verify(context.Background(), token)
+	if err != nil {
+		t.Fatalf("got error = %v, want = %v", err, nil)
+	}
+
+	// logging out revokes the session the request has been authenticated with
+	logout := verifier.HttpMiddleware(NewLogoutHandler(store, logger))
+
+	req := httptest.NewRequest(http.MethodPost, "/logout", nil)
+	req.Header.Set(AuthorizationHeader, BearerPrefix+token)
+
+	rec := httptest.NewRecorder()
+	logout.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("got status = %v, want = %v", rec.Code, http.StatusNoContent)
+	}
+
+	if _, err := verifier.Verify(context.Background(), token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("got error = %v, want = %v", err, ErrInvalidToken)
+	}
+
+	rec = httptest.NewRecorder()
+	logout.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("got status = %v, want = %v", rec.Code, http.StatusUnauthorized)
+	}
+
+	// any failure of the store must not be taken as the session being valid
+	server.Close()
+	if _, err := verifier.Verify(context.Background(), token); !errors.Is(err, ErrUnknown) {
+		t.Errorf("got error = %v, want = %v", err, ErrUnknown)
+	}
+
+	if session.Uid() != 999 {
+		t.Errorf("got uid = %v, want = %v", session.Uid(), 999)
+	}
+}
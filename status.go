@@ -0,0 +1,124 @@
+package filebrowser
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const ErrorDomain = "filebrowser"
+
+// errorStatus relates each known error with its gRPC code and HTTP status.
+type errorStatus struct {
+	err      error
+	code     codes.Code
+	httpCode int
+}
+
+var errorStatuses = []errorStatus{
+	{err: ErrUnknown, code: codes.Internal, httpCode: http.StatusInternalServerError},
+	{err: ErrNotFound, code: codes.NotFound, httpCode: http.StatusNotFound},
+	{err: ErrNotAvailable, code: codes.PermissionDenied, httpCode: http.StatusForbidden},
+	{err: ErrUnauthorized, code: codes.Unauthenticated, httpCode: http.StatusUnauthorized},
+	{err: ErrInvalidToken, code: codes.Unauthenticated, httpCode: http.StatusUnauthorized},
+	{err: ErrInvalidFormat, code: codes.InvalidArgument, httpCode: http.StatusBadRequest},
+	{err: ErrInvalidHeader, code: codes.InvalidArgument, httpCode: http.StatusBadRequest},
+	{err: ErrRegexNotMatch, code: codes.InvalidArgument, httpCode: http.StatusBadRequest},
+	{err: ErrAlreadyExists, code: codes.AlreadyExists, httpCode: http.StatusConflict},
+	{err: ErrConflict, code: codes.Aborted, httpCode: http.StatusConflict},
+	{err: ErrQuotaExceeded, code: codes.ResourceExhausted, httpCode: http.StatusInsufficientStorage},
+	{err: context.Canceled, code: codes.Canceled, httpCode: 499}, // client closed request
+	{err: context.DeadlineExceeded, code: codes.DeadlineExceeded, httpCode: http.StatusGatewayTimeout},
+}
+
+func findErrorStatus(err error) (errorStatus, bool) {
+	for _, candidate := range errorStatuses {
+		if errors.Is(err, candidate.err) {
+			return candidate, true
+		}
+	}
+
+	return errorStatus{}, false
+}
+
+// GrpcStatus returns the gRPC status the given error stands for, whose details include the error code (e.g. E002)
+// as the reason of an ErrorInfo.
+func GrpcStatus(err error) *status.Status {
+	if st, ok := status.FromError(err); ok {
+		// the error is either nil or already a gRPC status
+		return st
+	}
+
+	code, reason := codes.Unknown, ErrUnknown.Error()
+	if known, exists := findErrorStatus(err); exists {
+		code, reason = known.code, known.err.Error()
+	}
+
+	st := status.New(code, err.Error())
+	if detailed, err := st.WithDetails(&errdetails.ErrorInfo{
+		Reason: reason,
+		Domain: ErrorDomain,
+	}); err == nil {
+		st = detailed
+	}
+
+	return st
+}
+
+// HttpStatus returns the HTTP status code matching the gRPC status the given error stands for.
+func HttpStatus(err error) int {
+	if err == nil {
+		return http.StatusOK
+	}
+
+	if known, exists := findErrorStatus(err); exists {
+		return known.httpCode
+	}
+
+	return http.StatusInternalServerError
+}
+
+// HttpError replies to the request with the given error and the HTTP status it stands for.
+func HttpError(w http.ResponseWriter, err error) {
+	http.Error(w, err.Error(), HttpStatus(err))
+}
+
+// UnaryErrorInterceptor converts any error returned by a unary call into its proper gRPC status.
+func UnaryErrorInterceptor(logger *zap.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		resp, err := handler(ctx, req)
+		if err != nil {
+			return resp, grpcError(err, info.FullMethod, logger)
+		}
+
+		return resp, nil
+	}
+}
+
+// StreamErrorInterceptor converts any error returned by a stream into its proper gRPC status.
+func StreamErrorInterceptor(logger *zap.Logger) grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := handler(srv, stream); err != nil {
+			return grpcError(err, info.FullMethod, logger)
+		}
+
+		return nil
+	}
+}
+
+func grpcError(err error, method string, logger *zap.Logger) error {
+	st := GrpcStatus(err)
+	if st.Code() == codes.Unknown {
+		logger.Warn("unmapped grpc error",
+			zap.String("method", method),
+			zap.Error(err))
+	}
+
+	return st.Err()
+}
@@ -0,0 +1,116 @@
+package filebrowser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestGrpcStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		code   codes.Code
+		reason string
+		http   int
+	}{
+		{
+			name:   "not found",
+			err:    ErrNotFound,
+			code:   codes.NotFound,
+			reason: ErrNotFound.Error(),
+			http:   http.StatusNotFound,
+		},
+		{
+			name:   "not available",
+			err:    ErrNotAvailable,
+			code:   codes.PermissionDenied,
+			reason: ErrNotAvailable.Error(),
+			http:   http.StatusForbidden,
+		},
+		{
+			name:   "already exists",
+			err:    ErrAlreadyExists,
+			code:   codes.AlreadyExists,
+			reason: ErrAlreadyExists.Error(),
+			http:   http.StatusConflict,
+		},
+		{
+			name:   "wrapped invalid format",
+			err:    fmt.Errorf("parsing path: %w", ErrInvalidFormat),
+			code:   codes.InvalidArgument,
+			reason: ErrInvalidFormat.Error(),
+			http:   http.StatusBadRequest,
+		},
+		{
+			name:   "unknown error",
+			err:    errors.New("unexpected"),
+			code:   codes.Unknown,
+			reason: ErrUnknown.Error(),
+			http:   http.StatusInternalServerError,
+		},
+	}
+
+	for _, test := range tests {
+		st := GrpcStatus(test.err)
+		if st.Code() != test.code {
+			t.Errorf("%s: got code = %v, want = %v", test.name, st.Code(), test.code)
+		}
+
+		var info *errdetails.ErrorInfo
+		for _, detail := range st.Details() {
+			if detail, ok := detail.(*errdetails.ErrorInfo); ok {
+				info = detail
+			}
+		}
+
+		if info == nil {
+			t.Errorf("%s: got error info = %v, want = %v", test.name, nil, test.reason)
+		} else if info.GetReason() != test.reason || info.GetDomain() != ErrorDomain {
+			t.Errorf("%s: got reason = %v, want = %v", test.name, info.GetReason(), test.reason)
+		}
+
+		if got := HttpStatus(test.err); got != test.http {
+			t.Errorf("%s: got http status = %v, want = %v", test.name, got, test.http)
+		}
+	}
+}
+
+func TestUnaryErrorInterceptor(t *testing.T) {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	interceptor := UnaryErrorInterceptor(logger)
+	info := &grpc.UnaryServerInfo{FullMethod: "/test"}
+
+	_, err := interceptor(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, ErrNotFound
+	})
+
+	if got := status.Code(err); got != codes.NotFound {
+		t.Errorf("got code = %v, want = %v", got, codes.NotFound)
+	}
+
+	// errors that are already a status must be kept as they are
+	_, err = interceptor(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, status.Error(codes.ResourceExhausted, "exhausted")
+	})
+
+	if got := status.Code(err); got != codes.ResourceExhausted {
+		t.Errorf("got code = %v, want = %v", got, codes.ResourceExhausted)
+	}
+
+	if _, err = interceptor(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, nil
+	}); err != nil {
+		t.Errorf("got error = %v, want = %v", err, nil)
+	}
+}
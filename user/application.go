@@ -1,6 +1,7 @@
 package user
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 
@@ -13,13 +14,15 @@ import (
 type UserApplication struct {
 	dirRepo  dir.DirectoryRepository
 	fileRepo file.FileRepository
+	content  *file.ContentStore
 	logger   *zap.Logger
 }
 
-func NewUserApplication(dirRepo dir.DirectoryRepository, fileRepo file.FileRepository, logger *zap.Logger) *UserApplication {
+func NewUserApplication(dirRepo dir.DirectoryRepository, fileRepo file.FileRepository, content *file.ContentStore, logger *zap.Logger) *UserApplication {
 	return &UserApplication{
-		fileRepo: fileRepo,
 		dirRepo:  dirRepo,
+		fileRepo: fileRepo,
+		content:  content,
 		logger:   logger,
 	}
 }
@@ -48,13 +51,19 @@ func (app *UserApplication) GetProfile(ctx context.Context, uid int32) (*Profile
 		return nil, fb.ErrNotFound
 	}
 
-	f, err = app.fileRepo.Find(ctx, f.Id())
+	// lazy loaded files have no reference to their content
+	f, err = app.fileRepo.Find(ctx, f.Id(), nil)
 	if err != nil {
 		return nil, err
 	}
 
+	var data bytes.Buffer
+	if err := app.content.Read(ctx, f, &data); err != nil {
+		return nil, err
+	}
+
 	profile := new(Profile)
-	if err := json.Unmarshal(f.Data(), profile); err != nil {
+	if err := json.Unmarshal(data.Bytes(), profile); err != nil {
 		app.logger.Error("unmarshaling profile data",
 			zap.Int32("user_id", uid),
 			zap.Error(err))
@@ -62,5 +71,19 @@ func (app *UserApplication) GetProfile(ctx context.Context, uid int32) (*Profile
 		return nil, fb.ErrUnknown
 	}
 
+	// the usage is never stored in the profile, since it changes along with the user's files
+	usage, err := app.content.Quotas().Usage(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	quota := usage.Quota()
+	profile.Usage = &Usage{
+		Bytes:    usage.Bytes(),
+		Files:    usage.Files(),
+		MaxBytes: quota.Bytes,
+		MaxFiles: quota.Files,
+	}
+
 	return profile, nil
 }
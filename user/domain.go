@@ -8,4 +8,13 @@ const (
 type Profile struct {
 	Name  string `json:"user_name"`
 	Email string `json:"user_email"`
+	Usage *Usage `json:"user_usage,omitempty"`
+}
+
+// Usage is the storage a user consumes, as well as the most it may consume, if limited.
+type Usage struct {
+	Bytes    int64 `json:"bytes"`
+	Files    int64 `json:"files"`
+	MaxBytes int64 `json:"max_bytes,omitempty"`
+	MaxFiles int64 `json:"max_files,omitempty"`
 }
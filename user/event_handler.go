@@ -18,9 +18,10 @@ type UserEventPayload struct {
 }
 
 type UserEventHandler struct {
-	dirApp  *dir.DirectoryApplication
-	fileApp *file.FileApplication
-	logger  *zap.Logger
+	dirApp   *dir.DirectoryApplication
+	fileApp  *file.FileApplication
+	sessions fb.SessionStore
+	logger   *zap.Logger
 }
 
 func NewUserEventHandler(dirApp *dir.DirectoryApplication, fileApp *file.FileApplication, logger *zap.Logger) *UserEventHandler {
@@ -31,6 +32,11 @@ func NewUserEventHandler(dirApp *dir.DirectoryApplication, fileApp *file.FileApp
 	}
 }
 
+// SetSessionStore makes the handler revoke all the sessions of those users being deleted or disabled.
+func (handler *UserEventHandler) SetSessionStore(sessions fb.SessionStore) {
+	handler.sessions = sessions
+}
+
 func (handler *UserEventHandler) OnEvent(ctx context.Context, body []byte) {
 	event := new(UserEventPayload)
 	if err := json.Unmarshal(body, event); err != nil {
@@ -48,6 +54,12 @@ func (handler *UserEventHandler) OnEvent(ctx context.Context, body []byte) {
 
 		handler.onUserCreatedEvent(ctx, event)
 
+	case fb.EventKindDeleted, fb.EventKindDisabled:
+		handler.logger.Info("handling user event",
+			zap.String("kind", kind))
+
+		handler.onUserRevokedEvent(ctx, event)
+
 	default:
 		handler.logger.Warn("unhandled user event",
 			zap.String("kind", kind))
@@ -88,3 +100,15 @@ func (handler *UserEventHandler) onUserCreatedEvent(ctx context.Context, event *
 		return
 	}
 }
+
+func (handler *UserEventHandler) onUserRevokedEvent(ctx context.Context, event *UserEventPayload) {
+	if handler.sessions == nil {
+		return
+	}
+
+	if err := handler.sessions.RevokeUser(ctx, event.UserID); err != nil {
+		handler.logger.Error("revoking user sessions",
+			zap.Int32("user_id", event.UserID),
+			zap.Error(err))
+	}
+}
@@ -34,13 +34,13 @@ func (server *UserRestService) ServeHTTP(w http.ResponseWriter, r *http.Request)
 func (server *UserRestService) getProfileHandler(w http.ResponseWriter, r *http.Request) {
 	uid, err := fb.GetUidFromHttpRequest(r, server.uidHeader, server.logger)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusUnauthorized)
+		fb.HttpError(w, err)
 		return
 	}
 
 	profile, err := server.app.GetProfile(r.Context(), uid)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		fb.HttpError(w, err)
 		return
 	}
 
@@ -56,3 +56,35 @@ func (server *UserRestService) getProfileHandler(w http.ResponseWriter, r *http.
 			zap.Error(err))
 	}
 }
+
+// RegisterRoutes describes into the given registry all the routes served by the service.
+func (server *UserRestService) RegisterRoutes(registry *fb.RestRegistry) {
+	registry.AddSchema("Usage", &fb.Schema{
+		Type: "object",
+		Properties: map[string]*fb.Schema{
+			"bytes":     {Type: "integer", Format: "int64"},
+			"files":     {Type: "integer", Format: "int64"},
+			"max_bytes": {Type: "integer", Format: "int64", Description: "The most bytes the user may consume, if limited"},
+			"max_files": {Type: "integer", Format: "int64", Description: "The most files the user may own, if limited"},
+		},
+	})
+
+	registry.AddSchema("Profile", &fb.Schema{
+		Type: "object",
+		Properties: map[string]*fb.Schema{
+			"user_name":  {Type: "string"},
+			"user_email": {Type: "string"},
+			"user_usage": fb.SchemaRef("Usage"),
+		},
+	})
+
+	registry.Register(fb.Route{
+		Method:  http.MethodGet,
+		Path:    "/profile",
+		Summary: "Returns the profile of the authenticated user",
+		Responses: map[int]fb.Response{
+			http.StatusOK: {Description: "The profile", ContentType: fb.JsonContentType, Schema: fb.SchemaRef("Profile")},
+		},
+		Errors: []error{fb.ErrNotFound},
+	})
+}